@@ -0,0 +1,293 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type SigningKey struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func runAdmin(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: go run . -config <file> admin tenants | usage | create-key <user> | purge | reindex | vacuum | rotate-keys | backfill-stats")
+		return
+	}
+
+	fs := flag.NewFlagSet("admin "+args[0], flag.ExitOnError)
+	role := fs.String("role", "", "Role for a new user (create-key)")
+	tenant := fs.String("tenant", "", "Tenant for a new user (create-key) or to restrict purging or usage to")
+	status := fs.String("status", statusArchived, "Status of runs to purge")
+	before := fs.String("before", "", "Only purge runs created before this date (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "List runs that would be purged without deleting them")
+	keep := fs.Int("keep", 2, "Signing keys to keep after rotation, including the new one")
+	fs.Parse(args[1:])
+
+	var err error
+	switch args[0] {
+	case "tenants":
+		err = adminTenants()
+	case "usage":
+		err = adminUsage(*tenant)
+	case "create-key":
+		if fs.NArg() < 1 {
+			fmt.Println("Usage: go run . -config <file> admin create-key [-role r] [-tenant t] <user>")
+			return
+		}
+		err = adminCreateKey(fs.Arg(0), *role, *tenant)
+	case "purge":
+		err = adminPurge(*tenant, *status, *before, *dryRun)
+	case "reindex", "vacuum":
+		err = adminMaintain(args[0])
+	case "rotate-keys":
+		err = adminRotateKeys(*keep)
+	case "backfill-stats":
+		err = adminBackfillStats()
+	default:
+		err = fmt.Errorf("unknown admin command %q", args[0])
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func adminTenants() error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	runs, err := store.ListRuns()
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, run := range runs {
+		counts[run.Tenant]++
+	}
+	users := make(map[string]int)
+	for _, u := range cfg.Users {
+		users[u.Tenant]++
+	}
+
+	ids := make(map[string]bool)
+	for _, t := range cfg.Tenants {
+		ids[t.ID] = true
+	}
+	for id := range counts {
+		ids[id] = true
+	}
+	var list []string
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+
+	for _, id := range list {
+		t := cfg.tenant(id)
+		name := id
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("%s | %d runs | %d users | max runs: %s | max students: %s\n",
+			name, counts[id], users[id], quotaText(t.MaxRuns), quotaText(t.MaxStudents))
+	}
+	return nil
+}
+
+func quotaText(n int) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
+
+func adminCreateKey(name, role, tenant string) error {
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	key := randomHex(24)
+	found := false
+	for i := range cfg.Users {
+		if cfg.Users[i].Name == name {
+			cfg.Users[i].APIKey = key
+			if role != "" {
+				cfg.Users[i].Role = role
+			}
+			if tenant != "" {
+				cfg.Users[i].Tenant = tenant
+			}
+			found = true
+		}
+	}
+	if !found {
+		cfg.Users = append(cfg.Users, User{Name: name, Role: role, APIKey: key, Tenant: tenant})
+	}
+
+	if err := writeConfigFile(configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("API key for %s: %s\n", name, key)
+	return nil
+}
+
+func adminPurge(tenant, status, before string, dryRun bool) error {
+	if status == statusPublished {
+		return fmt.Errorf("published runs are immutable; archive them before purging")
+	}
+	var cutoff time.Time
+	if before != "" {
+		var err error
+		if cutoff, err = time.Parse("2006-01-02", before); err != nil {
+			return fmt.Errorf("invalid -before date: %w", err)
+		}
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	runs, err := store.ListRuns()
+	if err != nil {
+		return err
+	}
+	purged := 0
+	for _, run := range runs {
+		if runStatus(run) != status || (tenant != "" && run.Tenant != tenant) {
+			continue
+		}
+		if !cutoff.IsZero() && !run.CreatedAt.Before(cutoff) {
+			continue
+		}
+		purged++
+		if dryRun {
+			fmt.Printf("Would purge %s | %s | %s | %s\n", run.ID, runStatus(run), run.Course, run.CreatedAt.Format("2006-01-02 15:04"))
+			continue
+		}
+		if err := store.DeleteRun(run.ID); err != nil {
+			return fmt.Errorf("purging %s: %w", run.ID, err)
+		}
+		store.AppendAudit(AuditEntry{RunID: run.ID, Time: time.Now(), Actor: userName, Action: "run.purged", Detail: runStatus(run)})
+	}
+
+	if dryRun {
+		fmt.Printf("%d runs would be purged\n", purged)
+	} else {
+		fmt.Printf("Purged %d runs\n", purged)
+	}
+	return nil
+}
+
+func adminMaintain(task string) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	m, ok := store.(maintainer)
+	if !ok {
+		fmt.Printf("Nothing to %s for %s storage\n", task, storageName(cfg.Storage))
+		return nil
+	}
+	if task == "reindex" {
+		err = m.Reindex()
+	} else {
+		err = m.Vacuum()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s storage: %s done\n", storageName(cfg.Storage), task)
+	return nil
+}
+
+// adminBackfillStats snapshots runs saved before statistics were recorded.
+func adminBackfillStats() error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	snaps, err := store.ListStats("")
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(snaps))
+	for _, s := range snaps {
+		have[s.RunID] = true
+	}
+	runs, err := store.ListRuns()
+	if err != nil {
+		return err
+	}
+	added := 0
+	for _, summary := range runs {
+		if have[summary.ID] {
+			continue
+		}
+		run, err := store.GetRun(summary.ID)
+		if err != nil {
+			return err
+		}
+		if err := store.SaveStats(statsSnapshot(cfg, run)); err != nil {
+			return err
+		}
+		added++
+	}
+	fmt.Printf("Recorded statistics for %d of %d runs\n", added, len(runs))
+	return nil
+}
+
+// adminRotateKeys adds a new signing key in front of the existing ones. The
+// first key signs; the older ones kept are still accepted for verification.
+func adminRotateKeys(keep int) error {
+	if keep < 1 {
+		return fmt.Errorf("-keep must be at least 1")
+	}
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	key := SigningKey{ID: randomHex(4), Secret: randomHex(32), CreatedAt: time.Now().UTC()}
+	cfg.Keys = append([]SigningKey{key}, cfg.Keys...)
+	retired := 0
+	if len(cfg.Keys) > keep {
+		retired = len(cfg.Keys) - keep
+		cfg.Keys = cfg.Keys[:keep]
+	}
+
+	if err := writeConfigFile(configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("New signing key %s; %d kept, %d retired\n", key.ID, len(cfg.Keys), retired)
+	return nil
+}
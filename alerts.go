@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var alertSpec string
+
+// AlertRule marks a run as needing attention when a statistic crosses a
+// threshold, e.g. {"metric": "pass_rate", "op": "<", "value": 70}.
+type AlertRule struct {
+	Metric string  `json:"metric"`
+	Op     string  `json:"op"`
+	Value  float64 `json:"value"`
+}
+
+// alertMetrics are the statistics alert rules can test, with what each
+// measures. Rates and divergences are percentages.
+var alertMetrics = map[string]string{
+	"pass_rate":         "percentage of students at or above the course's pass mark",
+	"mean_pct":          "mean computed total, as a percentage of the course's maximum",
+	"branch_divergence": "largest difference of a branch's mean total from the cohort's, as a percentage of the cohort's",
+	"errors":            "validation errors",
+	"warnings":          "validation warnings",
+	"students":          "students read",
+}
+
+// Alert is a rule that fired, with the value that fired it.
+type Alert struct {
+	Rule   AlertRule `json:"rule"`
+	Actual float64   `json:"actual"`
+	// Detail names what the value belongs to, e.g. the branch that diverges.
+	Detail string `json:"detail,omitempty"`
+}
+
+func (a Alert) String() string {
+	actual, unit := strconv.FormatFloat(a.Actual, 'f', -1, 64), ""
+	switch a.Rule.Metric {
+	case "pass_rate", "mean_pct", "branch_divergence":
+		actual, unit = formatNumber(a.Actual), "%"
+	}
+	text := fmt.Sprintf("%s %s%s (alert when %s %s%s)", strings.ReplaceAll(a.Rule.Metric, "_", " "),
+		actual, unit, a.Rule.Op, strconv.FormatFloat(a.Rule.Value, 'f', -1, 64), unit)
+	if a.Detail != "" {
+		text += ": " + a.Detail
+	}
+	return text
+}
+
+func (r AlertRule) validate() error {
+	if _, ok := alertMetrics[r.Metric]; !ok {
+		names := make([]string, 0, len(alertMetrics))
+		for name := range alertMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown alert metric %q (want one of %s)", r.Metric, strings.Join(names, ", "))
+	}
+	switch r.Op {
+	case "<", "<=", ">", ">=":
+		return nil
+	}
+	return fmt.Errorf("alert on %s: unknown comparison %q (want <, <=, > or >=)", r.Metric, r.Op)
+}
+
+func (r AlertRule) fires(v float64) bool {
+	switch r.Op {
+	case "<":
+		return v < r.Value
+	case "<=":
+		return v <= r.Value
+	case ">":
+		return v > r.Value
+	case ">=":
+		return v >= r.Value
+	}
+	return false
+}
+
+// parseAlertRules reads -alert, e.g. "pass_rate<70,errors>50".
+func parseAlertRules(spec string) ([]AlertRule, error) {
+	var rules []AlertRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.IndexAny(part, "<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid alert %q (want metric<value or metric>value)", part)
+		}
+		op := part[i : i+1]
+		rest := part[i+1:]
+		if strings.HasPrefix(rest, "=") {
+			op += "="
+			rest = rest[1:]
+		}
+		value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rest), "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert %q: %v", part, err)
+		}
+		rule := AlertRule{Metric: strings.TrimSpace(part[:i]), Op: op, Value: value}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// evaluateAlerts checks the rules against a course's students and issues,
+// returning those that fire. A branch divergence rule fires once for each
+// branch that crosses it.
+func evaluateAlerts(cfg Config, rules []AlertRule, course string, set ComponentSet, students []Student, issues []Issue) []Alert {
+	if len(rules) == 0 {
+		return nil
+	}
+	c := cfg.course(course)
+	var sum float64
+	passed := 0
+	branchSum := make(map[string]float64)
+	branchCount := make(map[string]int)
+	for _, s := range students {
+		total := set.total(s)
+		sum += total
+		if total/c.MaxTotal*100 >= c.PassPct {
+			passed++
+		}
+		branchSum[s.Branch] += total
+		branchCount[s.Branch]++
+	}
+
+	metrics := map[string]float64{
+		"errors":   float64(countIssues(issues, severityError)),
+		"warnings": float64(countIssues(issues, severityWarning)),
+		"students": float64(len(students)),
+	}
+	branches := make([]string, 0, len(branchCount))
+	for branch := range branchCount {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	var cohort float64
+	if len(students) > 0 {
+		cohort = sum / float64(len(students))
+		metrics["pass_rate"] = float64(passed) / float64(len(students)) * 100
+		metrics["mean_pct"] = cohort / c.MaxTotal * 100
+	}
+
+	var alerts []Alert
+	for _, rule := range rules {
+		if rule.Metric == "branch_divergence" {
+			if cohort == 0 {
+				continue
+			}
+			for _, branch := range branches {
+				mean := branchSum[branch] / float64(branchCount[branch])
+				diff := math.Abs(mean-cohort) / cohort * 100
+				if rule.fires(diff) {
+					alerts = append(alerts, Alert{Rule: rule, Actual: diff,
+						Detail: fmt.Sprintf("branch %s averages %s against the cohort's %s", branch, formatNumber(mean), formatNumber(cohort))})
+				}
+			}
+			continue
+		}
+		// With no students there is no pass rate or mean to alert on.
+		if v, ok := metrics[rule.Metric]; ok && rule.fires(v) {
+			alerts = append(alerts, Alert{Rule: rule, Actual: v})
+		}
+	}
+	return alerts
+}
+
+// alertRules are the rules from the config followed by those from -alert.
+func alertRules(cfg Config) ([]AlertRule, error) {
+	extra, err := parseAlertRules(alertSpec)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]AlertRule(nil), cfg.Alerts...), extra...), nil
+}
+
+func alertTexts(alerts []Alert) []string {
+	texts := make([]string, len(alerts))
+	for i, a := range alerts {
+		texts[i] = a.String()
+	}
+	return texts
+}
+
+// printAlerts reports whether the run needs attention, in red or green on
+// a terminal.
+func printAlerts(alerts []Alert) {
+	if len(alerts) == 0 {
+		fmt.Println(colorize("\nAlerts: none triggered.", ansiGreen))
+		return
+	}
+	heading := fmt.Sprintf("\nNEEDS ATTENTION (%d alerts):", len(alerts))
+	if len(alerts) == 1 {
+		heading = "\nNEEDS ATTENTION (1 alert):"
+	}
+	fmt.Println(colorize(heading, ansiRed))
+	for _, a := range alerts {
+		fmt.Println(colorize("  - "+a.String(), ansiRed))
+	}
+}
+
+const (
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiReset = "\033[0m"
+)
+
+// colorize wraps s in an ANSI colour when standard output is a terminal
+// and NO_COLOR is unset.
+func colorize(s, color string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return s
+	}
+	return color + s + ansiReset
+}
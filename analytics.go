@@ -0,0 +1,375 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	sqlFile         string
+	analyticsEngine string
+)
+
+func validateEngine(engine string) error {
+	switch engine {
+	case "go", "duckdb":
+		return nil
+	}
+	return fmt.Errorf("invalid -engine %q (want go or duckdb)", engine)
+}
+
+// useDuckDB tells whether the run is loaded into DuckDB: with -engine duckdb,
+// and for -sql-file, whose queries run there.
+func useDuckDB() bool {
+	return analyticsEngine == "duckdb" || sqlFile != ""
+}
+
+// QueryResult is one result table of a -sql-file query.
+type QueryResult struct {
+	Title   string     `json:"title"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// openAnalytics loads the run into an embedded, in-memory DuckDB database,
+// for engineStatistics and runSQLFile. The tables are:
+//
+//	students(emp_id, campus_id, class_no, name, branch, row, <component>..., total)
+//	marks(emp_id, branch, component, mark)
+//	issues(emp_id, row, rule, severity, message)
+//
+// with each component as a column named like quiz or mid_sem, and the
+// students in ranking order.
+func openAnalytics(set ComponentSet, students []Student, issues []Issue) (*sql.DB, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("%w (is the binary built with -tags duckdb?)", err)
+	}
+	// Statements run one after another on a single connection, so that
+	// temporary tables and settings made by one are seen by the next.
+	db.SetMaxOpenConns(1)
+	if err := loadAnalytics(db, set, students, issues); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// runSQLFile runs the statements in path against the analytics database,
+// returning a table for each that yields rows. DuckDB's window functions
+// (RANK, PERCENT_RANK, NTILE, ...) and quantile aggregates cover rankings
+// and percentiles. A comment line just before a query titles its table.
+func runSQLFile(db *sql.DB, path string) ([]QueryResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []QueryResult
+	for i, stmt := range splitSQL(string(data)) {
+		result, err := runQuery(db, stmt.text)
+		if err != nil {
+			return results, fmt.Errorf("%s: statement %d (line %d): %w", path, i+1, stmt.line, err)
+		}
+		if result == nil {
+			continue
+		}
+		result.Title = stmt.title
+		if result.Title == "" {
+			result.Title = fmt.Sprintf("Query %d", len(results)+1)
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// engineStatistics computes the report's ranks and statistics in the
+// analytics database instead of in Go: RANK() over the totals for the ranks,
+// and avg, quantile_cont and stddev_samp over each column of marks, which
+// give what markStats does. The item and -computed columns, which the
+// database does not hold, keep the statistics buildReport gave them.
+func engineStatistics(db *sql.DB, rep *Report) error {
+	rows, err := db.Query(`SELECT RANK() OVER (ORDER BY total DESC) FROM students ORDER BY rowid`)
+	if err != nil {
+		return err
+	}
+	var ranks []int
+	for rows.Next() {
+		var rank int
+		if err := rows.Scan(&rank); err != nil {
+			rows.Close()
+			return err
+		}
+		ranks = append(ranks, rank)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	const stats = `coalesce(avg(%[1]s), 0), coalesce(quantile_cont(%[1]s, 0.25), 0), coalesce(quantile_cont(%[1]s, 0.5), 0),
+		coalesce(quantile_cont(%[1]s, 0.75), 0), coalesce(min(%[1]s), 0), coalesce(max(%[1]s), 0), coalesce(stddev_samp(%[1]s), 0)`
+	scanStats := func(row *sql.Row, name string) (MarkStats, error) {
+		m := MarkStats{Name: name}
+		err := row.Scan(&m.Mean, &m.P25, &m.Median, &m.P75, &m.Min, &m.Max, &m.StdDev)
+		return m, err
+	}
+	total, err := scanStats(db.QueryRow(`SELECT `+fmt.Sprintf(stats, "total")+` FROM students`), rep.TotalStats.Name)
+	if err != nil {
+		return err
+	}
+	components := make([]MarkStats, len(rep.Components))
+	for i, c := range rep.Components {
+		if components[i], err = scanStats(db.QueryRow(`SELECT `+fmt.Sprintf(stats, "mark")+` FROM marks WHERE component = ?`, c.Name), c.Name); err != nil {
+			return err
+		}
+	}
+
+	rows, err = db.Query(`SELECT branch, count(*), avg(total) FROM students GROUP BY branch ORDER BY branch`)
+	if err != nil {
+		return err
+	}
+	var branches []BranchStats
+	index := make(map[string]int)
+	for rows.Next() {
+		b := BranchStats{Means: make(map[string]float64)}
+		if err := rows.Scan(&b.Branch, &b.Students, &b.AverageTotal); err != nil {
+			rows.Close()
+			return err
+		}
+		index[b.Branch] = len(branches)
+		branches = append(branches, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows, err = db.Query(`SELECT branch, component, avg(mark) FROM marks GROUP BY branch, component`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var branch, component string
+		var mean float64
+		if err := rows.Scan(&branch, &component, &mean); err != nil {
+			return err
+		}
+		branches[index[branch]].Means[component] = mean
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rep.Ranks, rep.TotalStats, rep.Components, rep.Branches = ranks, total, components, branches
+	return nil
+}
+
+func loadAnalytics(db *sql.DB, set ComponentSet, students []Student, issues []Issue) error {
+	names := marksTable(nil, set).Names
+	cols := []string{"emp_id VARCHAR", "campus_id VARCHAR", "class_no VARCHAR", "name VARCHAR", "branch VARCHAR", "row INTEGER"}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		col := sqlColumn(name)
+		if seen[col] || col == "total" {
+			return fmt.Errorf("component %q has no column name of its own in SQL", name)
+		}
+		seen[col] = true
+		cols = append(cols, col+" DOUBLE")
+	}
+	cols = append(cols, "total DOUBLE")
+	schema := []string{
+		"CREATE TABLE students (" + strings.Join(cols, ", ") + ")",
+		"CREATE TABLE marks (emp_id VARCHAR, branch VARCHAR, component VARCHAR, mark DOUBLE)",
+		"CREATE TABLE issues (emp_id VARCHAR, row INTEGER, rule VARCHAR, severity VARCHAR, message VARCHAR)",
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	insertStudent, err := tx.Prepare("INSERT INTO students VALUES (?" + strings.Repeat(", ?", len(cols)-1) + ")")
+	if err != nil {
+		return err
+	}
+	insertMark, err := tx.Prepare("INSERT INTO marks VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	for _, s := range students {
+		values := []interface{}{s.EmpID, s.CampusID, s.ClassNo, s.Name, s.Branch, s.Row}
+		for _, name := range names {
+			values = append(values, s.Marks[name])
+			if _, err := insertMark.Exec(s.EmpID, s.Branch, name, s.Marks[name]); err != nil {
+				return err
+			}
+		}
+		values = append(values, set.total(s))
+		if _, err := insertStudent.Exec(values...); err != nil {
+			return err
+		}
+	}
+	insertIssue, err := tx.Prepare("INSERT INTO issues VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		if _, err := insertIssue.Exec(issue.EmpID, issue.Row, issue.Rule, issue.Severity, issue.Message); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlColumn names a component's column: lower case, with runs of anything
+// but letters and digits as underscores ("Mid-Sem" is mid_sem).
+func sqlColumn(name string) string {
+	col := strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "_")
+	if col == "" || unicode.IsDigit(rune(col[0])) {
+		col = "c_" + col
+	}
+	return col
+}
+
+// runQuery runs one statement, returning its rows, or nil when it yields
+// none, as for CREATE VIEW.
+func runQuery(db *sql.DB, stmt string) (*QueryResult, error) {
+	rows, err := db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		// Queried statements only run as their rows are read.
+		rows.Close()
+		_, err := db.Exec(stmt)
+		return nil, err
+	}
+
+	result := &QueryResult{Columns: cols, Rows: [][]string{}}
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(cols))
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+			case float64:
+				row[i] = formatNumber(v)
+			case float32:
+				row[i] = formatNumber(float64(v))
+			case int64:
+				row[i] = strconv.FormatInt(v, 10)
+			case []byte:
+				row[i] = string(v)
+			default:
+				row[i] = fmt.Sprint(v)
+				// DuckDB's DECIMAL values convert to float64 through a
+				// pointer.
+				p := reflect.New(reflect.TypeOf(v))
+				p.Elem().Set(reflect.ValueOf(v))
+				if d, ok := p.Interface().(interface{ Float64() float64 }); ok {
+					row[i] = formatNumber(d.Float64())
+				}
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+type sqlStatement struct {
+	text  string
+	title string
+	line  int
+}
+
+// splitSQL splits a script into statements at semicolons outside quotes
+// and comments. A statement's title is the last -- comment line before it.
+func splitSQL(script string) []sqlStatement {
+	var stmts []sqlStatement
+	var b strings.Builder
+	title, line, start := "", 1, 0
+	var quote rune
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' {
+			line++
+		}
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			if strings.TrimSpace(b.String()) == "" {
+				title = strings.TrimSpace(string(runes[i+2 : end]))
+			} else {
+				b.WriteString(string(runes[i:end]))
+			}
+			i = end - 1
+			continue
+		case r == ';':
+			if text := strings.TrimSpace(b.String()); text != "" {
+				stmts = append(stmts, sqlStatement{text: text, title: title, line: start})
+			}
+			b.Reset()
+			title = ""
+			continue
+		}
+		if b.Len() == 0 && unicode.IsSpace(r) {
+			continue
+		}
+		if b.Len() == 0 {
+			start = line
+		}
+		b.WriteRune(r)
+	}
+	if text := strings.TrimSpace(b.String()); text != "" {
+		stmts = append(stmts, sqlStatement{text: text, title: title, line: start})
+	}
+	return stmts
+}
+
+func printQueryResults(results []QueryResult) {
+	for _, r := range results {
+		fmt.Printf("\n%s:\n", r.Title)
+		fmt.Println(strings.Join(r.Columns, " | "))
+		for _, row := range r.Rows {
+			fmt.Println(strings.Join(row, " | "))
+		}
+		if len(r.Rows) == 0 {
+			fmt.Println("(no rows)")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var annotateOut string
+
+// annotationFill is the red the cells an issue is about are filled with.
+const annotationFill = "FFC7CE"
+
+// annotateWorkbook writes a copy of the workbook at path to out with the
+// cells each unwaived issue is about filled red, and a comment on each
+// saying what was checked, and what was expected against what was found.
+// The rest of the workbook, formats and formulas included, is copied as it
+// is. It returns how many cells were marked.
+func annotateWorkbook(path, out string, students []Student, issues []Issue, layout Layout) (int, error) {
+	if isCSV(path) || isODS(path) || isXLS(path) || isGoogleSheet(path) {
+		return 0, fmt.Errorf("-annotate writes a copy of the workbook and needs an .xlsx file, not CSV, ODS, .xls or Google Sheets")
+	}
+	f, err := openWorkbook(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closeWorkbook(f)
+	sheet, err := selectSheet(f)
+	if err != nil {
+		return 0, err
+	}
+
+	byRow := make(map[int]Student, len(students))
+	for _, s := range students {
+		byRow[s.Row] = s
+	}
+	notes := make(map[string][]string)
+	for _, issue := range issues {
+		if issue.Severity != severityError && issue.Severity != severityWarning {
+			continue
+		}
+		note := issue.Message
+		if s, ok := byRow[issue.Row]; ok {
+			if ef := expectedFound(issue, s, layout.componentSet()); ef != "" {
+				note += "\n" + ef
+			}
+		}
+		for _, cell := range annotatedCells(issue, layout) {
+			if !slices.Contains(notes[cell], note) {
+				notes[cell] = append(notes[cell], note)
+			}
+		}
+	}
+	if len(notes) == 0 {
+		return 0, f.SaveAs(out)
+	}
+
+	existing := make(map[string]string)
+	comments, err := f.GetComments(sheet)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range comments {
+		existing[c.Cell] = commentText(c)
+	}
+
+	// Cells keep their own number format, font and borders; only the fill
+	// changes, so each style in use gets a red copy.
+	red := make(map[int]int)
+	cells := make([]string, 0, len(notes))
+	for cell := range notes {
+		cells = append(cells, cell)
+	}
+	sort.Strings(cells)
+	for _, cell := range cells {
+		id, err := f.GetCellStyle(sheet, cell)
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := red[id]; !ok {
+			style, err := f.GetStyle(id)
+			if err != nil {
+				return 0, err
+			}
+			style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{annotationFill}}
+			if red[id], err = f.NewStyle(style); err != nil {
+				return 0, err
+			}
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, red[id]); err != nil {
+			return 0, err
+		}
+
+		text := strings.Join(notes[cell], "\n\n")
+		if old, ok := existing[cell]; ok {
+			if err := f.DeleteComment(sheet, cell); err != nil {
+				return 0, err
+			}
+			text = old + "\n\n" + text
+		}
+		if err := f.AddComment(sheet, excelize.Comment{Cell: cell, Author: "gradetool", Text: text, Width: 300, Height: 120}); err != nil {
+			return 0, err
+		}
+	}
+	return len(cells), f.SaveAs(out)
+}
+
+// annotatedCells are the cells marked for an issue: the derived cell a sum
+// check found wrong, rather than every cell summed, and otherwise the cells
+// the issue is about (see Layout.cells).
+func annotatedCells(issue Issue, layout Layout) []string {
+	if issue.Row <= 0 {
+		return nil
+	}
+	var col string
+	switch issue.Rule {
+	case "pre-compre-sum":
+		col = "Pre-Compre"
+	case "total-sum":
+		col = "Final Total"
+	default:
+		return layout.cells(issue)
+	}
+	return layout.cells(issue.in(col))
+}
+
+// expectedFound says what the checked cell should hold and what it holds,
+// for the rules that can say.
+func expectedFound(issue Issue, s Student, set ComponentSet) string {
+	switch issue.Rule {
+	case "pre-compre-sum":
+		expected := s.Marks["Quiz"] + s.Marks["Mid-Sem"] + s.Marks["Lab Test"] + s.Marks["Weekly Labs"]
+		return "Expected: " + differing(expected, s.Marks["Pre-Compre"], " (Quiz + Mid-Sem + Lab Test + Weekly Labs)")
+	case "total-sum":
+		expected := s.Marks["Pre-Compre"] + s.Marks["Compre"]
+		return "Expected: " + differing(expected, s.Marks["Final Total"], " (Pre-Compre + Compre)")
+	case "out-of-range":
+		return fmt.Sprintf("Expected: 0 to %s, found: %s", formatNumber(set.max(issue.Column)), formatNumber(s.Marks[issue.Column]))
+	}
+	return ""
+}
+
+// differing shows an expected and a found value, in full when they only
+// differ past -decimals.
+func differing(expected, found float64, how string) string {
+	e, f := formatNumber(expected), formatNumber(found)
+	if e == f {
+		e, f = formatMark(expected), formatMark(found)
+	}
+	return e + how + ", found: " + f
+}
+
+func commentText(c excelize.Comment) string {
+	if len(c.Paragraph) == 0 {
+		return c.Text
+	}
+	var b strings.Builder
+	for _, run := range c.Paragraph {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
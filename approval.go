@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const roleIC = "ic"
+
+type Approval struct {
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+	ApprovedBy  string    `json:"approved_by,omitempty"`
+	ApprovedAt  time.Time `json:"approved_at,omitempty"`
+}
+
+type User struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	APIKey string `json:"api_key"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func (c Config) user(name string) User {
+	for _, u := range c.Users {
+		if u.Name == name {
+			return u
+		}
+	}
+	return User{Name: name}
+}
+
+func (c Config) userByKey(key string) (User, bool) {
+	for _, u := range c.Users {
+		if key != "" && u.APIKey == key {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func requestApproval(store Storage, id string, requester User) (Run, error) {
+	run, err := store.GetRun(id)
+	if err != nil {
+		return Run{}, err
+	}
+	if runStatus(run) != statusValidated {
+		return Run{}, fmt.Errorf("run %s is %s; only validated runs can be sent for approval", id, runStatus(run))
+	}
+
+	run.Approval = &Approval{RequestedBy: requester.Name, RequestedAt: time.Now().UTC()}
+	if err := store.UpdateApproval(id, run.Approval); err != nil {
+		return Run{}, err
+	}
+	store.AppendAudit(AuditEntry{RunID: id, Time: time.Now(), Actor: requester.Name, Action: "run.approval_requested"})
+	return run, nil
+}
+
+func approveRun(store Storage, id string, approver User) (Run, error) {
+	run, err := store.GetRun(id)
+	if err != nil {
+		return Run{}, err
+	}
+	if runStatus(run) != statusValidated {
+		return Run{}, fmt.Errorf("run %s is %s; only validated runs can be approved", id, runStatus(run))
+	}
+	if run.Approval == nil {
+		return Run{}, fmt.Errorf("no approval has been requested for run %s", id)
+	}
+	if approver.Role != roleIC {
+		return Run{}, fmt.Errorf("user %q is not a course IC", approver.Name)
+	}
+	if approver.Name == run.Approval.RequestedBy {
+		return Run{}, fmt.Errorf("run %s must be approved by someone other than the requester", id)
+	}
+
+	run.Approval.ApprovedBy = approver.Name
+	run.Approval.ApprovedAt = time.Now().UTC()
+	if err := store.UpdateApproval(id, run.Approval); err != nil {
+		return Run{}, err
+	}
+	store.AppendAudit(AuditEntry{RunID: id, Time: time.Now(), Actor: approver.Name, Action: "run.approved",
+		Detail: "requested by " + run.Approval.RequestedBy})
+	return run, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	batchJobs int
+	batchOut  string
+
+	// quietParse stops parseExcel printing warnings as it goes, for callers
+	// that parse several files at once and report the issues themselves.
+	quietParse bool
+)
+
+// BatchFile summarises one workbook of a batch in the combined report.
+type BatchFile struct {
+	Path     string  `json:"path"`
+	Report   string  `json:"report,omitempty"`
+	Students int     `json:"students"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Mean     float64 `json:"mean_total"`
+	Partial  bool    `json:"partial,omitempty"`
+	Failure  string  `json:"failure,omitempty"`
+}
+
+type batchResult struct {
+	file     BatchFile
+	set      ComponentSet
+	students []Student
+	issues   []Issue
+}
+
+// batchInputs expands arg into the workbooks to process when it is a
+// directory or a glob. A directory contributes its .xlsx, .xlsm, .xls, .ods
+// and .csv files; Excel lock files (~$name.xlsx) are skipped either way.
+func batchInputs(arg string) ([]string, bool, error) {
+	// URLs can contain "?", which is not a glob there.
+	if isGoogleSheet(arg) || isDownload(arg) || isS3(arg) {
+		return nil, false, nil
+	}
+	var paths []string
+	if strings.ContainsAny(arg, "*?[") {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() && !strings.HasPrefix(filepath.Base(path), "~$") {
+				paths = append(paths, path)
+			}
+		}
+	} else {
+		info, err := os.Stat(arg)
+		if err != nil || !info.IsDir() {
+			return nil, false, nil
+		}
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, true, err
+		}
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".xlsx", ".xlsm", ".xls", ".ods", ".csv", ".tsv":
+				if !e.IsDir() && !strings.HasPrefix(e.Name(), "~$") {
+					paths = append(paths, filepath.Join(arg, e.Name()))
+				}
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, true, fmt.Errorf("no workbooks found in %s", arg)
+	}
+	sort.Strings(paths)
+	return paths, true, nil
+}
+
+// runBatch processes every workbook in paths, several at a time, writes a
+// report per file and a combined report to -batch-out, and prints the
+// combined averages and rankings.
+func runBatch(paths []string) {
+	if _, err := loadConfig(configPath); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(batchOut, 0o755); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	workers := max(batchJobs, 1)
+	if maxWorkbooks > 0 {
+		workers = min(workers, maxWorkbooks)
+	}
+	workers = min(workers, len(paths))
+	fmt.Printf("Processing %d files with %d workers\n", len(paths), workers)
+
+	reports := make([]string, len(paths))
+	used := make(map[string]bool)
+	for i, path := range paths {
+		name := filepath.Base(path)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", filepath.Base(path), n)
+		}
+		used[name] = true
+		reports[i] = filepath.Join(batchOut, name+".json")
+	}
+
+	quietParse = true
+	results := make([]batchResult, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = processBatchFile(paths[i], reports[i])
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	quietParse = false
+
+	fmt.Println("\nFiles:")
+	students := []Student{}
+	var issues []Issue
+	// The combined statistics are over the components of the first
+	// workbook read.
+	var set ComponentSet
+	loaded := false
+	files := make([]BatchFile, len(results))
+	owner := make(map[string]string)
+	for i, r := range results {
+		files[i] = r.file
+		if r.file.Failure != "" && !r.file.Partial {
+			fmt.Printf("%s: FAILED: %s\n", r.file.Path, r.file.Failure)
+			continue
+		}
+		fmt.Printf("%s: %d students | %d errors | %d warnings | Mean total: %s | Report: %s\n", r.file.Path,
+			r.file.Students, r.file.Errors, r.file.Warnings, formatNumber(r.file.Mean), r.file.Report)
+		if r.file.Partial {
+			fmt.Printf("%s: PARTIAL: %s\n", r.file.Path, r.file.Failure)
+		}
+
+		if !loaded {
+			set, loaded = r.set, true
+		}
+		for _, issue := range r.issues {
+			issues = append(issues, issue.inFile(r.file.Path))
+		}
+		for _, s := range r.students {
+			if other, seen := owner[s.EmpID]; seen {
+				issue := studentError("duplicate-empid", s, "Duplicate EmpID %s (%s and %s)", s.EmpID, other, location(r.file.Path, s.Row))
+				issue.Source = r.file.Path
+				issues = append(issues, issue)
+				continue
+			}
+			owner[s.EmpID] = location(r.file.Path, s.Row)
+			students = append(students, s)
+		}
+		if saveRun {
+			saveToStorage(buildManifest([]string{r.file.Path}), r.file.Path, r.set, r.students, r.issues)
+		}
+	}
+
+	fmt.Printf("\nCombined: %d students from %d files\n", len(students), countLoaded(files))
+	if len(students) > 0 {
+		// The issues were collected file by file; the pass is for the
+		// statistics and rankings.
+		stats := startTiming("stats")
+		a := analyze(students, set)
+		rankStudents(students, set, a.Totals)
+		consoleRenderer{}.Render(buildReport(students, a))
+		stats(len(students))
+	}
+
+	combined := filepath.Join(batchOut, "combined.json")
+	data := map[string]interface{}{
+		"schema_version": runSchemaVersion,
+		"course":         courseName,
+		"files":          files,
+		"students":       students,
+		"mismatches":     issues,
+		"manifest":       buildManifest(paths),
+	}
+	if err := writeExport(combined, data); err != nil {
+		fmt.Println("Error writing combined report:", err)
+	} else {
+		fmt.Println("\nCombined report written to", combined)
+	}
+
+	failed := len(paths) - countLoaded(files)
+	if failed > 0 {
+		fmt.Printf("%d of %d files could not be processed\n", failed, len(paths))
+	}
+	printTimings()
+	if shouldFail(failOn, issues) {
+		fmt.Printf("\nFailing (-fail-on %s): %d errors, %d warnings\n", failOn, countIssues(issues, severityError), countIssues(issues, severityWarning))
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func countLoaded(files []BatchFile) int {
+	n := 0
+	for _, f := range files {
+		if f.Failure == "" || f.Partial {
+			n++
+		}
+	}
+	return n
+}
+
+// processBatchFile parses and validates one workbook and writes its report,
+// the same as a single-file run with -export.
+func processBatchFile(path, report string) batchResult {
+	r := batchResult{file: BatchFile{Path: path}}
+	students, layout, issues, err := parseExcel(path)
+	var failure *PartialError
+	if err != nil && !errors.As(err, &failure) {
+		r.file.Failure = err.Error()
+		return r
+	}
+	set := layout.componentSet()
+
+	runStage(&failure, "validation", func() {
+		validated := startTiming("validate")
+		issues = append(issues, applyWaivers(collectMismatches(students, set), waivers)...)
+		validated(len(students))
+	})
+	runStage(&failure, "ranking", func() {
+		ranked := startTiming("rank")
+		defer func() { ranked(len(students)) }()
+		for i := range students {
+			students[i].Total = set.total(students[i])
+		}
+		if err := sortStudents(students, set, sortKeys); err != nil {
+			fmt.Println("Error:", err)
+		}
+	})
+	if failure != nil {
+		issues = append(issues, rowError("partial", failure.Row, "PARTIAL RUN: %v", failure))
+		r.file.Partial = true
+		r.file.Failure = failure.Error()
+	}
+
+	r.file.Report = report
+	if students == nil {
+		students = []Student{}
+	}
+	data := map[string]interface{}{
+		"schema_version": runSchemaVersion,
+		"course":         courseName,
+		"source":         path,
+		"students":       students,
+		"mismatches":     issues,
+		"manifest":       buildManifest([]string{path}),
+	}
+	if len(layout.Items) > 0 {
+		data["items"] = layout.Items
+	}
+	if list := set.exported(); list != nil {
+		data["components"] = list
+	}
+	if failure != nil {
+		data["partial"] = true
+		data["failure"] = failure.Error()
+	}
+	exported := startTiming("export")
+	err = writeExport(r.file.Report, data)
+	exported(len(students))
+	if err != nil {
+		r.file.Report, r.file.Partial = "", false
+		r.file.Failure = err.Error()
+		return r
+	}
+
+	totals := make([]float64, len(students))
+	for i, s := range students {
+		totals[i] = set.total(s)
+	}
+	r.file.Students = len(students)
+	r.file.Errors = countIssues(issues, severityError)
+	r.file.Warnings = countIssues(issues, severityWarning)
+	r.file.Mean = mean(totals)
+	r.set, r.students, r.issues = set, students, issues
+	return r
+}
+
+// writeExport writes data as indented JSON to path, within -max-export-mb,
+// removing the file again if it could not be written in full.
+func writeExport(path string, data interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(limitExport(file))
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type BranchConfig struct {
+	Source string `json:"source"`
+	Column string `json:"column"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Regex  string `json:"regex"`
+}
+
+type branchExtractor struct {
+	source     string
+	column     int
+	start, end int
+	re         *regexp.Regexp
+}
+
+func (c BranchConfig) validate() error {
+	switch c.Source {
+	case "", "campus_id", "column":
+	case "regex":
+		if _, err := regexp.Compile(c.Regex); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown branch source %q (want campus_id, column or regex)", c.Source)
+	}
+	if c.End != 0 && (c.Start < 0 || c.End <= c.Start) {
+		return fmt.Errorf("invalid CampusID slice %d:%d", c.Start, c.End)
+	}
+	return nil
+}
+
+func newBranchExtractor(cfg BranchConfig, layout Layout) branchExtractor {
+	b := branchExtractor{source: cfg.Source, column: layout.Branch, start: 4, end: 6}
+	if b.source == "" {
+		b.source = "campus_id"
+	}
+	if cfg.End != 0 {
+		b.start, b.end = cfg.Start, cfg.End
+	}
+	if cfg.Column != "" {
+		b.column = layout.column(cfg.Column)
+	}
+	if cfg.Source == "regex" {
+		b.re = regexp.MustCompile(cfg.Regex)
+	}
+	return b
+}
+
+func (b branchExtractor) extract(row []string, campusID string) (string, string) {
+	fromCampus := ""
+	if len(campusID) >= b.end {
+		fromCampus = campusID[b.start:b.end]
+	}
+	fromColumn := ""
+	if b.column >= 0 && b.column < len(row) {
+		fromColumn = strings.ToUpper(strings.TrimSpace(row[b.column]))
+	}
+
+	switch b.source {
+	case "column":
+		if fromColumn == "" {
+			if fromCampus == "" {
+				return "", ""
+			}
+			return fromCampus, fmt.Sprintf("branch column empty, using %s from CampusID", fromCampus)
+		}
+		if fromCampus != "" && fromCampus != fromColumn {
+			return fromColumn, fmt.Sprintf("branch column (%s) disagrees with CampusID (%s), using %s", fromColumn, fromCampus, fromColumn)
+		}
+		return fromColumn, ""
+	case "regex":
+		m := b.re.FindStringSubmatch(campusID)
+		if m == nil {
+			if fromCampus == "" {
+				return "", ""
+			}
+			return fromCampus, fmt.Sprintf("branch regex did not match %q, using %s from CampusID", campusID, fromCampus)
+		}
+		branch := m[0]
+		if len(m) > 1 {
+			branch = m[1]
+		}
+		return branch, ""
+	}
+
+	if fromCampus != "" && fromColumn != "" && fromColumn != fromCampus {
+		return fromCampus, fmt.Sprintf("branch column (%s) disagrees with CampusID (%s), using %s", fromColumn, fromCampus, fromCampus)
+	}
+	return fromCampus, ""
+}
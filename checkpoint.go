@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint records how far parsing of a workbook got, so that an
+// interrupted run can pick up after the last saved row with -resume.
+type Checkpoint struct {
+	Source   string    `json:"source"`
+	Sheet    string    `json:"sheet,omitempty"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Row      int       `json:"row"`
+	Students []Student `json:"students"`
+	Warnings []Issue   `json:"warnings"`
+}
+
+func checkpointPath(source string) string {
+	return source + ".checkpoint.json"
+}
+
+func newCheckpoint(source string) (Checkpoint, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{Source: source, Sheet: sheetName, Size: info.Size(), ModTime: info.ModTime().UTC()}, nil
+}
+
+// loadCheckpoint returns the saved checkpoint for source, or ok=false when
+// there is none or the file has changed since it was written.
+func loadCheckpoint(source string) (Checkpoint, bool, error) {
+	data, err := os.ReadFile(checkpointPath(source))
+	if os.IsNotExist(err) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	current, err := newCheckpoint(source)
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	if cp.Size != current.Size || !cp.ModTime.Equal(current.ModTime) {
+		fmt.Println("Warning: checkpoint is stale (source file changed), starting from scratch")
+		return Checkpoint{}, false, nil
+	}
+	if cp.Sheet != current.Sheet {
+		fmt.Printf("Warning: checkpoint is for sheet %q, not %q; starting from scratch\n", cp.Sheet, current.Sheet)
+		return Checkpoint{}, false, nil
+	}
+	internStudents(cp.Students)
+	return cp, true, nil
+}
+
+func (cp Checkpoint) save() error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	path := checkpointPath(cp.Source)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func removeCheckpoint(source string) {
+	if err := os.Remove(checkpointPath(source)); err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error removing checkpoint:", err)
+	}
+}
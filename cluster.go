@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+var clusterComponents = []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Compre"}
+
+type cluster struct {
+	Centroid []float64
+	Members  []int
+}
+
+func clusterVector(s Student, set ComponentSet) []float64 {
+	v := make([]float64, len(clusterComponents))
+	for i, comp := range clusterComponents {
+		v[i] = s.Marks[comp] / set.max(comp)
+	}
+	return v
+}
+
+func kMeans(points [][]float64, k int, rng *rand.Rand) []cluster {
+	k = min(k, len(points))
+	if k == 0 {
+		return nil
+	}
+
+	centroids := [][]float64{append([]float64(nil), points[rng.Intn(len(points))]...)}
+	for len(centroids) < k {
+		weights := make([]float64, len(points))
+		sum := 0.0
+		for i, p := range points {
+			_, d := nearest(p, centroids)
+			weights[i] = d
+			sum += d
+		}
+		r := rng.Float64() * sum
+		next := len(points) - 1
+		for i, w := range weights {
+			if r < w {
+				next = i
+				break
+			}
+			r -= w
+		}
+		centroids = append(centroids, append([]float64(nil), points[next]...))
+	}
+
+	assign := make([]int, len(points))
+	for iter := 0; iter < 100; iter++ {
+		changed := false
+		for i, p := range points {
+			c, _ := nearest(p, centroids)
+			if c != assign[i] {
+				assign[i] = c
+				changed = true
+			}
+		}
+
+		counts := make([]int, k)
+		sums := make([][]float64, k)
+		for c := range sums {
+			sums[c] = make([]float64, len(points[0]))
+		}
+		for i, p := range points {
+			counts[assign[i]]++
+			for d, x := range p {
+				sums[assign[i]][d] += x
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for d := range centroids[c] {
+				centroids[c][d] = sums[c][d] / float64(counts[c])
+			}
+		}
+
+		if !changed && iter > 0 {
+			break
+		}
+	}
+
+	clusters := make([]cluster, k)
+	for c := range clusters {
+		clusters[c].Centroid = centroids[c]
+	}
+	for i, c := range assign {
+		clusters[c].Members = append(clusters[c].Members, i)
+	}
+	return clusters
+}
+
+func nearest(p []float64, centroids [][]float64) (int, float64) {
+	best, bestDist := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		d := 0.0
+		for i := range p {
+			d += (p[i] - centroid[i]) * (p[i] - centroid[i])
+		}
+		if d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best, bestDist
+}
+
+func clusterProfile(centroid []float64) (string, float64, float64) {
+	continuous := (centroid[0] + centroid[1] + centroid[2] + centroid[3]) / 4
+	final := centroid[4]
+
+	switch {
+	case continuous-final >= 0.15:
+		return "strong-continuous/weak-final", continuous, final
+	case final-continuous >= 0.15:
+		return "weak-continuous/strong-final", continuous, final
+	case continuous >= 0.65 && final >= 0.65:
+		return "uniformly strong", continuous, final
+	case continuous < 0.4 && final < 0.4:
+		return "uniformly weak", continuous, final
+	}
+	return "average", continuous, final
+}
+
+func clusterStudents(students []Student, set ComponentSet, k int) {
+	points := make([][]float64, len(students))
+	for i, s := range students {
+		points[i] = clusterVector(s, set)
+	}
+
+	clusters := kMeans(points, k, rand.New(rand.NewSource(clusterSeed)))
+	sort.Slice(clusters, func(i, j int) bool {
+		return len(clusters[i].Members) > len(clusters[j].Members)
+	})
+
+	fmt.Println("\nPerformance Clusters:")
+	branchCounts := make(map[string][]int)
+	for c, cl := range clusters {
+		profile, continuous, final := clusterProfile(cl.Centroid)
+		fmt.Printf("Cluster %d (%s): %d students | Continuous: %.0f%% | Compre: %.0f%%\n",
+			c+1, profile, len(cl.Members), continuous*100, final*100)
+
+		for _, i := range cl.Members {
+			students[i].Cluster = c + 1
+			counts, ok := branchCounts[students[i].Branch]
+			if !ok {
+				counts = make([]int, len(clusters))
+				branchCounts[students[i].Branch] = counts
+			}
+			counts[c]++
+		}
+	}
+
+	branches := make([]string, 0, len(branchCounts))
+	for branch := range branchCounts {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	fmt.Println("\nCluster Sizes per Branch:")
+	for _, branch := range branches {
+		parts := make([]string, len(clusters))
+		for c, n := range branchCounts[branch] {
+			parts[c] = fmt.Sprintf("C%d=%d", c+1, n)
+		}
+		fmt.Printf("Branch %s: %s\n", branch, strings.Join(parts, " "))
+	}
+}
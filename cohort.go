@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// minCohort is the fewest students a group may have for its statistics to
+// appear in a shared report; 0 shows every group. A branch average over two
+// students all but names them, so the summaries that leave the grading
+// team (the -pdf report, -template, -docx and -latex, and the shared
+// summary link) pool smaller branches into one, in their branch rows and
+// beside their students' marks, while the console and the exports that
+// list every student keep full detail.
+var minCohort int
+
+// pooledBranch is the branch small branches are reported under, as in the
+// research export.
+const pooledBranch = "OTHER"
+
+// cohortPools maps each group, by size, to the group it is reported as:
+// itself when it has at least minCohort members, otherwise pooledBranch,
+// or "" when even the pooled groups together are too few and are withheld.
+// The notes say what was pooled or withheld.
+func cohortPools(sizes map[string]int) (map[string]string, []string) {
+	pools := make(map[string]string, len(sizes))
+	var small []string
+	pooled := 0
+	for group, n := range sizes {
+		pools[group] = group
+		if minCohort > 0 && n < minCohort {
+			small = append(small, group)
+			pooled += n
+		}
+	}
+	if len(small) == 0 {
+		return pools, nil
+	}
+	sort.Strings(small)
+	as := pooledBranch
+	note := fmt.Sprintf("Branches with fewer than %d students pooled into %s: %s", minCohort, pooledBranch, strings.Join(small, ", "))
+	if pooled < minCohort {
+		as = ""
+		note = fmt.Sprintf("Branches with fewer than %d students withheld (%d students in all): %s", minCohort, pooled, strings.Join(small, ", "))
+	}
+	for _, group := range small {
+		pools[group] = as
+	}
+	return pools, []string{note}
+}
+
+// pooledBranchStats is branches as a shared report shows them (see
+// cohortPools), the pooled branch last, averaged over all its students.
+func pooledBranchStats(branches []BranchStats) ([]BranchStats, []string) {
+	pools, notes := branchPools(branches)
+	if notes == nil {
+		return branches, nil
+	}
+
+	var shown []BranchStats
+	other := BranchStats{Branch: pooledBranch}
+	for _, b := range branches {
+		switch pools[b.Branch] {
+		case b.Branch:
+			shown = append(shown, b)
+		case pooledBranch:
+			other.AverageTotal += b.AverageTotal * float64(b.Students)
+			for name, v := range b.Means {
+				if other.Means == nil {
+					other.Means = make(map[string]float64)
+				}
+				other.Means[name] += v * float64(b.Students)
+			}
+			other.Students += b.Students
+		}
+	}
+	if other.Students > 0 {
+		other.AverageTotal /= float64(other.Students)
+		for name := range other.Means {
+			other.Means[name] /= float64(other.Students)
+		}
+		shown = append(shown, other)
+	}
+	return shown, notes
+}
+
+// branchPools is cohortPools over branches, by their numbers of students.
+func branchPools(branches []BranchStats) (map[string]string, []string) {
+	sizes := make(map[string]int, len(branches))
+	for _, b := range branches {
+		sizes[b.Branch] = b.Students
+	}
+	return cohortPools(sizes)
+}
+
+// sharedBranch is the branch a shared report gives a student of branch, with
+// pools from branchPools: pooledBranch for a withheld branch too, so that no
+// small branch is named beside its students' marks.
+func sharedBranch(pools map[string]string, branch string) string {
+	if pool := pools[branch]; pool != "" {
+		return pool
+	}
+	return pooledBranch
+}
+
+// sharedStatistics is a run's summary as the shared summary link gives it:
+// small branches pooled, and nothing but the student count for a cohort
+// smaller than minCohort.
+func sharedStatistics(stats RunStats) RunStats {
+	if minCohort > 0 && stats.Students < minCohort {
+		stats.Components = map[string]float64{}
+		stats.Branches = nil
+		stats.Notes = []string{fmt.Sprintf("Statistics withheld: fewer than %d students", minCohort)}
+		return stats
+	}
+	stats.Branches, stats.Notes = pooledBranchStats(stats.Branches)
+	return stats
+}
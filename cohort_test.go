@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLatexPoolsSmallBranchStudents(t *testing.T) {
+	var students []Student
+	for i, branch := range []string{"A7", "A7", "A7", "A7", "A7", "A3", "A3", "A4", "A4", "A4"} {
+		students = append(students, Student{EmpID: fmt.Sprintf("1232022%04d", i), Branch: branch,
+			Marks: map[string]float64{"Quiz": float64(30 - i), "Compre": float64(60 + i)}})
+	}
+	a := analyze(students, ComponentSet{})
+	rankStudents(students, ComponentSet{}, a.Totals)
+	rep := buildReport(students, a)
+
+	for _, tc := range []struct {
+		minCohort int
+		shown     []string
+		hidden    []string
+	}{
+		{5, []string{"A7", pooledBranch}, []string{"A3", "A4"}},
+		{6, nil, []string{"A7", "A3", "A4"}},
+	} {
+		minCohort = tc.minCohort
+		out := filepath.Join(t.TempDir(), "report.tex")
+		if err := writeLatex(Config{}, rep, out); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, branch := range tc.shown {
+			if !strings.Contains(string(data), ","+branch+",") {
+				t.Errorf("-min-cohort %d: branch %s missing", tc.minCohort, branch)
+			}
+		}
+		for _, branch := range tc.hidden {
+			if strings.Contains(string(data), branch) {
+				t.Errorf("-min-cohort %d: small branch %s named in the report", tc.minCohort, branch)
+			}
+		}
+	}
+	minCohort = 5
+}
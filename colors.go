@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func parseColorTags(spec string) (map[string]string, error) {
+	tags := make(map[string]string)
+	if spec == "" {
+		return tags, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		color, tag, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || tag == "" {
+			return nil, fmt.Errorf("invalid color tag %q (want RRGGBB=tag)", part)
+		}
+		tags[normalizeColor(color)] = strings.TrimSpace(tag)
+	}
+	return tags, nil
+}
+
+func normalizeColor(color string) string {
+	color = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if len(color) == 8 {
+		color = color[2:]
+	}
+	return color
+}
+
+func extractColorTags(f *excelize.File, sheet string, rows [][]string, tags map[string]string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	if len(tags) == 0 {
+		return annotations, nil
+	}
+
+	styleTags := make(map[int]string)
+	for i, row := range rows {
+		for j := range row {
+			cell, err := excelize.CoordinatesToCellName(j+1, i+1)
+			if err != nil {
+				return nil, err
+			}
+			styleID, err := f.GetCellStyle(sheet, cell)
+			if err != nil {
+				return nil, err
+			}
+			if styleID == 0 {
+				continue
+			}
+
+			tag, seen := styleTags[styleID]
+			if !seen {
+				style, err := f.GetStyle(styleID)
+				if err != nil {
+					return nil, err
+				}
+				for _, color := range style.Fill.Color {
+					if t, ok := tags[normalizeColor(color)]; ok {
+						tag = t
+						break
+					}
+				}
+				styleTags[styleID] = tag
+			}
+			if tag != "" {
+				annotations[cell] = tag
+			}
+		}
+	}
+	return annotations, nil
+}
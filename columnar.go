@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// MarkTable stores marks column by column, one contiguous slice per
+// component indexed by student position, so aggregations over large
+// sheets scan flat float64 slices instead of per-student maps.
+type MarkTable struct {
+	Names  []string
+	Branch []string
+	index  map[string]int
+	cols   [][]float64
+}
+
+func newMarkTable(names []string, n int) MarkTable {
+	t := MarkTable{Names: names, Branch: make([]string, n), index: make(map[string]int, len(names))}
+	data := make([]float64, len(names)*n)
+	for j, name := range names {
+		t.index[name] = j
+		t.cols = append(t.cols, data[j*n:(j+1)*n:(j+1)*n])
+	}
+	return t
+}
+
+func marksTable(students []Student, set ComponentSet) MarkTable {
+	t := newMarkTable(append(append([]string(nil), set.names()...), "Final Total"), len(students))
+	for i, s := range students {
+		t.Branch[i] = s.Branch
+		for j, name := range t.Names {
+			t.cols[j][i] = s.Marks[name]
+		}
+	}
+	return t
+}
+
+func itemsTable(students []Student, items []ItemColumn) MarkTable {
+	names := make([]string, len(items))
+	for j, item := range items {
+		names[j] = item.Key()
+	}
+	t := newMarkTable(names, len(students))
+	for i, s := range students {
+		t.Branch[i] = s.Branch
+		for j, name := range names {
+			t.cols[j][i] = s.Items[name]
+		}
+	}
+	return t
+}
+
+func (t MarkTable) Len() int {
+	return len(t.Branch)
+}
+
+func (t MarkTable) Column(name string) []float64 {
+	j, ok := t.index[name]
+	if !ok {
+		return make([]float64, t.Len())
+	}
+	return t.cols[j]
+}
+
+// SumColumns adds the named columns element-wise.
+func (t MarkTable) SumColumns(names ...string) []float64 {
+	out := make([]float64, t.Len())
+	for _, name := range names {
+		addInto(out, t.Column(name))
+	}
+	return out
+}
+
+func addInto(dst, src []float64) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] += src[i]
+	}
+}
+
+func sum(values []float64) float64 {
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(values); i += 4 {
+		s0 += values[i]
+		s1 += values[i+1]
+		s2 += values[i+2]
+		s3 += values[i+3]
+	}
+	for ; i < len(values); i++ {
+		s0 += values[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// percentile returns the p-th percentile (0-100) using linear interpolation
+// between closest ranks.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func extractComments(f *excelize.File, sheet string) (map[string]string, error) {
+	list, err := f.GetComments(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make(map[string]string)
+	for _, c := range list {
+		text := c.Text
+		for _, run := range c.Paragraph {
+			text += run.Text
+		}
+		if c.Author != "" {
+			text = strings.TrimPrefix(text, c.Author+":")
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			comments[strings.ToUpper(c.Cell)] = text
+		}
+	}
+	return comments, nil
+}
+
+func lookupCells(cells map[string]string, layout Layout, row int) map[string]string {
+	columns := map[string]int{"EmpID": layout.EmpID, "CampusID": layout.CampusID, "Final Total": layout.Total}
+	for comp, col := range layout.Columns {
+		columns[comp] = col
+	}
+
+	var found map[string]string
+	for name, col := range columns {
+		cell, err := excelize.CoordinatesToCellName(col+1, row)
+		if err != nil {
+			continue
+		}
+		if text, ok := cells[cell]; ok {
+			if found == nil {
+				found = make(map[string]string)
+			}
+			found[name] = text
+		}
+	}
+	return found
+}
+
+func printCellNotes(title string, students []Student, notes func(Student) map[string]string) {
+	var lines []string
+	for _, student := range students {
+		for name, text := range notes(student) {
+			lines = append(lines, fmt.Sprintf("EmpID %s | %s: %s", maskID(student.EmpID), name, text))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	sort.Strings(lines)
+	fmt.Printf("\n%s:\n", title)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
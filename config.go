@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Config struct {
+	Courses map[string]CourseConfig `json:"courses,omitempty"`
+	Grades  []GradeBoundary         `json:"grades,omitempty"`
+	Storage StorageConfig           `json:"storage,omitzero"`
+	Users   []User                  `json:"users,omitempty"`
+	Notify  NotifyConfig            `json:"notify,omitzero"`
+	Branch  BranchConfig            `json:"branch,omitzero"`
+	Tenants []Tenant                `json:"tenants,omitempty"`
+	Keys    []SigningKey            `json:"signing_keys,omitempty"`
+	Auth    AuthConfig              `json:"auth,omitzero"`
+	BaseURL string                  `json:"base_url,omitempty"`
+	Columns []ComputedColumn        `json:"computed_columns,omitempty"`
+	Roster  RosterConfig            `json:"roster,omitzero"`
+	Mapping *ColumnMapping          `json:"mapping,omitempty"`
+	Ignore  []string                `json:"ignore_columns,omitempty"`
+	Weights map[string]float64      `json:"weights,omitempty"`
+	Export  ExportConfig            `json:"export,omitzero"`
+	Quotas  []GradeQuota            `json:"grade_quotas,omitempty"`
+	S3      S3Config                `json:"s3,omitzero"`
+	// Strict makes -strict the default for a course's profile.
+	Strict bool `json:"strict,omitempty"`
+	// Alerts mark a run as needing attention (see evaluateAlerts).
+	Alerts []AlertRule `json:"alerts,omitempty"`
+}
+
+type ParseOptions struct {
+	Branch  BranchConfig
+	Mapping *ColumnMapping
+	// Ignore names columns to read as blank (see ignoredColumns).
+	Ignore []string
+	// Discover picks components from the header instead of the standard
+	// layout (see discoverLayout).
+	Discover bool
+	// Strict checks the sheet against its layout before reading any
+	// student, failing with every problem found (see checkSchema).
+	Strict bool
+	// Context, when set, cancels parsing and receives progress.
+	Context context.Context
+
+	// StartRow skips every row up to and including it, for resumed runs.
+	StartRow int
+	// Progress, when set, is called every ProgressEvery data rows with the
+	// last row handled and everything parsed so far.
+	Progress      func(row int, students []Student, warnings []Issue)
+	ProgressEvery int
+}
+
+func parseOptions(cfg Config) ParseOptions {
+	return ParseOptions{Branch: cfg.Branch, Mapping: cfg.Mapping, Ignore: cfg.Ignore, Strict: cfg.Strict}
+}
+
+type CourseConfig struct {
+	Credits  float64 `json:"credits"`
+	MaxTotal float64 `json:"max_total"`
+	// PassPct is the lowest percentage of MaxTotal that counts as a pass in
+	// statistics; 40 (a D) when unset.
+	PassPct float64 `json:"pass_pct,omitempty"`
+}
+
+type GradeBoundary struct {
+	Grade  string  `json:"grade"`
+	MinPct float64 `json:"min_pct"`
+	Points float64 `json:"points"`
+}
+
+var defaultGrades = []GradeBoundary{
+	{"A", 80, 10}, {"A-", 70, 9}, {"B", 60, 8}, {"B-", 55, 7},
+	{"C", 50, 6}, {"C-", 45, 5}, {"D", 40, 4}, {"E", 0, 2},
+}
+
+func loadConfig(path string) (Config, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Branch.validate(); err != nil {
+		return cfg, fmt.Errorf("invalid branch config: %w", err)
+	}
+
+	if len(cfg.Weights) > 0 {
+		cfg.Columns = append(cfg.Columns, weightedColumn(cfg.Weights))
+	}
+	if _, err := compileColumns(cfg.Columns); err != nil {
+		return cfg, err
+	}
+
+	if mappingPath != "" {
+		m, err := loadMapping(mappingPath)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Mapping = m
+	}
+	for _, spec := range strings.Split(ignoreColumns, ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			cfg.Ignore = append(cfg.Ignore, spec)
+		}
+	}
+	if cfg.Mapping != nil {
+		if _, err := cfg.Mapping.layout(); err != nil {
+			return cfg, fmt.Errorf("invalid column mapping: %w", err)
+		}
+	}
+
+	if len(cfg.Grades) == 0 {
+		cfg.Grades = defaultGrades
+	}
+	if err := validateGradeQuotas(cfg.Quotas, cfg.Grades); err != nil {
+		return cfg, err
+	}
+	for _, rule := range cfg.Alerts {
+		if err := rule.validate(); err != nil {
+			return cfg, fmt.Errorf("invalid alerts config: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads path as-is, without filling in defaults, so that it
+// can be written back unchanged apart from the edited fields.
+func readConfigFile(path string) (Config, error) {
+	cfg := Config{Courses: make(map[string]CourseConfig)}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func writeConfigFile(path string, cfg Config) error {
+	if path == "" {
+		return fmt.Errorf("no config file given (use -config)")
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (c Config) course(name string) CourseConfig {
+	course := c.Courses[name]
+	if course.MaxTotal == 0 {
+		course.MaxTotal = 300
+	}
+	if course.PassPct == 0 {
+		course.PassPct = 40
+	}
+	return course
+}
+
+func (c Config) grade(pct float64) GradeBoundary {
+	best := GradeBoundary{Grade: "NC"}
+	for _, g := range c.Grades {
+		if pct >= g.MinPct && (best.Grade == "NC" || g.MinPct > best.MinPct) {
+			best = g
+		}
+	}
+	return best
+}
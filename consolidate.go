@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type semesterRecord struct {
+	EmpID   string
+	Branch  string
+	Credits float64
+	Points  float64
+	Grades  map[string]string
+	// PctSum and ZSum are the course percentages, and the course totals
+	// as standard scores within their course, each times its credits.
+	PctSum float64
+	ZSum   float64
+}
+
+func (r semesterRecord) SGPA() float64 {
+	if r.Credits == 0 {
+		return 0
+	}
+	return r.Points / r.Credits
+}
+
+// WeightedPct is the student's percentage across their courses, each
+// counted by its credits.
+func (r semesterRecord) WeightedPct() float64 {
+	if r.Credits == 0 {
+		return 0
+	}
+	return r.PctSum / r.Credits
+}
+
+// WeightedZ is how far the student stands above or below each course's
+// class, in standard deviations, counted by credits, so that a hard course
+// weighs no less than an easy one.
+func (r semesterRecord) WeightedZ() float64 {
+	if r.Credits == 0 {
+		return 0
+	}
+	return r.ZSum / r.Credits
+}
+
+func runConsolidate(args []string) {
+	fs := flag.NewFlagSet("consolidate", flag.ExitOnError)
+	deansList := fs.Float64("deans-list", 0, "List the top percentage of students by credit-weighted percentage, e.g. 10 (0 disables)")
+	minCredits := fs.Float64("min-credits", 0, "Fewest credits a student needs for the dean's list")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . [-config file] consolidate [-deans-list 10] [-min-credits 15] <run.json>...")
+		return
+	}
+	if *deansList < 0 || *deansList > 100 {
+		fmt.Printf("Error: invalid -deans-list %s (want 0-100)\n", formatNumber(*deansList))
+		return
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	records := make(map[string]*semesterRecord)
+	for _, path := range fs.Args() {
+		run, err := loadExportedRun(path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+
+		course := cfg.course(run.Course)
+		if course.Credits == 0 {
+			fmt.Printf("Warning: No credits configured for course %s, skipping %s\n", run.Course, path)
+			continue
+		}
+
+		totals := make([]float64, len(run.Students))
+		for i, student := range run.Students {
+			totals[i] = student.Total
+		}
+		classMean, classSD := mean(totals), stdDev(totals)
+		for _, student := range run.Students {
+			rec, ok := records[student.EmpID]
+			if !ok {
+				rec = &semesterRecord{EmpID: student.EmpID, Branch: student.Branch, Grades: make(map[string]string)}
+				records[student.EmpID] = rec
+			}
+			pct := student.Total / course.MaxTotal * 100
+			g := cfg.grade(pct)
+			rec.Credits += course.Credits
+			rec.Points += course.Credits * g.Points
+			rec.Grades[run.Course] = g.Grade
+			rec.PctSum += course.Credits * pct
+			if classSD > 0 {
+				rec.ZSum += course.Credits * (student.Total - classMean) / classSD
+			}
+		}
+	}
+
+	printSemesterReport(records)
+	if *deansList > 0 {
+		printDeansList(records, *deansList, *minCredits)
+	}
+}
+
+func loadExportedRun(path string) (Run, error) {
+	var run Run
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("invalid run export %s: %w", path, err)
+	}
+	if err := migrateRun(&run); err != nil {
+		return run, fmt.Errorf("%s: %w", path, err)
+	}
+	internStudents(run.Students)
+	if run.Course == "" {
+		run.Course = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return run, nil
+}
+
+func printSemesterReport(records map[string]*semesterRecord) {
+	var list []*semesterRecord
+	for _, rec := range records {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].SGPA() != list[j].SGPA() {
+			return list[i].SGPA() > list[j].SGPA()
+		}
+		return list[i].EmpID < list[j].EmpID
+	})
+
+	fmt.Println("\nSemester GPA per Student:")
+	for _, rec := range list {
+		courses := make([]string, 0, len(rec.Grades))
+		for course, grade := range rec.Grades {
+			courses = append(courses, course+"="+grade)
+		}
+		sort.Strings(courses)
+		fmt.Printf("EmpID: %s | Branch: %s | Credits: %.0f | SGPA: %s | Weighted %%: %s | Weighted z: %s | %s\n",
+			maskID(rec.EmpID), rec.Branch, rec.Credits, formatNumber(rec.SGPA()), formatNumber(rec.WeightedPct()), formatNumber(rec.WeightedZ()), strings.Join(courses, ", "))
+	}
+
+	branchSGPA := make(map[string][]float64)
+	branchPct := make(map[string][]float64)
+	branchZ := make(map[string][]float64)
+	for _, rec := range list {
+		branchSGPA[rec.Branch] = append(branchSGPA[rec.Branch], rec.SGPA())
+		branchPct[rec.Branch] = append(branchPct[rec.Branch], rec.WeightedPct())
+		branchZ[rec.Branch] = append(branchZ[rec.Branch], rec.WeightedZ())
+	}
+	branches := make([]string, 0, len(branchSGPA))
+	for branch := range branchSGPA {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+
+	fmt.Println("\nBranch-wise Semester Report:")
+	for _, branch := range branches {
+		values := branchSGPA[branch]
+		sum, lo, hi := 0.0, values[0], values[0]
+		for _, v := range values {
+			sum += v
+			lo = min(lo, v)
+			hi = max(hi, v)
+		}
+		fmt.Printf("Branch %s: %d students | Mean SGPA: %s | Min: %s | Max: %s | Mean weighted %%: %s | Mean weighted z: %s\n",
+			branch, len(values), formatNumber(sum/float64(len(values))), formatNumber(lo), formatNumber(hi),
+			formatNumber(mean(branchPct[branch])), formatNumber(mean(branchZ[branch])))
+	}
+}
+
+// printDeansList lists the top pct percent of the students with at least
+// minCredits, by credit-weighted percentage. Students tied with the last
+// one listed are listed too.
+func printDeansList(records map[string]*semesterRecord, pct, minCredits float64) {
+	var eligible []*semesterRecord
+	for _, rec := range records {
+		if rec.Credits >= minCredits {
+			eligible = append(eligible, rec)
+		}
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].WeightedPct() != eligible[j].WeightedPct() {
+			return eligible[i].WeightedPct() > eligible[j].WeightedPct()
+		}
+		return eligible[i].EmpID < eligible[j].EmpID
+	})
+	n := int(math.Ceil(float64(len(eligible)) * pct / 100))
+	for n > 0 && n < len(eligible) && eligible[n].WeightedPct() == eligible[n-1].WeightedPct() {
+		n++
+	}
+
+	fmt.Printf("\nDean's List (top %s%% of %d students with at least %.0f credits):\n", formatNumber(pct), len(eligible), minCredits)
+	for i, rec := range eligible[:n] {
+		fmt.Printf("%d. EmpID: %s | Branch: %s | Credits: %.0f | Weighted %%: %s | SGPA: %s\n",
+			i+1, maskID(rec.EmpID), rec.Branch, rec.Credits, formatNumber(rec.WeightedPct()), formatNumber(rec.SGPA()))
+	}
+}
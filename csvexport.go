@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+var exportCSV bool
+
+// csvRenderer writes the report as two CSV files, for faculty who work
+// with the results in a spreadsheet: students, a row per student with their
+// marks, computed total and rank, and mismatches, a row per issue. Marks
+// are written in full, as in the sheet, rather than rounded to -decimals.
+type csvRenderer struct {
+	students, mismatches string
+}
+
+func (c csvRenderer) Render(rep *Report) error {
+	if err := writeCSV(c.students, studentRows(rep)); err != nil {
+		return fmt.Errorf("writing CSV data: %w", err)
+	}
+	if err := writeCSV(c.mismatches, issueRows(rep.Issues)); err != nil {
+		return fmt.Errorf("writing CSV data: %w", err)
+	}
+	fmt.Printf("Data exported to %s and %s\n", c.students, c.mismatches)
+	return nil
+}
+
+// studentRows lays out the report's students for the CSV export, in their
+// ranking order.
+func studentRows(rep *Report) [][]string {
+	header := append([]string{"EmpID", "Campus ID", "Branch", "Class No."}, rep.Marks...)
+	rows := [][]string{append(append(header, "Computed Total", "Rank"), rep.Extra...)}
+	for i, s := range rep.Students {
+		row := []string{s.EmpID, s.CampusID, s.Branch, s.ClassNo}
+		for _, name := range rep.Marks {
+			row = append(row, formatMark(s.Marks[name]))
+		}
+		row = append(row, formatMark(s.Total), strconv.Itoa(rep.Ranks[i]))
+		for _, name := range rep.Extra {
+			row = append(row, s.Extra[name])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// competitionRanks ranks totals from the highest, equal totals sharing a
+// rank (1, 2, 2, 4).
+func competitionRanks(totals []float64) []int {
+	ascending := slices.Clone(totals)
+	slices.Sort(ascending)
+	ranks := make([]int, len(totals))
+	for i, t := range totals {
+		higher := len(ascending) - sort.Search(len(ascending), func(j int) bool { return ascending[j] > t })
+		ranks[i] = higher + 1
+	}
+	return ranks
+}
+
+// markNames are the marks exported for each student: the components, and
+// the sheet's Final Total when it has one.
+func markNames(students []Student, set ComponentSet) []string {
+	names := append([]string(nil), set.names()...)
+	for _, s := range students {
+		if _, ok := s.Marks["Final Total"]; ok {
+			return append(names, "Final Total")
+		}
+	}
+	return names
+}
+
+func issueRows(issues []Issue) [][]string {
+	rows := [][]string{{"Severity", "Rule", "EmpID", "Row", "Column", "Message", "Waiver"}}
+	for _, issue := range issues {
+		row := ""
+		if issue.Row > 0 {
+			row = strconv.Itoa(issue.Row)
+		}
+		rows = append(rows, []string{issue.Severity, issue.Rule, issue.EmpID, row, issue.Column, issue.Message, issue.Waiver})
+	}
+	return rows
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	inputFormat  string
+	csvDelimiter string
+)
+
+func validateInputFormat(format string) error {
+	switch format {
+	case "auto", "xlsx", "xls", "csv", "tsv", "ods":
+		return nil
+	}
+	return fmt.Errorf("invalid -format %q (want auto, xlsx, xls, csv, tsv or ods)", format)
+}
+
+// validateDelimiter checks -delimiter: a single character, or "tab".
+func validateDelimiter(delim string) error {
+	_, err := delimiterRune(delim)
+	return err
+}
+
+func delimiterRune(delim string) (rune, error) {
+	switch {
+	case delim == "":
+		return 0, nil
+	case strings.EqualFold(delim, "tab") || delim == `\t`:
+		return '\t', nil
+	}
+	r := []rune(delim)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		return 0, fmt.Errorf("invalid -delimiter %q (want a single character, or tab)", delim)
+	}
+	return r[0], nil
+}
+
+// isCSV reports whether path should be read as CSV, or as TSV, which is read
+// the same way: with -format auto that is decided by the extension.
+func isCSV(path string) bool {
+	if inputFormat != "auto" {
+		return inputFormat == "csv" || inputFormat == "tsv"
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv", ".txt":
+		return true
+	}
+	return false
+}
+
+// isTSV reports whether a CSV input is tab-separated by default: with
+// -format tsv, or with -format auto for .tsv and .txt files, as LMS exports
+// are named.
+func isTSV(path string) bool {
+	if inputFormat != "auto" {
+		return inputFormat == "tsv"
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".txt":
+		return true
+	}
+	return false
+}
+
+// loadCSVRows reads a sheet exported as CSV or TSV. The delimiter is
+// -delimiter when given, a tab for TSV, and otherwise a comma unless the
+// first line has more semicolons, as written by spreadsheet programs in
+// locales that use a decimal comma. Comments, colours and hidden rows do not
+// survive CSV export, so only the rows are returned.
+func loadCSVRows(ctx context.Context, path string) (Sheet, error) {
+	if sheetName != "" {
+		return Sheet{}, fmt.Errorf("-sheet does not apply to CSV input %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Error opening the file:", err)
+		return Sheet{}, err
+	}
+	return parseCSV(ctx, data, path)
+}
+
+// parseCSV splits CSV data read from source (a path or URL, for messages)
+// into rows.
+func parseCSV(ctx context.Context, data []byte, source string) (Sheet, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	comma, _ := delimiterRune(csvDelimiter)
+	switch {
+	case comma != 0:
+		r.Comma = comma
+	case isTSV(source):
+		r.Comma = '\t'
+	default:
+		if line, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n'); strings.Count(line, ";") > strings.Count(line, ",") {
+			r.Comma = ';'
+		}
+	}
+
+	var rows [][]string
+	used := 0
+	for {
+		if len(rows)%jobStepRows == 0 {
+			if err := jobStep(ctx, "read", len(rows)); err != nil {
+				return Sheet{}, err
+			}
+		}
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return Sheet{}, fmt.Errorf("invalid %s %s: %w", csvKind(r.Comma), source, err)
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		for len(record) > 0 && record[len(record)-1] == "" {
+			record = record[:len(record)-1]
+		}
+		rows = append(rows, record)
+		if len(record) > 0 {
+			used = len(rows)
+		}
+		if err := checkStudentLimit(len(rows)); err != nil {
+			return Sheet{}, err
+		}
+	}
+	return Sheet{Rows: rows[:used]}, nil
+}
+
+func csvKind(comma rune) string {
+	if comma == '\t' {
+		return "TSV"
+	}
+	return "CSV"
+}
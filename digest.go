@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type MarkChange struct {
+	EmpID     string  `json:"emp_id"`
+	Component string  `json:"component"`
+	Old       float64 `json:"old"`
+	New       float64 `json:"new"`
+}
+
+type RankMove struct {
+	EmpID   string `json:"emp_id"`
+	OldRank int    `json:"old_rank"`
+	NewRank int    `json:"new_rank"`
+}
+
+type Digest struct {
+	Course         string       `json:"course"`
+	PreviousRunID  string       `json:"previous_run_id"`
+	RunID          string       `json:"run_id"`
+	MarksChanged   []MarkChange `json:"marks_changed"`
+	Added          []string     `json:"students_added"`
+	Removed        []string     `json:"students_removed"`
+	NewErrors      []string     `json:"new_errors"`
+	ResolvedErrors []string     `json:"resolved_errors"`
+	RankMoves      []RankMove   `json:"rank_moves"`
+}
+
+func diffRuns(old, new Run) Digest {
+	d := Digest{Course: new.Course, PreviousRunID: old.ID, RunID: new.ID}
+
+	oldByID := make(map[string]Student)
+	for _, s := range old.Students {
+		oldByID[s.EmpID] = s
+	}
+	newByID := make(map[string]Student)
+	for _, s := range new.Students {
+		newByID[s.EmpID] = s
+		prev, ok := oldByID[s.EmpID]
+		if !ok {
+			d.Added = append(d.Added, s.EmpID)
+			continue
+		}
+		for _, comp := range append(new.componentSet().names(), "Final Total") {
+			if prev.Marks[comp] != s.Marks[comp] {
+				d.MarksChanged = append(d.MarksChanged, MarkChange{EmpID: s.EmpID, Component: comp, Old: prev.Marks[comp], New: s.Marks[comp]})
+			}
+		}
+	}
+	for _, s := range old.Students {
+		if _, ok := newByID[s.EmpID]; !ok {
+			d.Removed = append(d.Removed, s.EmpID)
+		}
+	}
+
+	d.NewErrors = stringsNotIn(issueMessages(unwaived(new.Mismatches)), issueMessages(unwaived(old.Mismatches)))
+	d.ResolvedErrors = stringsNotIn(issueMessages(unwaived(old.Mismatches)), issueMessages(unwaived(new.Mismatches)))
+
+	oldRanks, newRanks := rankPositions(old.Students, old.componentSet()), rankPositions(new.Students, new.componentSet())
+	for id, rank := range newRanks {
+		if prev, ok := oldRanks[id]; ok && prev != rank {
+			d.RankMoves = append(d.RankMoves, RankMove{EmpID: id, OldRank: prev, NewRank: rank})
+		}
+	}
+	sort.Slice(d.RankMoves, func(i, j int) bool {
+		a, b := d.RankMoves[i], d.RankMoves[j]
+		da, db := math.Abs(float64(a.OldRank-a.NewRank)), math.Abs(float64(b.OldRank-b.NewRank))
+		if da != db {
+			return da > db
+		}
+		return a.EmpID < b.EmpID
+	})
+	if len(d.RankMoves) > 10 {
+		d.RankMoves = d.RankMoves[:10]
+	}
+
+	return d
+}
+
+func rankPositions(students []Student, set ComponentSet) map[string]int {
+	sorted := append([]Student(nil), students...)
+	sort.SliceStable(sorted, func(i, j int) bool { return set.total(sorted[i]) > set.total(sorted[j]) })
+	ranks := make(map[string]int)
+	for i, s := range sorted {
+		ranks[s.EmpID] = i + 1
+	}
+	return ranks
+}
+
+func stringsNotIn(list, other []string) []string {
+	seen := make(map[string]bool)
+	for _, s := range other {
+		seen[s] = true
+	}
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (d Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "What changed in %s (run %s, previous %s):\n", d.Course, d.RunID, d.PreviousRunID)
+	fmt.Fprintf(&b, "- %d marks changed, %d students added, %d removed\n", len(d.MarksChanged), len(d.Added), len(d.Removed))
+	fmt.Fprintf(&b, "- %d new validation errors, %d resolved\n", len(d.NewErrors), len(d.ResolvedErrors))
+	for i, c := range d.MarksChanged {
+		if i == 10 {
+			fmt.Fprintf(&b, "  ... and %d more mark changes\n", len(d.MarksChanged)-10)
+			break
+		}
+		fmt.Fprintf(&b, "  EmpID %s %s: %s -> %s\n", c.EmpID, c.Component, formatNumber(c.Old), formatNumber(c.New))
+	}
+	for _, e := range d.NewErrors {
+		fmt.Fprintf(&b, "  New: %s\n", e)
+	}
+	for _, m := range d.RankMoves {
+		fmt.Fprintf(&b, "  EmpID %s rank %d -> %d\n", m.EmpID, m.OldRank, m.NewRank)
+	}
+	return b.String()
+}
+
+func previousRun(store Storage, run Run) (Run, bool) {
+	if run.Course == "" {
+		return Run{}, false
+	}
+	runs, err := store.ListRuns()
+	if err != nil {
+		return Run{}, false
+	}
+
+	var latest *Run
+	for i := range runs {
+		r := &runs[i]
+		if r.ID == run.ID || r.Course != run.Course || r.CreatedAt.After(run.CreatedAt) {
+			continue
+		}
+		if latest == nil || r.CreatedAt.After(latest.CreatedAt) {
+			latest = r
+		}
+	}
+	if latest == nil {
+		return Run{}, false
+	}
+
+	prev, err := store.GetRun(latest.ID)
+	return prev, err == nil
+}
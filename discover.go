@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// discoverLayout treats every mostly-numeric column after the ID columns as a
+// component named by its header, for ad-hoc sheets that do not follow the
+// standard Quiz/Mid-Sem/.../Compre layout. A "Total" column is still used as
+// the sheet's own total.
+func discoverLayout(rows [][]string) (Layout, bool) {
+	var best headerMatch
+	depth := 0
+	for d := 1; d <= maxHeaderRows && d < len(rows); d++ {
+		m := matchHeader(rows[:d])
+		if len(m.roles) > len(best.roles) {
+			best, depth = m, d
+		}
+	}
+	empCol, okEmp := best.roles["EmpID"]
+	campusCol, okCampus := best.roles["CampusID"]
+	if !okEmp || !okCampus {
+		return Layout{}, false
+	}
+
+	layout := Layout{HeaderRows: depth, ClassNo: -1, EmpID: empCol, CampusID: campusCol, Branch: -1, Total: -1,
+		Columns: make(map[string]int), Max: make(map[string]float64), Headers: best.names}
+	if col, ok := best.roles["Class No."]; ok {
+		layout.ClassNo = col
+	}
+	if col, ok := best.roles["Branch"]; ok {
+		layout.Branch = col
+	}
+	firstData := max(layout.ClassNo, layout.EmpID, layout.CampusID, layout.Branch) + 1
+
+	for col := firstData; col < len(best.names); col++ {
+		header := best.names[col]
+		if headerRole(header) == "Total" {
+			layout.Total = col
+			continue
+		}
+		observed, ok := numericColumn(rows[depth:], col)
+		if !ok {
+			continue
+		}
+
+		name := headerRole(header)
+		if name == "" {
+			name = strings.TrimSpace(maxMarksPattern.ReplaceAllString(header, ""))
+		}
+		letter, _ := excelize.ColumnNumberToName(col + 1)
+		if name == "" {
+			name = letter
+		}
+		if _, taken := layout.Columns[name]; taken {
+			name += " (" + letter + ")"
+		}
+		name = intern(name)
+
+		layout.Columns[name] = col
+		layout.Components = append(layout.Components, name)
+		layout.Max[name] = headerMax(header, name, observed)
+	}
+	return layout, len(layout.Components) > 0
+}
+
+// numericColumn reports whether at least 80% of the non-empty cells in col
+// are marks, and the largest mark seen.
+func numericColumn(rows [][]string, col int) (float64, bool) {
+	filled, numeric := 0, 0
+	highest := 0.0
+	for _, row := range rows {
+		if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+			continue
+		}
+		filled++
+		if mark, err := parseMark(row[col]); err == nil {
+			numeric++
+			highest = max(highest, mark)
+		}
+	}
+	return highest, numeric > 0 && numeric*5 >= filled*4
+}
+
+// headerMax takes a component's maximum from a "(30)" in its header, the
+// standard maximum for known components, or else the highest mark seen.
+func headerMax(header, name string, observed float64) float64 {
+	if m := maxMarksPattern.FindString(header); m != "" {
+		if v, err := strconv.ParseFloat(strings.Trim(m, "() "), 64); err == nil && v > 0 {
+			return v
+		}
+	}
+	if v, ok := componentMax[name]; ok {
+		return v
+	}
+	return observed
+}
+
+func (l Layout) components() []string {
+	return l.componentSet().names()
+}
+
+// componentSet is the components the layout's sheet is marked in.
+func (l Layout) componentSet() ComponentSet {
+	return ComponentSet{Names: l.Components, Max: l.Max}
+}
+
+// ComponentSet is the components of one parsed sheet with their maximum
+// marks. The zero value is the standard set; a discovered or mapped layout
+// has its own. It travels with the students it was read with, from the
+// Layout to the analysis, the Report and a stored Run, rather than through
+// the package's standard lists, so sheets parsed at once cannot disturb one
+// another.
+type ComponentSet struct {
+	Names []string
+	Max   map[string]float64
+}
+
+func (c ComponentSet) custom() bool {
+	return c.Names != nil
+}
+
+// names are the set's components, capped so that appending to them copies.
+func (c ComponentSet) names() []string {
+	names := components
+	if c.custom() {
+		names = c.Names
+	}
+	return names[:len(names):len(names)]
+}
+
+// max is a component's maximum mark, from the standard maximums for one the
+// set does not give.
+func (c ComponentSet) max(name string) float64 {
+	if v, ok := c.Max[name]; ok {
+		return v
+	}
+	return componentMax[name]
+}
+
+// totalParts are the components summed into a student's total: everything
+// but the Pre-Compre subtotal.
+func (c ComponentSet) totalParts() []string {
+	if !c.custom() {
+		return totalParts
+	}
+	var names []string
+	for _, comp := range c.Names {
+		if comp != "Pre-Compre" {
+			names = append(names, comp)
+		}
+	}
+	return names
+}
+
+func (c ComponentSet) total(s Student) float64 {
+	total := 0.0
+	for _, comp := range c.totalParts() {
+		total += s.Marks[comp]
+	}
+	return total
+}
+
+// exported lists a custom set for an export or a stored run, which restore
+// it with componentSetOf; the standard set is left out.
+func (c ComponentSet) exported() []exportedComponent {
+	if !c.custom() {
+		return nil
+	}
+	list := make([]exportedComponent, len(c.Names))
+	for i, name := range c.Names {
+		list[i] = exportedComponent{name, c.max(name)}
+	}
+	return list
+}
+
+func componentSetOf(list []exportedComponent) ComponentSet {
+	if len(list) == 0 {
+		return ComponentSet{}
+	}
+	c := ComponentSet{Max: make(map[string]float64, len(list))}
+	for _, e := range list {
+		c.Names = append(c.Names, e.Name)
+		c.Max[e.Name] = e.Max
+	}
+	return c
+}
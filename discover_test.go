@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDiscoveredComponentsStayWithTheirSheet(t *testing.T) {
+	sheets := []Sheet{
+		{Rows: [][]string{
+			{"EmpID", "CampusID", "Essay (20)", "Viva (10)"},
+			{"12320220001", "2022A7PS0001G", "15", "8"},
+		}},
+		{Rows: [][]string{
+			{"EmpID", "CampusID", "Project (50)"},
+			{"12320220001", "2022A7PS0001G", "45"},
+		}},
+	}
+	want := []struct {
+		names []string
+		total float64
+	}{
+		{[]string{"Essay", "Viva"}, 23},
+		{[]string{"Project"}, 45},
+	}
+
+	var wg sync.WaitGroup
+	for i, sheet := range sheets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				students, layout, _, err := parseRows(sheet, ParseOptions{Discover: true})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				set := layout.componentSet()
+				if !slices.Equal(set.names(), want[i].names) {
+					t.Errorf("sheet %d: components %v, want %v", i, set.names(), want[i].names)
+					return
+				}
+				a := analyze(students, set)
+				if len(a.Mismatches) > 0 || a.Totals[0] != want[i].total {
+					t.Errorf("sheet %d: total %v with issues %v, want %v", i, a.Totals[0], a.Mismatches, want[i].total)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !slices.Equal(components, []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Pre-Compre", "Compre"}) || componentMax["Compre"] != 105 {
+		t.Errorf("standard components changed: %v %v", components, componentMax)
+	}
+}
+
+func TestResearchColumnsFollowDiscoveredComponents(t *testing.T) {
+	sheet := Sheet{Rows: [][]string{
+		{"EmpID", "CampusID", "Essay (20)", "Viva (10)"},
+		{"12320220001", "2022A7PS0001G", "15", "8"},
+	}}
+	students, layout, _, err := parseRows(sheet, ParseOptions{Discover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := layout.componentSet()
+	students[0].Total = set.total(students[0])
+
+	dir := t.TempDir()
+	cols := researchColumns(set, students)
+	records, _ := anonymize(students, []byte("salt"), 1)
+	if err := writeResearchCSV(filepath.Join(dir, "research.csv"), cols, records); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "research.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !strings.HasPrefix(lines[0], "pseudo_id,branch,admit_year,essay,viva,") || !strings.HasSuffix(lines[0], ",computed_total") {
+		t.Errorf("header %q, want the discovered components", lines[0])
+	}
+	if !strings.Contains(lines[1], ",15,8,") || !strings.HasSuffix(lines[1], ",23") {
+		t.Errorf("row %q, want marks 15 and 8 totalling 23", lines[1])
+	}
+	if cols[3].Description != "Essay marks (max 20)" {
+		t.Errorf("essay described as %q", cols[3].Description)
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	disputeOpen     = "open"
+	disputeReviewed = "reviewed"
+	disputeUpheld   = "upheld"
+	disputeRejected = "rejected"
+)
+
+// Only a course IC may uphold or reject a dispute; anyone may open one or
+// mark it reviewed.
+var disputeTransitions = map[string][]string{
+	disputeOpen:     {disputeReviewed, disputeUpheld, disputeRejected},
+	disputeReviewed: {disputeUpheld, disputeRejected},
+}
+
+// Dispute is a student's appeal against one component mark of a run. Runs
+// cannot be edited once published, so an upheld dispute is settled by
+// importing a corrected run and linking it as a Correction.
+type Dispute struct {
+	ID          string       `json:"id"`
+	RunID       string       `json:"run_id"`
+	Tenant      string       `json:"tenant,omitempty"`
+	EmpID       string       `json:"emp_id"`
+	Component   string       `json:"component"`
+	Mark        float64      `json:"mark"`
+	Claimed     *float64     `json:"claimed,omitempty"`
+	Reason      string       `json:"reason"`
+	Status      string       `json:"status"`
+	OpenedBy    string       `json:"opened_by"`
+	OpenedAt    time.Time    `json:"opened_at"`
+	UpdatedBy   string       `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time    `json:"updated_at,omitempty"`
+	Note        string       `json:"note,omitempty"`
+	Corrections []Correction `json:"corrections,omitempty"`
+}
+
+// Correction links a dispute to the run that carries the corrected mark.
+type Correction struct {
+	RunID    string    `json:"run_id"`
+	Mark     float64   `json:"mark"`
+	LinkedBy string    `json:"linked_by"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+func (d Dispute) resolved() bool {
+	return d.Status == disputeUpheld || d.Status == disputeRejected
+}
+
+// DisputeSummary counts a run's disputes by status and lists the ones still
+// waiting for a decision.
+type DisputeSummary struct {
+	Open       int       `json:"open"`
+	Reviewed   int       `json:"reviewed"`
+	Upheld     int       `json:"upheld"`
+	Rejected   int       `json:"rejected"`
+	Unresolved []Dispute `json:"unresolved,omitempty"`
+}
+
+func summarizeDisputes(disputes []Dispute) DisputeSummary {
+	var sum DisputeSummary
+	for _, d := range disputes {
+		switch d.Status {
+		case disputeOpen:
+			sum.Open++
+		case disputeReviewed:
+			sum.Reviewed++
+		case disputeUpheld:
+			sum.Upheld++
+		case disputeRejected:
+			sum.Rejected++
+		}
+		if !d.resolved() {
+			sum.Unresolved = append(sum.Unresolved, d)
+		}
+	}
+	return sum
+}
+
+func studentMark(run Run, empID, component string) (Student, string, bool) {
+	for _, s := range run.Students {
+		if s.EmpID != empID {
+			continue
+		}
+		for name := range s.Marks {
+			if normalizeHeader(name) == normalizeHeader(component) {
+				return s, name, true
+			}
+		}
+		return s, "", false
+	}
+	return Student{}, "", false
+}
+
+func openDispute(store Storage, runID, empID, component, reason string, claimed *float64, actor string) (Dispute, error) {
+	run, err := store.GetRun(runID)
+	if err != nil {
+		return Dispute{}, err
+	}
+	s, name, ok := studentMark(run, empID, component)
+	if s.EmpID == "" {
+		return Dispute{}, fmt.Errorf("%w: student %s is not in run %s", errNotFound, empID, runID)
+	}
+	if !ok {
+		return Dispute{}, fmt.Errorf("run %s has no component %q", runID, component)
+	}
+	if reason == "" {
+		return Dispute{}, fmt.Errorf("a dispute needs a reason")
+	}
+
+	existing, err := store.ListDisputes(runID)
+	if err != nil {
+		return Dispute{}, err
+	}
+	for _, d := range existing {
+		if d.EmpID == empID && d.Component == name && !d.resolved() {
+			return Dispute{}, fmt.Errorf("student %s already has dispute %s on %s", empID, d.ID, name)
+		}
+	}
+
+	d := Dispute{ID: randomHex(6), RunID: runID, EmpID: empID, Component: name, Mark: s.Marks[name], Claimed: claimed,
+		Reason: reason, Status: disputeOpen, OpenedBy: actor, OpenedAt: time.Now().UTC()}
+	if err := store.SaveDispute(d); err != nil {
+		return Dispute{}, err
+	}
+	store.AppendAudit(AuditEntry{RunID: runID, Time: time.Now(), Actor: actor, Action: "dispute.opened",
+		Detail: d.ID + ": " + empID + " " + name})
+	return d, nil
+}
+
+func updateDispute(store Storage, id, to, note string, user User) (Dispute, error) {
+	d, err := store.GetDispute(id)
+	if err != nil {
+		return Dispute{}, err
+	}
+	allowed := false
+	for _, next := range disputeTransitions[d.Status] {
+		if next == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return Dispute{}, fmt.Errorf("cannot move dispute %s from %s to %s", id, d.Status, to)
+	}
+	if (to == disputeUpheld || to == disputeRejected) && user.Role != roleIC {
+		return Dispute{}, fmt.Errorf("user %q is not a course IC", user.Name)
+	}
+
+	from := d.Status
+	d.Status, d.UpdatedBy, d.UpdatedAt = to, user.Name, time.Now().UTC()
+	if note != "" {
+		d.Note = note
+	}
+	if err := store.SaveDispute(d); err != nil {
+		return Dispute{}, err
+	}
+	store.AppendAudit(AuditEntry{RunID: d.RunID, Time: time.Now(), Actor: user.Name, Action: "dispute." + to,
+		Detail: d.ID + ": " + from + " -> " + to})
+	return d, nil
+}
+
+// linkCorrection records that correctedRun carries the outcome of an upheld
+// dispute, taking the corrected mark from that run.
+func linkCorrection(store Storage, id, correctedRun, actor string) (Dispute, error) {
+	d, err := store.GetDispute(id)
+	if err != nil {
+		return Dispute{}, err
+	}
+	if d.Status != disputeUpheld {
+		return Dispute{}, fmt.Errorf("dispute %s is %s; only upheld disputes take corrections", id, d.Status)
+	}
+	if correctedRun == d.RunID {
+		return Dispute{}, fmt.Errorf("the correction must be a different run from the disputed one")
+	}
+	run, err := store.GetRun(correctedRun)
+	if err != nil {
+		return Dispute{}, err
+	}
+	s, name, ok := studentMark(run, d.EmpID, d.Component)
+	if !ok {
+		return Dispute{}, fmt.Errorf("run %s has no %s mark for student %s", correctedRun, d.Component, d.EmpID)
+	}
+
+	d.Corrections = append(d.Corrections, Correction{RunID: correctedRun, Mark: s.Marks[name], LinkedBy: actor, LinkedAt: time.Now().UTC()})
+	if err := store.SaveDispute(d); err != nil {
+		return Dispute{}, err
+	}
+	store.AppendAudit(AuditEntry{RunID: d.RunID, Time: time.Now(), Actor: actor, Action: "dispute.corrected",
+		Detail: fmt.Sprintf("%s: %s %s -> %s in run %s", d.ID, d.Component, formatNumber(d.Mark), formatNumber(s.Marks[name]), correctedRun)})
+	return d, nil
+}
+
+func filterDisputes(disputes []Dispute, status string) []Dispute {
+	if status == "" {
+		return disputes
+	}
+	var out []Dispute
+	for _, d := range disputes {
+		if d.Status == status {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (d Dispute) line() string {
+	claim := ""
+	if d.Claimed != nil {
+		claim = " (claims " + formatNumber(*d.Claimed) + ")"
+	}
+	return fmt.Sprintf("%s | %s | run %s | EmpID: %s | %s: %s%s | %s", d.ID, d.Status, d.RunID, d.EmpID, d.Component, formatNumber(d.Mark), claim, d.Reason)
+}
+
+func printDisputeSummary(sum DisputeSummary) {
+	fmt.Printf("Disputes: %d open, %d reviewed, %d upheld, %d rejected\n", sum.Open, sum.Reviewed, sum.Upheld, sum.Rejected)
+	for _, d := range sum.Unresolved {
+		fmt.Println("  " + d.line())
+	}
+}
+
+func runDisputes(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: go run . [-user name] disputes open -component <name> -reason <text> [-claimed mark] <run> <empid> |")
+		fmt.Println("       list [-status open] [run] | show <id> | review|uphold|reject [-note text] <id> | link <id> <corrected-run>")
+		return
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		fmt.Println("Error opening storage:", err)
+		return
+	}
+	defer store.Close()
+	user := cfg.user(userName)
+	store = scopeStorage(store, user.Tenant)
+
+	fs := flag.NewFlagSet("disputes "+args[0], flag.ExitOnError)
+	component := fs.String("component", "", "Component whose mark is disputed")
+	reason := fs.String("reason", "", "Why the student disputes the mark")
+	claimed := fs.Float64("claimed", -1, "Mark the student claims (optional)")
+	status := fs.String("status", "", "Only list disputes with this status")
+	note := fs.String("note", "", "Reviewer's note")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "open":
+		if fs.NArg() < 2 || *component == "" {
+			fmt.Println("Usage: go run . disputes open -component <name> -reason <text> [-claimed mark] <run> <empid>")
+			return
+		}
+		var claim *float64
+		if *claimed >= 0 {
+			claim = claimed
+		}
+		d, err := openDispute(store, fs.Arg(0), fs.Arg(1), *component, *reason, claim, userName)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Dispute %s opened\n", d.ID)
+	case "list":
+		disputes, err := store.ListDisputes(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		for _, d := range filterDisputes(disputes, *status) {
+			fmt.Println(d.line())
+		}
+	case "show":
+		d, err := store.GetDispute(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(d.line())
+		fmt.Printf("Opened by %s at %s\n", d.OpenedBy, d.OpenedAt.Format("2006-01-02 15:04:05"))
+		if d.UpdatedBy != "" {
+			fmt.Printf("Updated by %s at %s\n", d.UpdatedBy, d.UpdatedAt.Format("2006-01-02 15:04:05"))
+		}
+		if d.Note != "" {
+			fmt.Println("Note:", d.Note)
+		}
+		for _, c := range d.Corrections {
+			fmt.Printf("Corrected in run %s: %s -> %s (linked by %s)\n", c.RunID, formatNumber(d.Mark), formatNumber(c.Mark), c.LinkedBy)
+		}
+	case "review", "uphold", "reject":
+		to := map[string]string{"review": disputeReviewed, "uphold": disputeUpheld, "reject": disputeRejected}[args[0]]
+		d, err := updateDispute(store, fs.Arg(0), to, *note, user)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Dispute %s is now %s\n", d.ID, d.Status)
+	case "link":
+		if fs.NArg() < 2 {
+			fmt.Println("Usage: go run . disputes link <id> <corrected-run>")
+			return
+		}
+		d, err := linkCorrection(store, fs.Arg(0), fs.Arg(1), userName)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		c := d.Corrections[len(d.Corrections)-1]
+		fmt.Printf("Dispute %s linked to run %s (%s: %s -> %s)\n", d.ID, c.RunID, d.Component, formatNumber(d.Mark), formatNumber(c.Mark))
+	default:
+		fmt.Println("Unknown disputes command:", args[0])
+	}
+}
+
+func (s *server) openDispute(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		EmpID     string   `json:"emp_id"`
+		Component string   `json:"component"`
+		Reason    string   `json:"reason"`
+		Claimed   *float64 `json:"claimed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	d, err := openDispute(store, r.PathValue("id"), req.EmpID, req.Component, req.Reason, req.Claimed, user.Name)
+	if err != nil {
+		writeDisputeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, d)
+}
+
+func (s *server) listDisputes(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	if _, err := store.GetRun(r.PathValue("id")); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	disputes, err := store.ListDisputes(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, filterDisputes(disputes, r.URL.Query().Get("status")))
+}
+
+func (s *server) getDispute(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	d, err := store.GetDispute(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (s *server) updateDispute(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	d, err := updateDispute(store, r.PathValue("id"), req.Status, req.Note, user)
+	if err != nil {
+		writeDisputeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (s *server) linkCorrection(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RunID string `json:"run_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	d, err := linkCorrection(store, r.PathValue("id"), req.RunID, user.Name)
+	if err != nil {
+		writeDisputeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func writeDisputeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusConflict, err.Error())
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var errSkipped = errors.New("skipped")
+
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	exportDir := fs.String("export-dir", ".", "Directory exports and report cards are written to")
+	minFree := fs.Int("min-free-mb", 100, "Minimum free disk space in the export directory")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for network checks")
+	fs.Parse(args)
+
+	cfg, cfgErr := loadConfig(configPath)
+	checks := []doctorCheck{
+		{"config", func() (string, error) {
+			if cfgErr != nil {
+				return "", cfgErr
+			}
+			if configPath == "" {
+				return "no -config given, using defaults", nil
+			}
+			return fmt.Sprintf("%s (%d users, %d tenants)", configPath, len(cfg.Users), len(cfg.Tenants)), nil
+		}},
+		{"storage", func() (string, error) {
+			if cfgErr != nil {
+				return "config is invalid", errSkipped
+			}
+			return checkStorage(cfg.Storage)
+		}},
+		{"smtp", func() (string, error) {
+			if cfgErr != nil || cfg.Notify.Email.Host == "" {
+				return "no notify.email.host configured", errSkipped
+			}
+			return checkSMTP(cfg.Notify.Email, *timeout)
+		}},
+		{"disk", func() (string, error) {
+			return checkDisk(*exportDir, uint64(*minFree)<<20)
+		}},
+	}
+
+	failed := 0
+	for _, c := range checks {
+		detail, err := c.run()
+		switch {
+		case errors.Is(err, errSkipped):
+			if detail == "" {
+				detail = err.Error()
+			}
+			fmt.Printf("SKIP  %-8s %s\n", c.name, detail)
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL  %-8s %v\n", c.name, err)
+		default:
+			fmt.Printf("PASS  %-8s %s\n", c.name, detail)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(checks))
+		os.Exit(1)
+	}
+}
+
+// checkStorage opens the configured backend and lists runs, which exercises
+// both connectivity and credentials.
+func checkStorage(cfg StorageConfig) (string, error) {
+	store, err := openStorage(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+	runs, err := store.ListRuns()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s, %d runs", storageName(cfg), len(runs)), nil
+}
+
+func checkSMTP(cfg EmailConfig, timeout time.Duration) (string, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return "", fmt.Errorf("starttls: %w", err)
+		}
+	}
+	detail := addr + " reachable"
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return "", fmt.Errorf("login as %s: %w", cfg.Username, err)
+		}
+		detail += ", logged in as " + cfg.Username
+	}
+	c.Quit()
+	return detail, nil
+}
+
+func checkDisk(dir string, minFree uint64) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	free, err := freeSpace(abs)
+	if err != nil {
+		return "", err
+	}
+	if free < minFree {
+		return "", fmt.Errorf("%s has %d MB free, want at least %d MB", abs, free>>20, minFree>>20)
+	}
+	return fmt.Sprintf("%s has %d MB free", abs, free>>20), nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+func freeSpace(dir string) (uint64, error) {
+	return 0, fmt.Errorf("free space check not supported on this platform: %w", errSkipped)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	docxOut      string
+	docxTemplate string
+
+	wordParagraph = regexp.MustCompile(`(?s)<w:p[ >].*?</w:p>`)
+	wordRow       = regexp.MustCompile(`(?s)<w:tr[ >].*?</w:tr>`)
+	wordText      = regexp.MustCompile(`<w:t(?: [^>]*)?>([^<]*)</w:t>`)
+	xmlEscaper    = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// fillDocx writes the summary report as a Word document. The template takes
+// the same placeholders as -template, in its body, headers and footers; a
+// table row holding student, branch, component, top or issue placeholders
+// is repeated like a template row in a workbook. Without a template a plain
+// document with the console summary is written.
+func fillDocx(cfg Config, rep *Report, template, out string) error {
+	var data []byte
+	if template == "" {
+		data = defaultDocx()
+	} else {
+		var err error
+		if data, err = os.ReadFile(template); err != nil {
+			return err
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%s is not a .docx file: %w", template, err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	d := newTemplateData(cfg, rep)
+	for _, zf := range zr.File {
+		if !isWordPart(zf.Name) {
+			if err := zw.Copy(zf); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		filled, err := d.fillWordXML(string(content))
+		if err != nil {
+			return fmt.Errorf("%s: %w", zf.Name, err)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: zf.Name, Method: zip.Deflate, Modified: zf.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, filled); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0o644)
+}
+
+func isWordPart(name string) bool {
+	if name == "word/document.xml" {
+		return true
+	}
+	base := path.Base(name)
+	return path.Dir(name) == "word" && path.Ext(base) == ".xml" &&
+		(strings.HasPrefix(base, "header") || strings.HasPrefix(base, "footer"))
+}
+
+func (d templateData) fillWordXML(doc string) (string, error) {
+	doc = wordParagraph.ReplaceAllStringFunc(doc, joinPlaceholderRuns)
+
+	var b strings.Builder
+	last := 0
+	for _, m := range wordRow.FindAllStringIndex(doc, -1) {
+		row := doc[m[0]:m[1]]
+		kind, err := rowKind([]string{wordTextOf(row)})
+		if err != nil {
+			return "", err
+		}
+		if kind == "" {
+			continue
+		}
+		b.WriteString(doc[last:m[0]])
+		for k := 0; k < d.count(kind); k++ {
+			filled, err := d.fillWordText(row, k)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(filled)
+		}
+		last = m[1]
+	}
+	b.WriteString(doc[last:])
+	return d.fillWordText(b.String(), -1)
+}
+
+func wordTextOf(xml string) string {
+	var b strings.Builder
+	for _, m := range wordText.FindAllStringSubmatch(xml, -1) {
+		b.WriteString(m[1])
+	}
+	return b.String()
+}
+
+// joinPlaceholderRuns moves a paragraph's text into its first run when Word
+// has split a placeholder across runs (it does so at spelling marks and
+// formatting changes), at the cost of that paragraph's mixed formatting.
+func joinPlaceholderRuns(p string) string {
+	texts := wordText.FindAllStringSubmatch(p, -1)
+	joined := wordTextOf(p)
+	whole := 0
+	for _, t := range texts {
+		whole += len(placeholderPattern.FindAllString(t[1], -1))
+	}
+	if whole == len(placeholderPattern.FindAllString(joined, -1)) {
+		return p
+	}
+	first := true
+	return wordText.ReplaceAllStringFunc(p, func(string) string {
+		if first {
+			first = false
+			return `<w:t xml:space="preserve">` + joined + `</w:t>`
+		}
+		return "<w:t></w:t>"
+	})
+}
+
+func (d templateData) fillWordText(xml string, k int) (string, error) {
+	var err error
+	filled := wordText.ReplaceAllStringFunc(xml, func(t string) string {
+		text := wordText.FindStringSubmatch(t)[1]
+		if err != nil || !strings.Contains(text, "{{") {
+			return t
+		}
+		var s string
+		s, err = d.fillText(text, k, xmlEscaper.Replace)
+		return `<w:t xml:space="preserve">` + s + `</w:t>`
+	})
+	return filled, err
+}
+
+// defaultDocx is the document written without -docx-template: the summary
+// shown on the console, as tables.
+func defaultDocx() []byte {
+	var body strings.Builder
+	heading := func(text string) {
+		fmt.Fprintf(&body, `<w:p><w:pPr><w:spacing w:before="240"/></w:pPr><w:r><w:rPr><w:b/><w:sz w:val="28"/></w:rPr><w:t xml:space="preserve">%s</w:t></w:r></w:p>`, text)
+	}
+	para := func(text string) {
+		fmt.Fprintf(&body, `<w:p><w:r><w:t xml:space="preserve">%s</w:t></w:r></w:p>`, text)
+	}
+	table := func(headers, cells []string) {
+		body.WriteString(`<w:tbl><w:tblPr><w:tblW w:w="0" w:type="auto"/><w:tblBorders>`)
+		for _, side := range []string{"top", "left", "bottom", "right", "insideH", "insideV"} {
+			fmt.Fprintf(&body, `<w:%s w:val="single" w:sz="4" w:space="0" w:color="auto"/>`, side)
+		}
+		body.WriteString(`</w:tblBorders></w:tblPr>`)
+		for i, row := range [][]string{headers, cells} {
+			body.WriteString(`<w:tr>`)
+			for _, text := range row {
+				bold := ""
+				if i == 0 {
+					bold = `<w:rPr><w:b/></w:rPr>`
+				}
+				fmt.Fprintf(&body, `<w:tc><w:p><w:r>%s<w:t xml:space="preserve">%s</w:t></w:r></w:p></w:tc>`, bold, text)
+			}
+			body.WriteString(`</w:tr>`)
+		}
+		body.WriteString(`</w:tbl>`)
+	}
+
+	fmt.Fprintf(&body, `<w:p><w:r><w:rPr><w:b/><w:sz w:val="36"/></w:rPr><w:t>Summary Report</w:t></w:r></w:p>`)
+	para("Course: {{course}}")
+	para("Date: {{date}}")
+	para("Students: {{students}} | Validation errors: {{errors}} | Warnings: {{warnings}}")
+	heading("Average Marks per Component")
+	table([]string{"Component", "Mean", "Median", "Min", "Max"},
+		[]string{"{{component.Name}}", "{{component.Mean}}", "{{component.Median}}", "{{component.Min}}", "{{component.Max}}"})
+	heading("Branch-wise Averages")
+	table([]string{"Branch", "Students", "Average Total"}, []string{"{{branch.Name}}", "{{branch.Students}}", "{{branch.Total}}"})
+	heading("Top 3 Students")
+	table([]string{"Rank", "EmpID", "Branch", "Total", "Grade"},
+		[]string{"{{top.Rank}}", "{{top.EmpID}}", "{{top.Branch}}", "{{top.Total}}", "{{top.Grade}}"})
+	heading("Validation Errors")
+	table([]string{"Row", "EmpID", "Issue"}, []string{"{{issue.Row}}", "{{issue.EmpID}}", "{{issue.Message}}"})
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+			`</Relationships>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			body.String() + `<w:sectPr/></w:body></w:document>`},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, p := range parts {
+		w, _ := zw.Create(p.name)
+		io.WriteString(w, p.content)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
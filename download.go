@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+var (
+	downloadToken  string
+	downloadClient = &http.Client{Timeout: 5 * time.Minute}
+)
+
+// isDownload reports whether arg is the URL of a file to download before
+// reading. Google Sheets URLs are read live instead (see loadGoogleSheet).
+func isDownload(arg string) bool {
+	u, err := url.Parse(arg)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && !isGoogleSheet(arg)
+}
+
+// downloadInput fetches rawURL, with -download-token as a bearer token when
+// set, to a temporary file that is read like any other input. The format
+// is taken from the file name the server gives, or the URL's, and failing
+// both from the content.
+func downloadInput(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if downloadToken != "" {
+		req.Header.Set("Authorization", "Bearer "+downloadToken)
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		hint := ""
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			hint = " (pass -download-token if the server needs one)"
+		}
+		return "", fmt.Errorf("downloading %s: %s%s", rawURL, resp.Status, hint)
+	}
+
+	ext := path.Ext(req.URL.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		ext = path.Ext(params["filename"])
+	}
+	return copyInput(resp.Body, rawURL, ext)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ComputedColumn is an extra per-student value defined in config, e.g.
+// {"name": "ContinuousPct", "expr": "PreCompre/195*100"}. Expressions use
+// + - * / and parentheses, the functions min, max, abs and round, and names
+// of components (punctuation ignored), Total, Final Total, item scores and
+// earlier computed columns.
+type ComputedColumn struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+type expr interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberExpr float64
+
+type varExpr string
+
+type unaryExpr struct {
+	x expr
+}
+
+type binaryExpr struct {
+	op   byte
+	x, y expr
+}
+
+type callExpr struct {
+	fn   string
+	args []expr
+}
+
+func (e numberExpr) eval(map[string]float64) (float64, error) { return float64(e), nil }
+
+func (e varExpr) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(e)]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %q", string(e))
+	}
+	return v, nil
+}
+
+func (e unaryExpr) eval(vars map[string]float64) (float64, error) {
+	v, err := e.x.eval(vars)
+	return -v, err
+}
+
+func (e binaryExpr) eval(vars map[string]float64) (float64, error) {
+	x, err := e.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	y, err := e.y.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch e.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	}
+	if y == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return x / y, nil
+}
+
+func (e callExpr) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(e.args))
+	for i, a := range e.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	switch e.fn {
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "round":
+		if len(args) == 1 {
+			return math.Round(args[0]), nil
+		}
+		p := math.Pow(10, math.Round(args[1]))
+		return math.Round(args[0]*p) / p, nil
+	case "min":
+		return math.Min(args[0], args[1]), nil
+	default:
+		return math.Max(args[0], args[1]), nil
+	}
+}
+
+var exprFuncs = map[string][2]int{"abs": {1, 1}, "round": {1, 2}, "min": {2, 2}, "max": {2, 2}}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func parseExpr(src string) (expr, error) {
+	p := &exprParser{src: src}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos:], p.pos)
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) sum() (expr, error) {
+	x, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.src[p.pos]
+		p.pos++
+		var y expr
+		if y, err = p.product(); err == nil {
+			x = binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) product() (expr, error) {
+	x, err := p.unary()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.src[p.pos]
+		p.pos++
+		var y expr
+		if y, err = p.unary(); err == nil {
+			x = binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) unary() (expr, error) {
+	if p.peek() == '-' {
+		p.pos++
+		x, err := p.unary()
+		return unaryExpr{x: x}, err
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		p.pos++
+		return x, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return numberExpr(v), nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.peek() != '(' {
+			return varExpr(normalizeHeader(name)), nil
+		}
+		return p.call(strings.ToLower(name))
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", string(c), p.pos)
+}
+
+func (p *exprParser) call(fn string) (expr, error) {
+	arity, ok := exprFuncs[fn]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", fn)
+	}
+	p.pos++
+	var args []expr
+	for p.peek() != ')' {
+		if len(args) > 0 {
+			if p.peek() != ',' {
+				return nil, fmt.Errorf("expected , or ) at offset %d", p.pos)
+			}
+			p.pos++
+		}
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++
+	if len(args) < arity[0] || len(args) > arity[1] {
+		return nil, fmt.Errorf("%s takes %d to %d arguments", fn, arity[0], arity[1])
+	}
+	return callExpr{fn: fn, args: args}, nil
+}
+
+func compileColumns(cols []ComputedColumn) ([]expr, error) {
+	exprs := make([]expr, len(cols))
+	for i, c := range cols {
+		if c.Name == "" {
+			return nil, fmt.Errorf("computed column %d has no name", i+1)
+		}
+		e, err := parseExpr(c.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("computed column %s: %w", c.Name, err)
+		}
+		exprs[i] = e
+	}
+	return exprs, nil
+}
+
+// exprNames calls fn with each name e refers to.
+func exprNames(e expr, fn func(string)) {
+	switch e := e.(type) {
+	case varExpr:
+		fn(string(e))
+	case unaryExpr:
+		exprNames(e.x, fn)
+	case binaryExpr:
+		exprNames(e.x, fn)
+		exprNames(e.y, fn)
+	case callExpr:
+		for _, a := range e.args {
+			exprNames(a, fn)
+		}
+	}
+}
+
+// checkColumnNames reports the first computed column that refers to a
+// name outside known, the normalized names of the marks and items, or an
+// earlier computed column. A misspelt name fails the run once, rather than
+// warning for every student.
+func checkColumnNames(cols []ComputedColumn, exprs []expr, known map[string]bool) error {
+	known = maps.Clone(known)
+	for i, c := range cols {
+		var err error
+		exprNames(exprs[i], func(name string) {
+			if err == nil && !known[name] {
+				err = fmt.Errorf("computed column %s: unknown name %q (want a component, item, Total or earlier computed column)", c.Name, name)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		known[normalizeHeader(c.Name)] = true
+	}
+	return nil
+}
+
+// studentVars returns the names an expression can use for s: components,
+// item scores, Total and any computed columns already evaluated.
+func studentVars(s Student, set ComponentSet) map[string]float64 {
+	vars := make(map[string]float64, len(s.Marks)+len(s.Items)+len(s.Computed)+1)
+	for name, v := range s.Marks {
+		vars[normalizeHeader(name)] = v
+	}
+	for name, v := range s.Items {
+		vars[normalizeHeader(name)] = v
+	}
+	vars["total"] = set.total(s)
+	for name, v := range s.Computed {
+		vars[normalizeHeader(name)] = v
+	}
+	return vars
+}
+
+// applyComputedColumns evaluates cols for every student in order, so later
+// columns may refer to earlier ones. Students for whom a column cannot be
+// evaluated get a warning and no value for it; a column naming something
+// the sheet does not have is an error.
+func applyComputedColumns(students []Student, set ComponentSet, items []ItemColumn, cols []ComputedColumn) error {
+	if len(cols) == 0 || len(students) == 0 {
+		return nil
+	}
+	exprs, err := compileColumns(cols)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{"total": true}
+	for _, name := range set.names() {
+		known[normalizeHeader(name)] = true
+	}
+	for _, item := range items {
+		known[normalizeHeader(item.Key())] = true
+	}
+	for _, s := range students {
+		for name := range s.Marks {
+			known[normalizeHeader(name)] = true
+		}
+	}
+	if err := checkColumnNames(cols, exprs, known); err != nil {
+		return err
+	}
+
+	for i := range students {
+		s := &students[i]
+		vars := studentVars(*s, set)
+		s.Computed = make(map[string]float64, len(cols))
+		for j, c := range cols {
+			v, err := exprs[j].eval(vars)
+			if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+				err = fmt.Errorf("result is not a finite number")
+			}
+			if err != nil {
+				fmt.Printf("Warning: Row %d: computed column %s: %v\n", s.Row, c.Name, err)
+				continue
+			}
+			s.Computed[c.Name] = v
+			vars[normalizeHeader(c.Name)] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	vars := map[string]float64{"quiz": 20, "compre": 60, "midsem": 45}
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"-quiz + 5", -15},
+		{"--quiz", 20},
+		{"2 * -3", -6},
+		{"-(quiz - compre) / 4", 10},
+		{"Quiz + MidSem", 65},
+		{"max(quiz, compre) - min(quiz, compre)", 40},
+		{"round(compre / 7, 2)", 8.57},
+		{"round(-2.5)", -3},
+		{"abs(quiz - compre)", 40},
+	}
+	for _, tt := range tests {
+		e, err := parseExpr(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got, err := e.eval(vars); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"abs()", "abs takes 1 to 1 arguments"},
+		{"abs(quiz, compre)", "abs takes 1 to 1 arguments"},
+		{"max(quiz)", "max takes 2 to 2 arguments"},
+		{"round(quiz, 1, 2)", "round takes 1 to 2 arguments"},
+		{"sqrt(quiz)", "unknown function sqrt"},
+		{"(quiz + 1", "missing )"},
+		{"quiz +", "unexpected end of expression"},
+		{"quiz compre", "unexpected"},
+	}
+	for _, tt := range tests {
+		_, err := parseExpr(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestComputedColumnUnknownName(t *testing.T) {
+	students := []Student{
+		{Row: 2, Marks: map[string]float64{"Quiz": 20, "Compre": 60, "Final Total": 80}, Items: map[string]float64{"Compre Q1": 7}},
+	}
+	items := []ItemColumn{{Exam: "Compre", Name: "Q1"}}
+	cols := []ComputedColumn{
+		{Name: "Best", Expr: "max(Quiz, Compre)"},
+		{Name: "Scaled", Expr: "Best / 2 + CompreQ1 + FinalTotal - Total"},
+	}
+	if err := applyComputedColumns(students, ComponentSet{}, items, cols); err != nil {
+		t.Fatal(err)
+	}
+	if got := students[0].Computed["Scaled"]; got != 37 {
+		t.Errorf("Scaled = %v, want 37", got)
+	}
+
+	for _, c := range []ComputedColumn{
+		{Name: "Typo", Expr: "Quizz * 2"},
+		{Name: "Later", Expr: "Later2 + 1"},
+	} {
+		err := applyComputedColumns(students, ComponentSet{}, items, append(cols, c, ComputedColumn{Name: "Later2", Expr: "1"}))
+		if err == nil || !strings.Contains(err.Error(), "computed column "+c.Name+": unknown name") {
+			t.Errorf("%s: error %v, want an unknown name", c.Expr, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type CellChange struct {
+	Sheet string
+	Cell  string
+	Old   string
+	New   string
+	Rule  string
+}
+
+func runFix(args []string) {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "Write the changes to a copy of the workbook instead of only listing them")
+	out := fs.String("o", "", "Path of the fixed copy (default <name>.fixed.xlsx)")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . fix [-apply] [-o fixed.xlsx] <path-to-excel-file>")
+		return
+	}
+	path := fs.Arg(0)
+	if isCSV(path) || isODS(path) || isXLS(path) || isGoogleSheet(path) {
+		fmt.Println("Error: fix writes a corrected workbook and needs an .xlsx file, not CSV, ODS, .xls or Google Sheets")
+		return
+	}
+
+	students, layout, _, err := parseExcel(path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	f, err := openWorkbook(path)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		return
+	}
+	defer closeWorkbook(f)
+
+	sheet, err := selectSheet(f)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	changes, err := planFixes(f, sheet, students, layout)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to fix.")
+		return
+	}
+
+	fmt.Printf("%-12s %-6s %12s %12s  %s\n", "Sheet", "Cell", "Old", "New", "Rule")
+	for _, c := range changes {
+		fmt.Printf("%-12s %-6s %12s %12s  %s\n", c.Sheet, c.Cell, c.Old, c.New, c.Rule)
+	}
+	if !*apply {
+		fmt.Printf("\n%d cells would change. Rerun with -apply to write a fixed copy.\n", len(changes))
+		return
+	}
+
+	for _, c := range changes {
+		v, _ := strconv.ParseFloat(c.New, 64)
+		if err := f.SetCellValue(c.Sheet, c.Cell, v); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	target := *out
+	if target == "" {
+		target = strings.TrimSuffix(path, filepath.Ext(path)) + ".fixed.xlsx"
+	}
+	if err := f.SaveAs(target); err != nil {
+		fmt.Println("Error saving fixed copy:", err)
+		return
+	}
+	fmt.Printf("\nWrote %d changed cells to %s (%s is unchanged)\n", len(changes), target, path)
+}
+
+// planFixes recomputes the derived Pre-Compre and Final Total cells for
+// students failing the sum checks, skipping anything that has been waived.
+// Differences below fixTolerance are float noise, not wrong cells.
+const fixTolerance = 1e-6
+
+func planFixes(f *excelize.File, sheet string, students []Student, layout Layout) ([]CellChange, error) {
+	waived := make(map[[2]string]bool, len(waivers))
+	for _, w := range waivers {
+		waived[[2]string{w.EmpID, w.Rule}] = true
+	}
+
+	var changes []CellChange
+	change := func(s Student, col int, value float64, rule string) error {
+		cell, err := excelize.CoordinatesToCellName(col+1, s.Row)
+		if err != nil {
+			return err
+		}
+		old, err := f.GetCellValue(sheet, cell)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, CellChange{Sheet: sheet, Cell: cell, Old: old, New: strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64), Rule: rule})
+		return nil
+	}
+
+	for _, s := range students {
+		if s.Row <= 0 {
+			continue
+		}
+		preCompre := s.Marks["Pre-Compre"]
+		expectedI := s.Marks["Quiz"] + s.Marks["Mid-Sem"] + s.Marks["Lab Test"] + s.Marks["Weekly Labs"]
+		if math.Abs(expectedI-preCompre) > fixTolerance && !waived[[2]string{s.EmpID, "pre-compre-sum"}] {
+			if err := change(s, layout.Columns["Pre-Compre"], expectedI, "pre-compre-sum"); err != nil {
+				return nil, err
+			}
+			preCompre = expectedI
+		}
+
+		expectedTotal := preCompre + s.Marks["Compre"]
+		if math.Abs(expectedTotal-s.Marks["Final Total"]) > fixTolerance && !waived[[2]string{s.EmpID, "total-sum"}] {
+			if err := change(s, layout.Total, expectedTotal, "total-sum"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	followupDir     string
+	followupBy      string
+	followupStart   string
+	followupMinutes int
+
+	icsEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	unsafeInFile = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+)
+
+// followupDayEnd is when the last follow-up meeting of a day must end; later
+// meetings move to the next weekday.
+const followupDayEnd = 17
+
+// writeFollowups writes calendar invites (RFC 5545) for follow-up meetings
+// with the at-risk students from predictCompre: one .ics file per branch or
+// per advisor, with back-to-back meetings from -followup-start and a
+// reminder the day before each. Students on the roster are invited by email,
+// and an advisor given as an email address organises their meetings.
+func writeFollowups(students []Student, set ComponentSet, risks []comprePrediction, dir string) error {
+	if followupBy != "branch" && followupBy != "advisor" {
+		return fmt.Errorf("invalid -followup-by %q (want branch or advisor)", followupBy)
+	}
+	if followupMinutes <= 0 {
+		return fmt.Errorf("-followup-minutes must be positive")
+	}
+	start, err := parseFollowupStart(followupStart, time.Now())
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]Student, len(students))
+	advisors := false
+	for _, s := range students {
+		byID[s.EmpID] = s
+		advisors = advisors || s.Advisor != ""
+	}
+	if followupBy == "advisor" && !advisors {
+		return fmt.Errorf("-followup-by advisor needs a -roster with an Advisor column")
+	}
+
+	batches := make(map[string][]comprePrediction)
+	for _, r := range risks {
+		key := r.Branch
+		if followupBy == "advisor" {
+			key = byID[r.EmpID].Advisor
+		}
+		if key == "" {
+			key = "unassigned"
+		}
+		batches[key] = append(batches[key], r)
+	}
+	keys := make([]string, 0, len(batches))
+	for key := range batches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	stamp := time.Now().UTC()
+	for _, key := range keys {
+		var b strings.Builder
+		icsLine(&b, "BEGIN:VCALENDAR")
+		icsLine(&b, "VERSION:2.0")
+		icsLine(&b, "PRODID:-//gradetool//Follow-ups//EN")
+		icsLine(&b, "METHOD:PUBLISH")
+		icsLine(&b, "X-WR-CALNAME:"+icsEscaper.Replace(followupTitle(followupBy+" "+key)))
+		slot := start
+		for _, r := range batches[key] {
+			s := byID[r.EmpID]
+			end := slot.Add(time.Duration(followupMinutes) * time.Minute)
+			if end.Hour()*60+end.Minute() > followupDayEnd*60 || end.Day() != slot.Day() {
+				slot = nextWeekday(slot, start)
+				end = slot.Add(time.Duration(followupMinutes) * time.Minute)
+			}
+			who := r.EmpID
+			if s.Name != "" {
+				who = s.Name + " (" + r.EmpID + ")"
+			}
+			description := fmt.Sprintf("%s, branch %s, is predicted to score %s in the Compre (95%% PI %s to %s, out of %s).\nMarks so far: %s.",
+				who, r.Branch, formatNumber(r.Predicted), formatNumber(r.Low), formatNumber(r.High), formatNumber(set.max("Compre")), followupMarks(s, set))
+
+			icsLine(&b, "BEGIN:VEVENT")
+			icsLine(&b, fmt.Sprintf("UID:followup-%s-%s@gradetool", r.EmpID, slot.UTC().Format("20060102T150405Z")))
+			icsLine(&b, "DTSTAMP:"+stamp.Format("20060102T150405Z"))
+			icsLine(&b, "DTSTART:"+slot.UTC().Format("20060102T150405Z"))
+			icsLine(&b, "DTEND:"+end.UTC().Format("20060102T150405Z"))
+			icsLine(&b, "SUMMARY:"+icsEscaper.Replace(followupTitle("follow-up with "+who)))
+			icsLine(&b, "DESCRIPTION:"+icsEscaper.Replace(description))
+			if strings.Contains(s.Advisor, "@") {
+				icsLine(&b, "ORGANIZER:mailto:"+s.Advisor)
+			}
+			if s.Email != "" {
+				icsLine(&b, fmt.Sprintf("ATTENDEE;CN=\"%s\";ROLE=REQ-PARTICIPANT;RSVP=TRUE:mailto:%s", strings.ReplaceAll(who, `"`, "'"), s.Email))
+			}
+			icsLine(&b, "BEGIN:VALARM")
+			icsLine(&b, "ACTION:DISPLAY")
+			icsLine(&b, "TRIGGER:-P1D")
+			icsLine(&b, "DESCRIPTION:"+icsEscaper.Replace("Tomorrow: follow-up with "+who))
+			icsLine(&b, "END:VALARM")
+			icsLine(&b, "END:VEVENT")
+			slot = end
+		}
+		icsLine(&b, "END:VCALENDAR")
+
+		name := filepath.Join(dir, strings.Trim(unsafeInFile.ReplaceAllString(key, "_"), "_.")+".ics")
+		if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Follow-up invites: %d meetings in %d calendars (per %s) written to %s\n", len(risks), len(keys), followupBy, dir)
+	return nil
+}
+
+// parseFollowupStart reads -followup-start as local time; by default the
+// meetings start at 10:00 on the Monday after now.
+func parseFollowupStart(text string, now time.Time) (time.Time, error) {
+	if text == "" {
+		days := (8 - int(now.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		d := now.AddDate(0, 0, days)
+		return time.Date(d.Year(), d.Month(), d.Day(), 10, 0, 0, 0, time.Local), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", text, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -followup-start %q (want YYYY-MM-DD HH:MM)", text)
+	}
+	return t, nil
+}
+
+// nextWeekday is the day after t, skipping weekends, at the time of day of
+// first.
+func nextWeekday(t, first time.Time) time.Time {
+	d := t.AddDate(0, 0, 1)
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		d = d.AddDate(0, 0, 1)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), first.Hour(), first.Minute(), 0, 0, first.Location())
+}
+
+func followupTitle(text string) string {
+	if courseName == "" {
+		return strings.ToUpper(text[:1]) + text[1:]
+	}
+	return courseName + ": " + text
+}
+
+func followupMarks(s Student, set ComponentSet) string {
+	parts := make([]string, 0, len(predictorComponents))
+	for _, comp := range predictorComponents {
+		parts = append(parts, fmt.Sprintf("%s %s/%s", comp, formatNumber(s.Marks[comp]), formatNumber(set.max(comp))))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// icsLine writes one content line, folded at 75 octets as RFC 5545 requires
+// (without splitting a UTF-8 sequence), with CRLF line endings.
+func icsLine(b *strings.Builder, line string) {
+	// Continuation lines start with a space, which counts.
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func validateNumberFormat() error {
+	if decimals < 0 || decimals > 10 {
+		return fmt.Errorf("invalid -decimals value %d (want 0-10)", decimals)
+	}
+	if decimalSep == "" {
+		return fmt.Errorf("empty -decimal-sep")
+	}
+	return nil
+}
+
+// formatNumber renders v for human-facing output using -decimals and
+// -decimal-sep, e.g. "12,50" with -decimal-sep ",".
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if decimalSep != "." {
+		s = strings.Replace(s, ".", decimalSep, 1)
+	}
+	return s
+}
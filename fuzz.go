@@ -0,0 +1,26 @@
+//go:build gofuzz
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+// Fuzz is the go-fuzz entry point. The input is read as CSV rows and fed
+// through the same parser used for workbooks.
+func Fuzz(data []byte) int {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return 0
+	}
+
+	if err := checkParse(rows); err != nil {
+		panic(err)
+	}
+	return 1
+}
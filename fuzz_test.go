@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/rand"
+	"testing"
+)
+
+// FuzzParseRows feeds CSV rows through parseRows, seeded with a gradebook
+// and the fuzz command's mutations of it. It checks only that the parser
+// does not panic; checkParse holds the stricter checks.
+func FuzzParseRows(f *testing.F) {
+	rows := [][]string{
+		gradebookHeader,
+		{"1", "1", "12320220001", "2022A7PS0001G", "20", "40", "20", "20", "100", "60", "160"},
+		{"2", "2", "12320220002", "2022A8PS0002G", "25.5", "61", "48", "27", "161.5", "90", "251.5"},
+		{"3", "3", "12320220003", "2022AAPS0003G", "12", "33.25", "41", "18", "104.25", "55", "159.25"},
+	}
+	encode := func(rows [][]string) []byte {
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		w.WriteAll(rows)
+		return b.Bytes()
+	}
+
+	f.Add(encode(rows))
+	f.Add(encode(append(append([][]string(nil), mergedGradebookHeader...), rows[1:]...)))
+	for i, cell := range malformedCells {
+		mutated := mutateRows(rand.New(rand.NewSource(0)), rows, 0)
+		mutated[1+i%3][4+i%7] = cell
+		f.Add(encode(mutated))
+	}
+	for seed := range int64(20) {
+		f.Add(encode(mutateRows(rand.New(rand.NewSource(seed)), rows, 0.5)))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		rows, err := r.ReadAll()
+		if err != nil {
+			return
+		}
+		parseRows(Sheet{Rows: rows}, ParseOptions{})
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	gradebookHeader = []string{"Sl No", "Class No.", "Emplid", "Campus ID", "Quiz (30)", "Mid-Sem (75)",
+		"Lab Test (60)", "Weekly Labs (30)", "Pre-Compre (195)", "Compre (105)", "Total (300)"}
+	mergedGradebookHeader = [][]string{
+		{"Sl No", "Class No.", "Emplid", "Campus ID", "Continuous Evaluation", "", "", "", "Pre-Compre (195)", "Compre (105)", "Total (300)"},
+		{"", "", "", "", "Quiz (30)", "Mid-Sem (75)", "Lab Test (60)", "Weekly Labs (30)", "", "", ""},
+	}
+	mergedGradebookRanges = [][2]string{
+		{"A1", "A2"}, {"B1", "B2"}, {"C1", "C2"}, {"D1", "D2"}, {"E1", "H1"}, {"I1", "I2"}, {"J1", "J2"}, {"K1", "K2"},
+	}
+)
+
+type branchWeight struct {
+	code   string
+	weight float64
+}
+
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	count := fs.Int("n", 500, "Number of students to generate")
+	out := fs.String("o", "synthetic.xlsx", "Output file (.xlsx or .csv)")
+	mix := fs.String("branches", "A7:30,A8:15,AD:10,A4:10,A3:10,AA:10,B5:5,A1:5,A2:5", "Branch mix as code:weight pairs")
+	errorRate := fs.Float64("errors", 0, "Fraction of rows with an injected error (0-1)")
+	seed := fs.Int64("seed", 1, "Random seed")
+	mergedHeader := fs.Bool("merged-header", false, "Write a two-row header with merged group cells")
+	fs.Parse(args)
+
+	branches, err := parseBranchMix(*mix)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	rows := generateRows(rng, *count, branches, *errorRate)
+
+	var merges [][2]string
+	if *mergedHeader {
+		rows = append(append([][]string(nil), mergedGradebookHeader...), rows[1:]...)
+		merges = mergedGradebookRanges
+	}
+
+	if strings.EqualFold(filepath.Ext(*out), ".csv") {
+		err = writeCSV(*out, rows)
+	} else {
+		err = writeXLSX(*out, rows, merges)
+	}
+	if err != nil {
+		fmt.Println("Error writing synthetic gradebook:", err)
+		return
+	}
+
+	fmt.Printf("Generated %d students to %s\n", *count, *out)
+}
+
+func parseBranchMix(mix string) ([]branchWeight, error) {
+	var branches []branchWeight
+	for _, part := range strings.Split(mix, ",") {
+		code, w, found := strings.Cut(strings.TrimSpace(part), ":")
+		weight := 1.0
+		if found {
+			var err error
+			weight, err = strconv.ParseFloat(w, 64)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight in branch mix %q", part)
+			}
+		}
+		if len(code) != 2 {
+			return nil, fmt.Errorf("branch code must be 2 characters, got %q", code)
+		}
+		branches = append(branches, branchWeight{code: strings.ToUpper(code), weight: weight})
+	}
+	return branches, nil
+}
+
+func pickBranch(rng *rand.Rand, branches []branchWeight) string {
+	var sum float64
+	for _, b := range branches {
+		sum += b.weight
+	}
+	r := rng.Float64() * sum
+	for _, b := range branches {
+		if r < b.weight {
+			return b.code
+		}
+		r -= b.weight
+	}
+	return branches[len(branches)-1].code
+}
+
+func generateRows(rng *rand.Rand, count int, branches []branchWeight, errorRate float64) [][]string {
+	rows := [][]string{gradebookHeader}
+	years := []int{2021, 2022, 2023, 2024, 2024, 2024}
+	serials := make(map[int]int)
+
+	for i := 0; i < count; i++ {
+		year := years[rng.Intn(len(years))]
+		serials[year]++
+		serial := serials[year]
+		branch := pickBranch(rng, branches)
+
+		empID := fmt.Sprintf("111%d%04d", year, serial)
+		campusID := fmt.Sprintf("%d%sPS%04dP", year, branch, serial)
+		classNo := strconv.Itoa(2462 + rng.Intn(3))
+
+		ability := math.Max(0.05, math.Min(1, rng.NormFloat64()*0.17+0.62))
+		marks := make(map[string]float64)
+		for _, comp := range components {
+			max, ok := componentMax[comp]
+			if !ok {
+				continue
+			}
+			score := math.Max(0, math.Min(1, ability+rng.NormFloat64()*0.1))
+			marks[comp] = math.Round(score*max*4) / 4
+		}
+		preCompre := marks["Quiz"] + marks["Mid-Sem"] + marks["Lab Test"] + marks["Weekly Labs"]
+		total := preCompre + marks["Compre"]
+
+		row := []string{
+			strconv.Itoa(i + 1), classNo, empID, campusID,
+			formatMark(marks["Quiz"]), formatMark(marks["Mid-Sem"]), formatMark(marks["Lab Test"]),
+			formatMark(marks["Weekly Labs"]), formatMark(preCompre), formatMark(marks["Compre"]), formatMark(total),
+		}
+
+		if rng.Float64() < errorRate {
+			injectError(rng, row)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func injectError(rng *rand.Rand, row []string) {
+	switch rng.Intn(5) {
+	case 0:
+		pre, _ := strconv.ParseFloat(row[8], 64)
+		row[8] = formatMark(pre + float64(rng.Intn(9)+1)*0.5)
+	case 1:
+		total, _ := strconv.ParseFloat(row[10], 64)
+		row[10] = formatMark(total - float64(rng.Intn(9)+1)*0.5)
+	case 2:
+		row[3] = row[3][:4]
+	case 3:
+		row[4+rng.Intn(4)] = "AB"
+	case 4:
+		row[4+rng.Intn(6)] = ""
+	}
+}
+
+func formatMark(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+func writeXLSX(path string, rows [][]string, merges [][2]string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "GradeBook"
+	f.SetSheetName(f.GetSheetName(0), sheet)
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		values := make([]interface{}, len(row))
+		for j, cell := range row {
+			if n, err := strconv.ParseFloat(cell, 64); err == nil && j > 3 {
+				values[j] = n
+			} else {
+				values[j] = cell
+			}
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := sw.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range merges {
+		if err := sw.MergeCell(m[0], m[1]); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return f.SaveAs(path)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GradeTrace records how a student's grade was derived, for handling
+// disputes: the marks that were added up, the percentage, the boundary it
+// fell into and any issues on the student that were waived or left open.
+type GradeTrace struct {
+	EmpID    string             `json:"emp_id"`
+	Parts    map[string]float64 `json:"parts"`
+	Total    float64            `json:"total"`
+	MaxTotal float64            `json:"max_total"`
+	Percent  float64            `json:"percent"`
+	Rounding string             `json:"rounding"`
+	Grade    string             `json:"grade"`
+	Points   float64            `json:"points"`
+	MinPct   float64            `json:"min_pct"`
+	// NextGrade and NextPct describe the next boundary up, if any.
+	NextGrade string  `json:"next_grade,omitempty"`
+	NextPct   float64 `json:"next_pct,omitempty"`
+	Waived    []Issue `json:"waived,omitempty"`
+	Open      []Issue `json:"open_issues,omitempty"`
+
+	// order is the order Text lists the parts in.
+	order []string
+}
+
+func traceGrade(cfg Config, run Run, s Student) GradeTrace {
+	course := cfg.course(run.Course)
+	set := run.componentSet()
+	t := GradeTrace{EmpID: s.EmpID, Parts: make(map[string]float64), Total: set.total(s), MaxTotal: course.MaxTotal,
+		Rounding: "none: the grade is taken from the unrounded percentage", order: set.totalParts()}
+	for _, comp := range t.order {
+		t.Parts[comp] = s.Marks[comp]
+	}
+	t.Percent = t.Total / course.MaxTotal * 100
+
+	g := cfg.grade(t.Percent)
+	t.Grade, t.Points, t.MinPct = g.Grade, g.Points, g.MinPct
+	for _, b := range cfg.Grades {
+		if b.MinPct > t.Percent && (t.NextGrade == "" || b.MinPct < t.NextPct) {
+			t.NextGrade, t.NextPct = b.Grade, b.MinPct
+		}
+	}
+
+	for _, issue := range run.Mismatches {
+		if issue.EmpID != s.EmpID && (issue.Row == 0 || issue.Row != s.Row) {
+			continue
+		}
+		if issue.Severity == severityWaived {
+			t.Waived = append(t.Waived, issue)
+		} else {
+			t.Open = append(t.Open, issue)
+		}
+	}
+	return t
+}
+
+func (t GradeTrace) Text() string {
+	var b strings.Builder
+	parts := make([]string, 0, len(t.Parts))
+	for _, comp := range t.order {
+		if v, ok := t.Parts[comp]; ok {
+			parts = append(parts, comp+" "+formatNumber(v))
+		}
+	}
+	fmt.Fprintf(&b, "Total: %s = %s\n", strings.Join(parts, " + "), formatNumber(t.Total))
+	fmt.Fprintf(&b, "Percentage: %s / %s = %s%%\n", formatNumber(t.Total), formatNumber(t.MaxTotal), formatNumber(t.Percent))
+	fmt.Fprintf(&b, "Rounding: %s\n", t.Rounding)
+	if t.Grade == "NC" {
+		fmt.Fprintf(&b, "Grade: NC (below every boundary)\n")
+	} else {
+		fmt.Fprintf(&b, "Grade: %s (%s points), boundary %s%% and above\n", t.Grade, formatNumber(t.Points), formatNumber(t.MinPct))
+	}
+	if t.NextGrade != "" {
+		fmt.Fprintf(&b, "Next boundary: %s at %s%%\n", t.NextGrade, formatNumber(t.NextPct))
+	}
+	for _, issue := range t.Waived {
+		fmt.Fprintf(&b, "Waived: %s (%s)\n", issue.Message, issue.Waiver)
+	}
+	for _, issue := range t.Open {
+		fmt.Fprintf(&b, "Open issue: %s\n", issue.Message)
+	}
+	return b.String()
+}
+
+func (s *server) gradeTrace(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	for _, student := range run.Students {
+		if student.EmpID == r.PathValue("empid") {
+			writeJSON(w, http.StatusOK, traceGrade(s.cfg, run, student))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "student not found in run")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	googleCredentials string
+
+	sheetsAPI    = "https://sheets.googleapis.com/v4/spreadsheets/"
+	sheetsExport = "https://docs.google.com/spreadsheets/d/"
+	sheetsClient = &http.Client{Timeout: 60 * time.Second}
+
+	sheetURLPattern = regexp.MustCompile(`^https://docs\.google\.com/spreadsheets/d/([A-Za-z0-9_-]+)`)
+	sheetIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{30,}$`)
+)
+
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+// googleSheet recognises a Google Sheets share URL, or a bare spreadsheet ID
+// when -google-credentials is set and no file of that name exists. gid is
+// the tab named in the URL, if any.
+func googleSheet(arg string) (id, gid string, ok bool) {
+	if m := sheetURLPattern.FindStringSubmatch(arg); m != nil {
+		if u, err := url.Parse(arg); err == nil {
+			gid = u.Query().Get("gid")
+			if g, found := strings.CutPrefix(u.Fragment, "gid="); found {
+				gid = g
+			}
+		}
+		return m[1], gid, true
+	}
+	if googleCredentials != "" && sheetIDPattern.MatchString(arg) {
+		if _, err := os.Stat(arg); err != nil {
+			return arg, "", true
+		}
+	}
+	return "", "", false
+}
+
+func isGoogleSheet(arg string) bool {
+	_, _, ok := googleSheet(arg)
+	return ok
+}
+
+// loadGoogleSheet fetches a live spreadsheet. With -google-credentials it
+// reads the values through the Sheets API as the service account; without,
+// the sheet must be shared with anyone who has the link and is downloaded
+// as CSV. Either way only the cell values come across, as from a CSV file.
+func loadGoogleSheet(ctx context.Context, arg string) (Sheet, error) {
+	id, gid, _ := googleSheet(arg)
+	if err := jobStep(ctx, "read", 0); err != nil {
+		return Sheet{}, err
+	}
+
+	if googleCredentials == "" {
+		if sheetName != "" {
+			return Sheet{}, fmt.Errorf("-sheet needs -google-credentials; without them, pass the URL of the tab itself (ending in #gid=...)")
+		}
+		data, err := fetchSheetCSV(ctx, id, gid)
+		if err != nil {
+			return Sheet{}, err
+		}
+		return parseCSV(ctx, data, arg)
+	}
+
+	token, err := serviceAccountToken(ctx, googleCredentials)
+	if err != nil {
+		return Sheet{}, err
+	}
+	title, err := sheetTitle(ctx, token, id, gid)
+	if err != nil {
+		return Sheet{}, err
+	}
+	rows, err := sheetValues(ctx, token, id, title)
+	if err != nil {
+		return Sheet{}, err
+	}
+	if err := checkStudentLimit(len(rows)); err != nil {
+		return Sheet{}, err
+	}
+	return Sheet{Rows: rows, Name: title}, nil
+}
+
+func fetchSheetCSV(ctx context.Context, id, gid string) ([]byte, error) {
+	u := sheetsExport + id + "/export?format=csv"
+	if gid != "" {
+		u += "&gid=" + url.QueryEscape(gid)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching spreadsheet %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+
+	// Private sheets redirect to a sign-in page rather than failing.
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		return nil, fmt.Errorf("cannot download spreadsheet %s (%s): share it with anyone with the link, or pass -google-credentials", id, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// serviceAccountToken exchanges a signed JWT for an access token, as
+// described for Google service accounts (RFC 7523).
+func serviceAccountToken(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var creds struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	if creds.ClientEmail == "" || creds.PrivateKey == "" {
+		return "", fmt.Errorf("credentials file %s is not a service account key", path)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	key, err := parsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("credentials file %s: %w", path, err)
+	}
+	now := time.Now().Unix()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss": creds.ClientEmail, "scope": sheetsScope, "aud": creds.TokenURI, "iat": now, "exp": now + 3600,
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		if token.Error != "" {
+			return "", fmt.Errorf("requesting access token: %s: %s", token.Error, token.Description)
+		}
+		return "", fmt.Errorf("requesting access token: %s", resp.Status)
+	}
+	return token.AccessToken, nil
+}
+
+func parsePrivateKey(text string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf("private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+func sheetsGet(ctx context.Context, token, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Sheets API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		msg := fmt.Errorf("Sheets API: %s", resp.Status)
+		if apiErr.Error.Message != "" {
+			msg = fmt.Errorf("%w: %s", msg, apiErr.Error.Message)
+		}
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound {
+			msg = fmt.Errorf("%w (is the spreadsheet shared with the service account?)", msg)
+		}
+		return msg
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sheetTitle picks the tab to read: the one named by -sheet, else the one
+// in the URL, else the first.
+func sheetTitle(ctx context.Context, token, id, gid string) (string, error) {
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				SheetID int    `json:"sheetId"`
+				Title   string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := sheetsGet(ctx, token, sheetsAPI+url.PathEscape(id)+"?fields=sheets.properties", &meta); err != nil {
+		return "", err
+	}
+	titles := make([]string, len(meta.Sheets))
+	for i, s := range meta.Sheets {
+		titles[i] = s.Properties.Title
+		if sheetName == "" && gid != "" && strconv.Itoa(s.Properties.SheetID) == gid {
+			return s.Properties.Title, nil
+		}
+	}
+	if len(titles) == 0 {
+		return "", fmt.Errorf("spreadsheet %s has no sheets", id)
+	}
+	return pickSheet(titles)
+}
+
+// sheetValues reads a whole tab as displayed, the same text excelize gives
+// for a downloaded copy.
+func sheetValues(ctx context.Context, token, id, title string) ([][]string, error) {
+	var values struct {
+		Values [][]interface{} `json:"values"`
+	}
+	rng := "'" + strings.ReplaceAll(title, "'", "''") + "'"
+	u := sheetsAPI + url.PathEscape(id) + "/values/" + url.PathEscape(rng) + "?valueRenderOption=FORMATTED_VALUE"
+	if err := sheetsGet(ctx, token, u, &values); err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(values.Values))
+	for i, row := range values.Values {
+		rows[i] = make([]string, len(row))
+		for j, cell := range row {
+			if v, ok := cell.(float64); ok {
+				rows[i][j] = strconv.FormatFloat(v, 'f', -1, 64)
+			} else {
+				rows[i][j] = strings.TrimSpace(fmt.Sprint(cell))
+			}
+		}
+	}
+	return rows, nil
+}
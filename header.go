@@ -0,0 +1,346 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const maxHeaderRows = 3
+
+type Layout struct {
+	HeaderRows int
+	ClassNo    int
+	EmpID      int
+	CampusID   int
+	Total      int
+	Branch     int
+	Columns    map[string]int
+	Items      []ItemColumn
+	Headers    []string
+
+	// Missing and Moved are set when the header was not recognised: the
+	// columns it lacks, and those it has away from their default position.
+	Missing []string
+	Moved   []string
+
+	// Components and Max are only set by discoverLayout.
+	Components []string
+	Max        map[string]float64
+
+	// Ignored are the rows below the header skipped as not being students.
+	Ignored []IgnoredRow
+	// Extra are the headed columns read from no field (see extraColumns).
+	Extra []ExtraColumn
+}
+
+type ItemColumn struct {
+	Exam string
+	Name string
+	Max  float64
+	Col  int
+}
+
+func (c ItemColumn) Key() string {
+	return c.Exam + " " + c.Name
+}
+
+var (
+	headerAliases = map[string][]string{
+		"Class No.":   {"classno", "class", "classnumber", "section"},
+		"EmpID":       {"emplid", "empid", "employeeid"},
+		"CampusID":    {"campusid", "idno", "idnumber"},
+		"Branch":      {"branch", "branchcode"},
+		"Quiz":        {"quiz", "quizzes"},
+		"Mid-Sem":     {"midsem", "midsemester", "midterm"},
+		"Lab Test":    {"labtest"},
+		"Weekly Labs": {"weeklylabs", "weeklylab", "labs"},
+		"Pre-Compre":  {"precompre", "precomprehensive"},
+		"Compre":      {"compre", "comprehensive", "endsem"},
+		"Total":       {"total", "finaltotal", "grandtotal"},
+	}
+	maxMarksPattern = regexp.MustCompile(`\([^)]*\)`)
+	itemPattern     = regexp.MustCompile(`(?i)^(.*?)\s*\b(Q(?:uestion)?\s*\.?\s*\d+[a-z]?)\s*(?:\((\d+(?:\.\d+)?)\))?$`)
+	nonAlnumPattern = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func defaultLayout() Layout {
+	columns := make(map[string]int)
+	for j, comp := range components {
+		columns[comp] = j + 4
+	}
+	return Layout{HeaderRows: 1, ClassNo: 1, EmpID: 2, CampusID: 3, Branch: -1, Total: 10, Columns: columns}
+}
+
+func (l Layout) width() int {
+	width := max(l.ClassNo, l.EmpID, l.CampusID, l.Total)
+	for _, col := range l.Columns {
+		width = max(width, col)
+	}
+	return width + 1
+}
+
+type headerMatch struct {
+	roles map[string]int
+	items []ItemColumn
+	names []string
+}
+
+func detectLayout(rows [][]string) (Layout, bool) {
+	var best headerMatch
+	bestDepth := 0
+
+	for depth := 1; depth <= maxHeaderRows && depth <= len(rows); depth++ {
+		m := matchHeader(rows[:depth])
+		if len(m.roles)+len(m.items) > len(best.roles)+len(best.items) {
+			best, bestDepth = m, depth
+		}
+	}
+
+	var missing []string
+	for _, role := range append([]string{"EmpID", "CampusID", "Total"}, components...) {
+		if _, ok := best.roles[role]; !ok {
+			missing = append(missing, role)
+		}
+	}
+	if len(missing) > 0 {
+		layout := defaultLayout()
+		if len(rows) > 0 {
+			layout.Headers = matchHeader(rows[:1]).names
+		}
+		layout.Missing = missing
+		for _, role := range append([]string{"EmpID", "CampusID", "Total"}, components...) {
+			name := role
+			if role == "Total" {
+				name = "Final Total"
+			}
+			if col, ok := best.roles[role]; ok && col != layout.roleColumn(name) {
+				layout.Moved = append(layout.Moved, role)
+			}
+		}
+		return layout, false
+	}
+	layout := layoutFromRoles(best.roles, bestDepth)
+	layout.Items = best.items
+	layout.Headers = best.names
+	return layout, true
+}
+
+// headerIssue reports a header that was not recognised. The default
+// positions suit a sheet without one, but when the header names columns
+// elsewhere the sheet has been rearranged, and reading it by position would
+// mix up the marks.
+func headerIssue(l Layout) Issue {
+	missing := strings.Join(l.Missing, ", ")
+	if len(l.Moved) > 0 {
+		return rowError("header", 0, "Header has no column for %s, and its %s columns are not at the default positions; set the columns with -mapping",
+			missing, strings.Join(l.Moved, ", "))
+	}
+	if len(l.Missing) < 3+len(components) {
+		return rowWarning("header", 0, "Header has no column for %s, using default column positions", missing)
+	}
+	return rowWarning("header", 0, "Header not recognised, using default column positions")
+}
+
+func (l Layout) column(name string) int {
+	key := normalizeHeader(name)
+	for col, header := range l.Headers {
+		if normalizeHeader(header) == key {
+			return col
+		}
+	}
+	return -1
+}
+
+func matchHeader(header [][]string) headerMatch {
+	width := 0
+	for _, row := range header {
+		width = max(width, len(row))
+	}
+
+	roles := make(map[string]int)
+	var items []ItemColumn
+	names := make([]string, width)
+	// Columns that only resemble a role are matched after the exact ones,
+	// and only if no other column resembles the same role.
+	fuzzy := make(map[string][]int)
+	for col := 0; col < width; col++ {
+		var segments []string
+		for _, row := range header {
+			if col < len(row) && strings.TrimSpace(row[col]) != "" {
+				cell := strings.TrimSpace(row[col])
+				if len(segments) == 0 || segments[len(segments)-1] != cell {
+					segments = append(segments, cell)
+				}
+			}
+		}
+		if len(segments) == 0 {
+			continue
+		}
+		names[col] = strings.Join(segments, " ")
+
+		if item, ok := matchItem(segments); ok {
+			item.Col = col
+			items = append(items, item)
+			continue
+		}
+
+		role := ""
+		for i := len(segments) - 1; i >= 0 && role == ""; i-- {
+			role = headerRole(segments[i])
+		}
+		if role == "" {
+			role = headerRole(strings.Join(segments, " "))
+		}
+		if _, taken := roles[role]; role != "" && !taken {
+			roles[role] = col
+		}
+		if role != "" {
+			continue
+		}
+		for i := len(segments) - 1; i >= 0 && role == ""; i-- {
+			role = fuzzyHeaderRole(segments[i])
+		}
+		if role == "" {
+			role = fuzzyHeaderRole(strings.Join(segments, " "))
+		}
+		if role != "" {
+			fuzzy[role] = append(fuzzy[role], col)
+		}
+	}
+	for role, cols := range fuzzy {
+		if _, taken := roles[role]; !taken && len(cols) == 1 {
+			roles[role] = cols[0]
+		}
+	}
+
+	return headerMatch{roles: roles, items: items, names: names}
+}
+
+func matchItem(segments []string) (ItemColumn, bool) {
+	m := itemPattern.FindStringSubmatch(segments[len(segments)-1])
+	if m == nil {
+		return ItemColumn{}, false
+	}
+
+	exam := strings.TrimSpace(m[1])
+	if exam == "" && len(segments) > 1 {
+		exam = segments[len(segments)-2]
+	}
+	if role := headerRole(exam); role != "" {
+		exam = role
+	}
+	if exam == "" {
+		exam = "Exam"
+	}
+
+	item := ItemColumn{Exam: exam, Name: strings.ToUpper(strings.ReplaceAll(m[2], " ", ""))}
+	if m[3] != "" {
+		item.Max, _ = strconv.ParseFloat(m[3], 64)
+	}
+	return item, true
+}
+
+func headerRole(name string) string {
+	key := normalizeHeader(name)
+	for role, aliases := range headerAliases {
+		for _, alias := range aliases {
+			if key == alias {
+				return role
+			}
+		}
+	}
+	return ""
+}
+
+// fuzzyHeaderRole matches a header that only resembles an alias: one that
+// starts with it, like "Quiz Marks" or "Mid-Sem Exam", or is a typo or two
+// away from it, like "Comprehensve". The longest alias wins, so that
+// "Pre-Compre Total" is Pre-Compre rather than Compre.
+func fuzzyHeaderRole(name string) string {
+	key := normalizeHeader(name)
+	best, bestLen := "", 0
+	for role, aliases := range headerAliases {
+		for _, alias := range aliases {
+			if len(alias) < bestLen || len(alias) == bestLen && role > best {
+				continue
+			}
+			if strings.HasPrefix(key, alias) || len(alias) >= 5 && editDistance(key, alias) <= len(alias)/5 {
+				best, bestLen = role, len(alias)
+			}
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func normalizeHeader(name string) string {
+	name = maxMarksPattern.ReplaceAllString(strings.ToLower(name), "")
+	return nonAlnumPattern.ReplaceAllString(name, "")
+}
+
+func layoutFromRoles(roles map[string]int, depth int) Layout {
+	layout := Layout{HeaderRows: depth, ClassNo: -1, Branch: -1, Columns: make(map[string]int)}
+	for role, col := range roles {
+		switch role {
+		case "Class No.":
+			layout.ClassNo = col
+		case "EmpID":
+			layout.EmpID = col
+		case "CampusID":
+			layout.CampusID = col
+		case "Branch":
+			layout.Branch = col
+		case "Total":
+			layout.Total = col
+		default:
+			layout.Columns[role] = col
+		}
+	}
+	return layout
+}
+
+func flattenMergedHeaders(rows [][]string, merges []excelize.MergeCell) {
+	for _, m := range merges {
+		startCol, startRow, err := excelize.CellNameToCoordinates(m.GetStartAxis())
+		if err != nil || startRow > maxHeaderRows {
+			continue
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(m.GetEndAxis())
+		if err != nil {
+			continue
+		}
+
+		value := m.GetCellValue()
+		for r := startRow; r <= endRow && r <= maxHeaderRows && r <= len(rows); r++ {
+			for len(rows[r-1]) < endCol {
+				rows[r-1] = append(rows[r-1], "")
+			}
+			for c := startCol; c <= endCol; c++ {
+				rows[r-1][c-1] = value
+			}
+		}
+	}
+}
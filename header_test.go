@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+var standardHeader = []string{"Class No.", "EmpID", "CampusID", "Quiz (30)", "Mid-Sem (60)", "Lab Test (30)",
+	"Weekly Labs (30)", "Pre-Compre (150)", "Compre (90)", "Final Total (240)"}
+
+func TestDetectLayoutHeaderOnly(t *testing.T) {
+	layout, ok := detectLayout([][]string{standardHeader})
+	if !ok {
+		t.Fatalf("header of a sheet without students not recognised (missing %v)", layout.Missing)
+	}
+	if layout.HeaderRows != 1 || layout.EmpID != 1 || layout.CampusID != 2 || layout.Total != 9 {
+		t.Errorf("layout = %+v, want the header's own columns", layout)
+	}
+}
+
+func TestParseRowsHeaderOnly(t *testing.T) {
+	students, _, issues, err := parseRows(Sheet{Rows: [][]string{standardHeader}}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 0 {
+		t.Errorf("got %d students from a header-only sheet", len(students))
+	}
+	for _, issue := range issues {
+		if issue.Rule == "header" {
+			t.Errorf("unexpected header issue: %s", issue.Message)
+		}
+	}
+}
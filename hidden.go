@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// hiddenFilter applies -hidden to a sheet's rows as they are read, given
+// its hidden (1-based) columns.
+type hiddenFilter struct {
+	cols  []int
+	width int
+	rows  int
+}
+
+func newHiddenFilter(cols map[int]bool) (*hiddenFilter, error) {
+	if hiddenPolicy != "include" && hiddenPolicy != "skip" {
+		return nil, fmt.Errorf("invalid -hidden value %q (want include or skip)", hiddenPolicy)
+	}
+	h := &hiddenFilter{}
+	for col := range cols {
+		h.cols = append(h.cols, col)
+	}
+	sort.Ints(h.cols)
+	return h, nil
+}
+
+// row blanks the hidden columns of row, and drops the row altogether if it
+// is hidden itself, when hidden cells are skipped.
+func (h *hiddenFilter) row(row []string, hidden bool) []string {
+	h.width = max(h.width, len(row))
+	if hiddenPolicy == "skip" {
+		for _, col := range h.cols {
+			if col > len(row) {
+				break
+			}
+			row[col-1] = ""
+		}
+	}
+	if !hidden || isBlankRow(row) {
+		return row
+	}
+	h.rows++
+	if hiddenPolicy == "skip" {
+		return nil
+	}
+	return row
+}
+
+func (h *hiddenFilter) report() {
+	cols := sort.SearchInts(h.cols, h.width+1)
+	if h.rows > 0 || cols > 0 {
+		action := "included"
+		if hiddenPolicy == "skip" {
+			action = "skipped"
+		}
+		fmt.Printf("Hidden rows encountered: %d, hidden columns: %d (%s)\n", h.rows, cols, action)
+	}
+}
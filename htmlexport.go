@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"time"
+)
+
+var exportHTML bool
+
+// htmlRenderer writes the report as one standalone HTML page, for TAs who
+// do not run the tool: tables of students, branch statistics, component
+// statistics and mismatches, each of which sorts by a click on a column
+// heading and filters by what is typed above it. It needs nothing beyond a
+// browser, so it can be mailed or put on a course page as it is.
+type htmlRenderer struct {
+	path string
+}
+
+// htmlTable is a table as the page lays it out. Each cell has the text
+// shown and, for numbers, the value it sorts by.
+type htmlTable struct {
+	ID      string
+	Title   string
+	Headers []string
+	Rows    [][]htmlCell
+}
+
+type htmlCell struct {
+	Text string
+	Sort string
+}
+
+func htmlNumber(v float64) htmlCell {
+	return htmlCell{formatNumber(v), strconv.FormatFloat(v, 'g', -1, 64)}
+}
+
+func htmlInt(n int) htmlCell {
+	return htmlCell{strconv.Itoa(n), strconv.Itoa(n)}
+}
+
+func (h htmlRenderer) Render(rep *Report) error {
+	students := htmlTable{ID: "students", Title: fmt.Sprintf("Students (%d)", len(rep.Students)),
+		Headers: append(append([]string{"Rank", "EmpID", "Campus ID", "Branch", "Class No."}, rep.Marks...), "Computed Total")}
+	for i, s := range rep.Students {
+		row := []htmlCell{htmlInt(rep.Ranks[i]), {Text: s.EmpID}, {Text: s.CampusID}, {Text: s.Branch}, {Text: s.ClassNo}}
+		for _, name := range rep.Marks {
+			row = append(row, htmlNumber(s.Marks[name]))
+		}
+		students.Rows = append(students.Rows, append(row, htmlNumber(s.Total)))
+	}
+
+	branches := htmlTable{ID: "branches", Title: "Branch Statistics", Headers: []string{"Branch", "Students", "Mean Computed Total"}}
+	for _, b := range rep.Branches {
+		branches.Rows = append(branches.Rows, []htmlCell{{Text: b.Branch}, htmlInt(b.Students), htmlNumber(b.AverageTotal)})
+	}
+
+	comps := htmlTable{ID: "components", Title: "Component Statistics", Headers: []string{"Component", "Mean", "P25", "Median", "P75", "Min", "Max", "Std Dev"}}
+	for _, c := range append(rep.components(), rep.TotalStats) {
+		comps.Rows = append(comps.Rows, []htmlCell{{Text: c.Name}, htmlNumber(c.Mean), htmlNumber(c.P25), htmlNumber(c.Median),
+			htmlNumber(c.P75), htmlNumber(c.Min), htmlNumber(c.Max), htmlNumber(c.StdDev)})
+	}
+
+	mismatches := htmlTable{ID: "mismatches", Title: fmt.Sprintf("Mismatches (%d errors, %d warnings)", countIssues(rep.Issues, severityError), countIssues(rep.Issues, severityWarning)),
+		Headers: []string{"Severity", "Rule", "EmpID", "Row", "Column", "Message", "Waiver"}}
+	for _, issue := range rep.Issues {
+		row := htmlCell{}
+		if issue.Row > 0 {
+			row = htmlInt(issue.Row)
+		}
+		mismatches.Rows = append(mismatches.Rows, []htmlCell{{Text: issue.Severity}, {Text: issue.Rule}, {Text: issue.EmpID}, row,
+			{Text: issue.Column}, {Text: issue.Message}, {Text: issue.Waiver}})
+	}
+
+	title := "Grade Report"
+	if rep.Course != "" {
+		title += ": " + rep.Course
+	}
+	if rep.Branch != "" {
+		title += " (Branch " + rep.Branch + ")"
+	}
+	var failure string
+	if rep.Failure != nil {
+		failure = rep.Failure.Error()
+	}
+	page := struct {
+		Title     string
+		Generated string
+		RunID     string
+		Failure   string
+		Alerts    []string
+		Tables    []htmlTable
+	}{title, time.Now().Format("2006-01-02 15:04"), rep.Manifest.RunID, failure, alertTexts(rep.Alerts),
+		[]htmlTable{students, branches, comps, mismatches}}
+
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, page); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	if err := os.WriteFile(h.path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	fmt.Println("Data exported to", h.path)
+	return nil
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; font-size: 14px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+th { background: #f0f0f0; cursor: pointer; user-select: none; position: sticky; top: 0; }
+th[data-dir="asc"]::after { content: " \25B2"; }
+th[data-dir="desc"]::after { content: " \25BC"; }
+td.num { text-align: right; }
+tr:nth-child(even) td { background: #fafafa; }
+input.filter { margin-bottom: 0.5em; padding: 4px; width: 20em; }
+.meta { color: #666; }
+.alert { color: #b00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{.Generated}}{{if .RunID}} &middot; run {{.RunID}}{{end}}</p>
+{{if .Failure}}<p class="alert"><strong>Partial report:</strong> {{.Failure}}</p>{{end}}
+{{if .Alerts}}<div class="alert"><strong>Needs attention:</strong><ul>{{range .Alerts}}<li>{{.}}</li>{{end}}</ul></div>{{end}}
+{{range .Tables}}
+<h2>{{.Title}}</h2>
+<input class="filter" type="search" placeholder="Filter rows" data-table="{{.ID}}">
+<table id="{{.ID}}">
+<thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}{{if .Sort}}<td class="num" data-sort="{{.Sort}}">{{.Text}}</td>{{else}}<td>{{.Text}}</td>{{end}}{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("input.filter").forEach(function (input) {
+  input.addEventListener("input", function () {
+    var q = input.value.toLowerCase();
+    document.querySelectorAll("#" + input.dataset.table + " tbody tr").forEach(function (tr) {
+      tr.style.display = tr.textContent.toLowerCase().indexOf(q) >= 0 ? "" : "none";
+    });
+  });
+});
+document.querySelectorAll("th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table"), col = th.cellIndex;
+    var dir = th.dataset.dir === "asc" ? "desc" : "asc";
+    table.querySelectorAll("th").forEach(function (h) { delete h.dataset.dir; });
+    th.dataset.dir = dir;
+    var key = function (tr) {
+      var td = tr.cells[col];
+      return td.dataset.sort !== undefined ? parseFloat(td.dataset.sort) : td.textContent;
+    };
+    var rows = Array.from(table.tBodies[0].rows);
+    rows.sort(function (a, b) {
+      var x = key(a), y = key(b);
+      var c = typeof x === "number" && typeof y === "number" ? x - y : String(x).localeCompare(String(y), undefined, {numeric: true});
+      return dir === "asc" ? c : -c;
+    });
+    rows.forEach(function (tr) { table.tBodies[0].appendChild(tr); });
+  });
+});
+</script>
+</body>
+</html>
+`))
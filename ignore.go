@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var columnLetters = regexp.MustCompile(`^[A-Z]{1,3}$`)
+
+// ignoredColumns resolves the columns to ignore, each given as a column
+// letter in capitals, a 1-based number, or else a header name, matched like
+// headers are (ignoring case, punctuation and maxima such as "(30)"). It
+// returns the specs that matched nothing as well.
+func ignoredColumns(header [][]string, specs []string) (map[int]bool, []string) {
+	ignored := make(map[int]bool)
+	var unknown []string
+	for _, spec := range specs {
+		if columnLetters.MatchString(spec) {
+			if n, err := excelize.ColumnNameToNumber(spec); err == nil {
+				ignored[n-1] = true
+				continue
+			}
+		}
+		if n, err := strconv.Atoi(spec); err == nil && n >= 1 && n <= excelize.MaxColumns {
+			ignored[n-1] = true
+			continue
+		}
+		key := normalizeHeader(spec)
+		found := false
+		for col, name := range matchHeader(header).names {
+			if name != "" && normalizeHeader(name) == key {
+				ignored[col], found = true, true
+			}
+		}
+		for _, row := range header {
+			for col, cell := range row {
+				if strings.TrimSpace(cell) != "" && normalizeHeader(cell) == key {
+					ignored[col], found = true, true
+				}
+			}
+		}
+		if !found {
+			unknown = append(unknown, spec)
+		}
+	}
+	return ignored, unknown
+}
+
+func blankColumns(row []string, ignored map[int]bool) {
+	for col := range ignored {
+		if col < len(row) {
+			row[col] = ""
+		}
+	}
+}
+
+// ignoreIssues warns about ignored columns that matched nothing, and those
+// the layout reads a field from, which will come out blank.
+func ignoreIssues(layout Layout, ignored map[int]bool, unknown []string) []Issue {
+	var issues []Issue
+	for _, spec := range unknown {
+		issues = append(issues, rowWarning("header", 0, "Ignored column %q not found in the header", spec))
+	}
+	var cols []int
+	for col := range ignored {
+		cols = append(cols, col)
+	}
+	sort.Ints(cols)
+	for _, col := range cols {
+		for _, name := range append([]string{"EmpID", "CampusID", "Final Total"}, layout.components()...) {
+			if layout.roleColumn(name) == col {
+				letter, _ := excelize.ColumnNumberToName(col + 1)
+				issues = append(issues, rowWarning("header", 0, "Column %s is ignored, but %s is read from it", letter, name))
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+var malformedCells = []string{"", " ", "AB", "NaN", "Inf", "-Inf", "1e309", "#REF!", "#DIV/0!", "23,5", "12.5.1", "--3", "０", "\x00", "TRUE"}
+
+func runFuzz(args []string) {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	iterations := fs.Int("iterations", 1000, "Number of mutated sheets to parse")
+	seed := fs.Int64("seed", 1, "Random seed")
+	rate := fs.Float64("rate", 0.05, "Fraction of rows mutated per iteration")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . fuzz [flags] <path-to-excel-file>")
+		return
+	}
+
+	sheet, err := loadRows(context.Background(), fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	rows := sheet.Rows
+
+	rng := rand.New(rand.NewSource(*seed))
+	failures := 0
+	for i := 0; i < *iterations; i++ {
+		mutated := mutateRows(rng, rows, *rate)
+		if err := checkParse(mutated); err != nil {
+			failures++
+			fmt.Printf("Iteration %d: %v\n", i+1, err)
+		}
+	}
+
+	fmt.Printf("\nFuzzed %d sheets, %d failures\n", *iterations, failures)
+}
+
+func mutateRows(rng *rand.Rand, rows [][]string, rate float64) [][]string {
+	mutated := make([][]string, len(rows))
+	for i, row := range rows {
+		mutated[i] = append([]string(nil), row...)
+	}
+
+	for i := 1; i < len(mutated); i++ {
+		if rng.Float64() >= rate || len(mutated[i]) == 0 {
+			continue
+		}
+		row := mutated[i]
+		switch rng.Intn(4) {
+		case 0:
+			row[rng.Intn(len(row))] = malformedCells[rng.Intn(len(malformedCells))]
+		case 1:
+			mutated[i] = row[:rng.Intn(len(row))]
+		case 2:
+			start := rng.Intn(len(row))
+			end := start + rng.Intn(len(row)-start) + 1
+			for j := start + 1; j < end; j++ {
+				row[j] = ""
+			}
+		case 3:
+			if i+1 < len(mutated) {
+				mutated[i+1] = make([]string, len(row))
+			}
+		}
+	}
+
+	return mutated
+}
+
+func checkParse(rows [][]string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parser panicked: %v", r)
+		}
+	}()
+
+	students, layout, warnings, err := parseRows(Sheet{Rows: rows}, ParseOptions{})
+	if err != nil {
+		return err
+	}
+
+	warnedRows := make(map[int]bool)
+	for _, w := range warnings {
+		warnedRows[w.Row] = true
+	}
+
+	for _, s := range students {
+		if s.Row < 1 || s.Row > len(rows) {
+			return fmt.Errorf("EmpID %s has out-of-range source row %d", s.EmpID, s.Row)
+		}
+		source := rows[s.Row-1]
+		for _, comp := range components {
+			cell := source[layout.Columns[comp]]
+			got := s.Marks[comp]
+			if math.IsNaN(got) || math.IsInf(got, 0) {
+				return fmt.Errorf("row %d: non-finite %s mark %v", s.Row, comp, got)
+			}
+			want, parseErr := parseMark(cell)
+			if parseErr != nil {
+				if !warnedRows[s.Row] {
+					return fmt.Errorf("row %d: malformed %s cell %q parsed silently", s.Row, comp, cell)
+				}
+				continue
+			}
+			if got != want {
+				return fmt.Errorf("row %d: %s parsed as %v, source cell is %q", s.Row, comp, got, cell)
+			}
+		}
+	}
+
+	return nil
+}
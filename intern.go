@@ -0,0 +1,34 @@
+package main
+
+import "unique"
+
+// intern returns the canonical copy of s. Branch codes and component names
+// repeat for every student, so big consolidated sheets keep one copy each.
+func intern(s string) string {
+	return unique.Make(s).Value()
+}
+
+// internStudent swaps a decoded student's branch and map keys for canonical
+// copies; JSON decoding allocates fresh strings for every key of every row.
+func internStudent(s *Student) {
+	s.Branch = intern(s.Branch)
+	s.Marks = internKeys(s.Marks)
+	s.Items = internKeys(s.Items)
+}
+
+func internStudents(students []Student) {
+	for i := range students {
+		internStudent(&students[i])
+	}
+}
+
+func internKeys(m map[string]float64) map[string]float64 {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(m))
+	for k, v := range m {
+		out[intern(k)] = v
+	}
+	return out
+}
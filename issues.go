@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+	severityWaived  = "waived"
+)
+
+// Issue is one problem found while reading or validating a sheet. Rule
+// names the check that raised it, so issues can be filtered, waived or
+// explained without parsing the message.
+type Issue struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	EmpID    string `json:"emp_id,omitempty"`
+	Row      int    `json:"row,omitempty"`
+	// Source is the file the issue was found in, set in reports that
+	// combine several files.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+	Column  string `json:"column,omitempty"`
+	Waiver  string `json:"waiver,omitempty"`
+}
+
+func (i Issue) String() string {
+	return i.Message
+}
+
+// in records the single column an issue is about.
+func (i Issue) in(column string) Issue {
+	i.Column = column
+	return i
+}
+
+// inFile records the file an issue was found in, for reports that combine
+// several files, and leads its message with the file and row, e.g.
+// "grades-A3.xlsx row 57: ...", in place of a leading "Row 57: ".
+func (i Issue) inFile(path string) Issue {
+	if i.Source == "" {
+		i.Source = path
+	}
+	msg := strings.TrimPrefix(i.Message, fmt.Sprintf("Row %d: ", i.Row))
+	i.Message = location(i.Source, i.Row) + ": " + msg
+	return i
+}
+
+// location names a row of a file, e.g. "grades-A3.xlsx row 57", or just the
+// file when the row is not known.
+func location(path string, row int) string {
+	if row <= 0 {
+		return filepath.Base(path)
+	}
+	return fmt.Sprintf("%s row %d", filepath.Base(path), row)
+}
+
+// UnmarshalJSON also accepts the plain strings stored before issues had a
+// severity; all of those were validation errors.
+func (i *Issue) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		*i = Issue{Severity: severityError, Rule: "legacy", Message: msg}
+		return nil
+	}
+	type plain Issue
+	return json.Unmarshal(data, (*plain)(i))
+}
+
+func rowWarning(rule string, row int, format string, args ...interface{}) Issue {
+	return Issue{Severity: severityWarning, Rule: rule, Row: row, Message: fmt.Sprintf(format, args...)}
+}
+
+func rowError(rule string, row int, format string, args ...interface{}) Issue {
+	return Issue{Severity: severityError, Rule: rule, Row: row, Message: fmt.Sprintf(format, args...)}
+}
+
+func studentWarning(rule string, s Student, format string, args ...interface{}) Issue {
+	return Issue{Severity: severityWarning, Rule: rule, EmpID: s.EmpID, Row: s.Row, Message: fmt.Sprintf(format, args...)}
+}
+
+func studentError(rule string, s Student, format string, args ...interface{}) Issue {
+	return Issue{Severity: severityError, Rule: rule, EmpID: s.EmpID, Row: s.Row, Message: fmt.Sprintf(format, args...)}
+}
+
+func printIssues(issues []Issue, layout Layout) {
+	for _, issue := range issues {
+		if issue.Severity == severityWaived {
+			fmt.Printf("Waived: %s (%s)\n", maskMessage(issue), issue.Waiver)
+		} else {
+			fmt.Printf("%s: %s\n", strings.ToUpper(issue.Severity[:1])+issue.Severity[1:], maskMessage(issue))
+		}
+		if explain {
+			fmt.Print(explainIssue(issue, layout))
+		}
+	}
+}
+
+func countIssues(issues []Issue, severity string) int {
+	n := 0
+	for _, issue := range issues {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+func issueMessages(issues []Issue) []string {
+	msgs := make([]string, len(issues))
+	for i, issue := range issues {
+		msgs[i] = issue.Message
+	}
+	return msgs
+}
+
+// errRejected is returned for an upload refused by -fail-on, which is not
+// stored.
+var errRejected = errors.New("rejected")
+
+func validateFailOn(policy string) error {
+	switch policy {
+	case "error", "warning", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid -fail-on value %q (want error, warning or never)", policy)
+}
+
+// shouldFail applies a -fail-on policy: "error" fails on any error, "warning"
+// on any error or warning, and "never" only reports. Waived issues never fail.
+func shouldFail(policy string, issues []Issue) bool {
+	switch policy {
+	case "error":
+		return countIssues(issues, severityError) > 0
+	case "warning":
+		return len(unwaived(issues)) > 0
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+func analyzeItems(students []Student, items []ItemColumn) {
+	exams := make(map[string][]ItemColumn)
+	var order []string
+	for _, item := range items {
+		if _, ok := exams[item.Exam]; !ok {
+			order = append(order, item.Exam)
+		}
+		exams[item.Exam] = append(exams[item.Exam], item)
+	}
+
+	fmt.Println("\nItem Analysis:")
+	if len(students) < 2 {
+		fmt.Println("Not enough students for item analysis.")
+		return
+	}
+
+	table := itemsTable(students, items)
+	for _, exam := range order {
+		examItems := exams[exam]
+		keys := make([]string, len(examItems))
+		for j, item := range examItems {
+			keys[j] = item.Key()
+		}
+		totals := table.SumColumns(keys...)
+
+		ranked := make([]int, len(students))
+		for i := range ranked {
+			ranked[i] = i
+		}
+		sort.Slice(ranked, func(a, b int) bool { return totals[ranked[a]] > totals[ranked[b]] })
+		group := max(1, int(math.Round(float64(len(students))*0.27)))
+
+		fmt.Printf("\n%s (%d items):\n", exam, len(examItems))
+		for _, item := range examItems {
+			scores := table.Column(item.Key())
+			rest := make([]float64, len(students))
+			maxScore := item.Max
+			for i := range scores {
+				rest[i] = totals[i] - scores[i]
+				if item.Max == 0 {
+					maxScore = math.Max(maxScore, scores[i])
+				}
+			}
+
+			difficulty := 0.0
+			upperLower := 0.0
+			if maxScore > 0 {
+				difficulty = mean(scores) / maxScore
+				upper, lower := 0.0, 0.0
+				for k := 0; k < group; k++ {
+					upper += scores[ranked[k]]
+					lower += scores[ranked[len(ranked)-1-k]]
+				}
+				upperLower = (upper - lower) / (float64(group) * maxScore)
+			}
+			discrimination := correlation(scores, rest)
+
+			var flags []string
+			switch {
+			case difficulty < 0.2:
+				flags = append(flags, "very hard")
+			case difficulty > 0.9:
+				flags = append(flags, "very easy")
+			}
+			if discrimination < 0.2 {
+				flags = append(flags, "poor discrimination")
+			}
+			note := ""
+			if len(flags) > 0 {
+				note = " | Review: " + strings.Join(flags, ", ")
+			}
+
+			fmt.Printf("%s: Difficulty %s | Discrimination (r_pb) %s | Upper-Lower D %s%s\n",
+				item.Name, formatNumber(difficulty), formatNumber(discrimination), formatNumber(upperLower), note)
+		}
+	}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return sum(values) / float64(len(values))
+}
+
+func correlation(x, y []float64) float64 {
+	mx, my := mean(x), mean(y)
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	jobRunning    = "running"
+	jobCancelling = "cancelling"
+	jobCancelled  = "cancelled"
+	jobFailed     = "failed"
+	jobDone       = "done"
+
+	// jobStepRows is how often (in rows) long stages report progress and
+	// check for cancellation.
+	jobStepRows  = 256
+	jobRetention = time.Hour
+)
+
+// Job is an upload being imported in the background by the server. Progress
+// counters are updated by the importing goroutine while handlers read them.
+type Job struct {
+	ID     string
+	Tenant string
+	Owner  string
+	Source string
+
+	rows   atomic.Int64
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	state    string
+	stage    string
+	runID    string
+	warning  string
+	err      string
+	created  time.Time
+	finished time.Time
+}
+
+type JobStatus struct {
+	ID         string    `json:"id"`
+	Owner      string    `json:"owner"`
+	Source     string    `json:"source"`
+	State      string    `json:"state"`
+	Stage      string    `json:"stage,omitempty"`
+	Rows       int64     `json:"rows"`
+	RunID      string    `json:"run_id,omitempty"`
+	Warning    string    `json:"warning,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+func (j *Job) status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobStatus{ID: j.ID, Owner: j.Owner, Source: j.Source, State: j.state, Stage: j.stage, Rows: j.rows.Load(),
+		RunID: j.runID, Warning: j.warning, Error: j.err, CreatedAt: j.created, FinishedAt: j.finished}
+}
+
+func (j *Job) progress(stage string, rows int) {
+	j.rows.Store(int64(rows))
+	j.mu.Lock()
+	j.stage = stage
+	j.mu.Unlock()
+}
+
+// finish records the outcome. A run that was saved counts as done even if a
+// cancel arrived too late to stop it.
+func (j *Job) finish(ctx context.Context, runID, warning string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now().UTC()
+	j.stage = ""
+	switch {
+	case err == nil:
+		j.state, j.runID, j.warning = jobDone, runID, warning
+	case ctx.Err() != nil:
+		j.state = jobCancelled
+	default:
+		j.state, j.err = jobFailed, err.Error()
+	}
+}
+
+// requestCancel reports false if the job had already finished.
+func (j *Job) requestCancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != jobRunning && j.state != jobCancelling {
+		return false
+	}
+	j.state = jobCancelling
+	j.cancel()
+	return true
+}
+
+type jobKey struct{}
+
+// jobStep reports progress to the job running under ctx, if any, and
+// returns ctx's error once the job has been cancelled.
+func jobStep(ctx context.Context, stage string, rows int) error {
+	if ctx == nil {
+		return nil
+	}
+	if j, ok := ctx.Value(jobKey{}).(*Job); ok {
+		j.progress(stage, rows)
+	}
+	return ctx.Err()
+}
+
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*Job)}
+}
+
+func (r *jobRegistry) add(j *Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, old := range r.jobs {
+		if st := old.status(); !st.FinishedAt.IsZero() && time.Since(st.FinishedAt) > jobRetention {
+			delete(r.jobs, id)
+		}
+	}
+	r.jobs[j.ID] = j
+}
+
+// get only finds jobs belonging to tenant.
+func (r *jobRegistry) get(id, tenant string) (*Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok || j.Tenant != tenant {
+		return nil, false
+	}
+	return j, true
+}
+
+func (r *jobRegistry) list(tenant string) []JobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []JobStatus
+	for _, j := range r.jobs {
+		if j.Tenant == tenant {
+			out = append(out, j.status())
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out
+}
+
+func (s *server) createJob(w http.ResponseWriter, r *http.Request) {
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	path, name, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	course := r.FormValue("course")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{ID: randomHex(8), Tenant: user.Tenant, Owner: user.Name, Source: name, cancel: cancel,
+		state: jobRunning, stage: "queued", created: time.Now().UTC()}
+	ctx = context.WithValue(ctx, jobKey{}, job)
+	s.jobs.add(job)
+
+	go func() {
+		defer cancel()
+		run, warning, _, err := s.importRun(ctx, user, store, path, name, course)
+		// Partial artifacts of a cancelled or failed import: the upload
+		// itself and any checkpoint written while parsing it.
+		os.Remove(path)
+		removeCheckpoint(path)
+		job.finish(ctx, run.ID, warning, err)
+	}()
+
+	writeJSON(w, http.StatusAccepted, job.status())
+}
+
+func (s *server) listJobs(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.jobs.list(user.Tenant))
+}
+
+func (s *server) getJob(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	job, found := s.jobs.get(r.PathValue("id"), user.Tenant)
+	if !found {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.status())
+}
+
+func (s *server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	job, found := s.jobs.get(r.PathValue("id"), user.Tenant)
+	if !found {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if !job.requestCancel() {
+		writeError(w, http.StatusConflict, "job already "+job.status().State)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job.status())
+}
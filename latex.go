@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	latexOut string
+
+	latexEscaper = strings.NewReplacer(`\`, `\textbackslash{}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`,
+		"_", `\_`, "{", `\{`, "}", `\}`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`)
+)
+
+// writeLatex writes the summary report as a LaTeX document for end-of-course
+// reports. Besides the tables it defines the data as pgfplotstable tables
+// (\componentdata, \branchdata, \gradedata and \studentdata), so that the
+// charts in it can be restyled, or the tables copied into another report,
+// without re-exporting.
+func writeLatex(cfg Config, rep *Report, out string) error {
+	d := newTemplateData(cfg, rep)
+	var b strings.Builder
+	b.WriteString(`\documentclass{article}
+\usepackage[T1]{fontenc}
+\usepackage[margin=2cm]{geometry}
+\usepackage{booktabs}
+\usepackage{longtable}
+\usepackage{pgfplots}
+\usepackage{pgfplotstable}
+\pgfplotsset{compat=1.16}
+
+`)
+
+	b.WriteString("% Data for pgfplots, with plain numbers (a point as the decimal separator).\n")
+	var rows [][]string
+	for _, c := range rep.Components {
+		rows = append(rows, []string{c.Name, latexData(c.Mean), latexData(c.Median), latexData(c.Min), latexData(c.Max), d.outOf(c.Name, latexData)})
+	}
+	latexTable(&b, "componentdata", []string{"component", "mean", "median", "min", "max", "outof"}, rows)
+
+	rows = nil
+	for _, b := range d.branches {
+		rows = append(rows, []string{b.Branch, strconv.Itoa(b.Students), latexData(b.AverageTotal)})
+	}
+	latexTable(&b, "branchdata", []string{"branch", "students", "total"}, rows)
+
+	grades := append([]GradeBoundary(nil), cfg.Grades...)
+	sort.SliceStable(grades, func(i, j int) bool { return grades[i].MinPct > grades[j].MinPct })
+	grades = append(grades, GradeBoundary{Grade: "NC"})
+	counts := make(map[string]int)
+	rows = nil
+	for k, s := range rep.Students {
+		grade, _ := d.studentField(s, k, "grade")
+		counts[grade.(string)]++
+		// A withheld branch's students, were they listed, would give away
+		// the averages \branchdata leaves out.
+		if d.pools[s.Branch] == "" {
+			continue
+		}
+		branch, _ := d.studentField(s, k, "branch")
+		total, _ := d.studentField(s, k, "total")
+		pct, _ := d.studentField(s, k, "percent")
+		rows = append(rows, []string{s.EmpID, branch.(string), latexData(total.(float64)), latexData(pct.(float64)), grade.(string)})
+	}
+	latexTable(&b, "studentdata", []string{"empid", "branch", "total", "percent", "grade"}, rows)
+	var gradeRows [][]string
+	for _, g := range grades {
+		if g.Grade != "NC" || counts["NC"] > 0 {
+			gradeRows = append(gradeRows, []string{g.Grade, strconv.Itoa(counts[g.Grade])})
+		}
+	}
+	latexTable(&b, "gradedata", []string{"grade", "students"}, gradeRows)
+
+	title := "Course Report"
+	if courseName != "" {
+		title += ": " + latexEscaper.Replace(courseName)
+	}
+	fmt.Fprintf(&b, "\n\\begin{document}\n\n\\section*{%s}\n\n", title)
+	fmt.Fprintf(&b, "Date: %s\\\\\nStudents: %d \\quad Validation errors: %d \\quad Warnings: %d\n",
+		d.now.Format("2006-01-02"), len(rep.Students), countIssues(rep.Issues, severityError), countIssues(rep.Issues, severityWarning))
+
+	b.WriteString("\n\\subsection*{Average Marks per Component}\n\n")
+	rows = nil
+	for _, c := range rep.Components {
+		rows = append(rows, []string{c.Name, d.outOf(c.Name, formatNumber), formatNumber(c.Mean), formatNumber(c.Median), formatNumber(c.Min), formatNumber(c.Max)})
+	}
+	latexTabular(&b, []string{"Component", "Out of", "Mean", "Median", "Min", "Max"}, rows)
+	latexBarChart(&b, "componentdata", "component", "mean", "Mean mark")
+
+	b.WriteString("\n\\subsection*{Branch-wise Averages}\n\n")
+	rows = nil
+	for _, b := range d.branches {
+		rows = append(rows, []string{b.Branch, strconv.Itoa(b.Students), formatNumber(b.AverageTotal)})
+	}
+	latexTabular(&b, []string{"Branch", "Students", "Average Total"}, rows)
+
+	b.WriteString("\n\\subsection*{Grade Distribution}\n\n")
+	latexTabular(&b, []string{"Grade", "Students"}, gradeRows)
+	latexBarChart(&b, "gradedata", "grade", "students", "Students")
+
+	b.WriteString("\n\\subsection*{Top 3 Students}\n\n")
+	rows = nil
+	for k := range d.count("top") {
+		s := rep.Students[k]
+		grade, _ := d.studentField(s, k, "grade")
+		branch, _ := d.studentField(s, k, "branch")
+		rows = append(rows, []string{strconv.Itoa(rep.Ranks[k]), s.EmpID, branch.(string), formatNumber(rep.Set.total(s)), grade.(string)})
+	}
+	latexTabular(&b, []string{"Rank", "EmpID", "Branch", "Total", "Grade"}, rows)
+
+	b.WriteString("\n\\subsection*{Validation Errors}\n\n")
+	if len(d.active) == 0 {
+		b.WriteString("None.\n")
+	} else {
+		b.WriteString("{\\small\n\\begin{longtable}{rlp{11cm}}\n\\toprule\nRow & EmpID & Issue \\\\\n\\midrule\n\\endhead\n")
+		for _, issue := range d.active {
+			fmt.Fprintf(&b, "%d & %s & %s \\\\\n", issue.Row, latexEscaper.Replace(issue.EmpID), latexEscaper.Replace(issue.Message))
+		}
+		b.WriteString("\\bottomrule\n\\end{longtable}\n}\n")
+	}
+
+	b.WriteString("\n\\end{document}\n")
+	return os.WriteFile(out, []byte(b.String()), 0o644)
+}
+
+// latexData formats a number for pgfplots, which ignores -decimal-sep.
+func latexData(v float64) string {
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// outOf is a component's maximum mark, or nothing for computed columns and
+// the total, which have none.
+func (d templateData) outOf(comp string, format func(float64) string) string {
+	if slices.Contains(d.rep.Set.names(), comp) {
+		return format(d.rep.Set.max(comp))
+	}
+	return ""
+}
+
+// latexTable defines \name as a pgfplotstable table. Text cells are escaped,
+// and commas in them braced so they do not split the column.
+func latexTable(b *strings.Builder, name string, headers []string, rows [][]string) {
+	fmt.Fprintf(b, "\\pgfplotstableread[col sep=comma]{\n%s\n", strings.Join(headers, ","))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.ReplaceAll(latexEscaper.Replace(cell), ",", "{,}")
+		}
+		b.WriteString(strings.Join(cells, ",") + "\n")
+	}
+	fmt.Fprintf(b, "}\\%s\n", name)
+}
+
+func latexTabular(b *strings.Builder, headers []string, rows [][]string) {
+	fmt.Fprintf(b, "\\begin{tabular}{l%s}\n\\toprule\n%s \\\\\n\\midrule\n", strings.Repeat("r", len(headers)-1), strings.Join(headers, " & "))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = latexEscaper.Replace(cell)
+		}
+		b.WriteString(strings.Join(cells, " & ") + " \\\\\n")
+	}
+	b.WriteString("\\bottomrule\n\\end{tabular}\n")
+}
+
+// latexBarChart plots column y of a pgfplotstable table against its label
+// column x.
+func latexBarChart(b *strings.Builder, table, x, y, ylabel string) {
+	fmt.Fprintf(b, `
+\begin{center}
+\begin{tikzpicture}
+\begin{axis}[ybar, ymin=0, width=0.9\textwidth, height=6cm, ylabel={%s},
+  xtick=data, xticklabels from table={\%s}{%s}, x tick label style={rotate=30, anchor=east}]
+\addplot table[x expr=\coordindex, y=%s]{\%s};
+\end{axis}
+\end{tikzpicture}
+\end{center}
+`, ylabel, table, x, y, table)
+}
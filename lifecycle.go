@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+const (
+	statusDraft     = "draft"
+	statusValidated = "validated"
+	statusPublished = "published"
+	statusArchived  = "archived"
+)
+
+var (
+	runTransitions = map[string][]string{
+		statusDraft:     {statusValidated, statusArchived},
+		statusValidated: {statusDraft, statusPublished, statusArchived},
+		statusPublished: {statusArchived},
+	}
+	errRunImmutable = errors.New("run is published or archived and cannot be modified")
+	errConflict     = errors.New("run status changed concurrently")
+)
+
+func runStatus(run Run) string {
+	if run.Status == "" {
+		return statusDraft
+	}
+	return run.Status
+}
+
+func isImmutable(status string) bool {
+	return status == statusPublished || status == statusArchived
+}
+
+func transitionRun(store Storage, id, to, actor string, force bool) (Run, error) {
+	run, err := store.GetRun(id)
+	if err != nil {
+		return Run{}, err
+	}
+	from := runStatus(run)
+
+	allowed := false
+	for _, next := range runTransitions[from] {
+		if next == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return Run{}, fmt.Errorf("cannot move run %s from %s to %s", id, from, to)
+	}
+	if n := countIssues(run.Mismatches, severityError); to == statusValidated && n > 0 && !force {
+		return Run{}, fmt.Errorf("run %s has %d validation errors; fix them or force validation", id, n)
+	}
+	if to == statusPublished && (run.Approval == nil || run.Approval.ApprovedBy == "") {
+		return Run{}, fmt.Errorf("run %s needs approval from a course IC before publication", id)
+	}
+	if to == statusDraft && run.Approval != nil {
+		if err := store.UpdateApproval(id, nil); err != nil {
+			return Run{}, err
+		}
+		run.Approval = nil
+	}
+
+	if err := store.TransitionRun(id, from, to); err != nil {
+		return Run{}, err
+	}
+
+	detail := from + " -> " + to
+	if force {
+		detail += " (forced)"
+	}
+	store.AppendAudit(AuditEntry{RunID: id, Time: time.Now(), Actor: actor, Action: "run." + to, Detail: detail})
+
+	run.Status = to
+	return run, nil
+}
+
+func requirePublished(run Run) error {
+	if runStatus(run) != statusPublished {
+		return fmt.Errorf("run %s is %s; student-facing exports require a published run", run.ID, runStatus(run))
+	}
+	return nil
+}
+
+func cliActor() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "cli"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	maxWorkbooks int
+	maxStudents  int
+	maxExportMB  int
+
+	errLimit = errors.New("resource limit reached")
+	errBusy  = errors.New("server busy")
+
+	workbookOnce  sync.Once
+	workbookSlots chan struct{}
+)
+
+// openWorkbook opens path if fewer than -max-workbooks workbooks are open in
+// this process. It fails immediately rather than queueing, so that a busy
+// server sheds load instead of piling up parsed sheets in memory.
+func openWorkbook(path string) (*excelize.File, error) {
+	if err := acquireWorkbook(); err != nil {
+		return nil, err
+	}
+	f, err := excelize.OpenFile(path, excelize.Options{Password: workbookPassword})
+	if err != nil {
+		releaseWorkbook()
+		return nil, openError(path, err)
+	}
+	return f, nil
+}
+
+// acquireWorkbook takes one of the -max-workbooks slots, for readers that
+// do not go through excelize; releaseWorkbook gives it back.
+func acquireWorkbook() error {
+	workbookOnce.Do(func() {
+		if maxWorkbooks > 0 {
+			workbookSlots = make(chan struct{}, maxWorkbooks)
+		}
+	})
+	if workbookSlots != nil {
+		select {
+		case workbookSlots <- struct{}{}:
+		default:
+			return fmt.Errorf("%w: %d workbooks are already open (-max-workbooks %d), try again shortly",
+				errBusy, maxWorkbooks, maxWorkbooks)
+		}
+	}
+	return nil
+}
+
+func closeWorkbook(f *excelize.File) error {
+	defer releaseWorkbook()
+	return f.Close()
+}
+
+func releaseWorkbook() {
+	if workbookSlots != nil {
+		<-workbookSlots
+	}
+}
+
+// checkStudentLimit is called with the number of rows (or students) read
+// so far; header rows are allowed on top of -max-students.
+func checkStudentLimit(rows int) error {
+	if maxStudents > 0 && rows > maxStudents+maxHeaderRows {
+		return fmt.Errorf("%w: sheet has more than %d students (-max-students %d)", errLimit, maxStudents, maxStudents)
+	}
+	return nil
+}
+
+// exportWriter fails writes once more than -max-export-mb has been written.
+type exportWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func limitExport(w io.Writer) *exportWriter {
+	return &exportWriter{w: w}
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if limit := int64(maxExportMB) << 20; limit > 0 && e.written+int64(len(p)) > limit {
+		return 0, fmt.Errorf("%w: export is larger than %d MB (-max-export-mb %d)", errLimit, maxExportMB, maxExportMB)
+	}
+	n, err := e.w.Write(p)
+	e.written += int64(n)
+	return n, err
+}
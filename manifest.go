@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+var manifestOut string
+
+// clusterSeed seeds k-means (-clusters), so that a run clusters the same
+// way every time.
+const clusterSeed = 1
+
+// Manifest records what a report was computed from: enough to regenerate
+// it bit for bit with the same build. RunID is a hash of the rest, less
+// file names, so two runs share an ID exactly when they had the same
+// build, config, input contents, flags and seeds, wherever the files were.
+// A saved run also has the tenant it is stored for, so that tenants
+// computing the same run keep runs of their own.
+type Manifest struct {
+	RunID  string            `json:"run_id"`
+	Tenant string            `json:"tenant,omitempty"`
+	Tool   ToolVersion       `json:"tool"`
+	Config *ManifestFile     `json:"config,omitempty"`
+	Inputs []ManifestFile    `json:"inputs"`
+	Flags  map[string]string `json:"flags"`
+	Seeds  map[string]int64  `json:"seeds,omitempty"`
+}
+
+type ToolVersion struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Go       string `json:"go"`
+	Revision string `json:"revision,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+}
+
+// ManifestFile is one file a run read: the input itself, or one named by a
+// flag such as -roster. A live Google Sheet has no content to hash.
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Flag   string `json:"flag,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// manifestFileFlags name files that change a run's results.
+var manifestFileFlags = []string{"roster", "waivers", "mapping", "predict-from", "sql-file", "template", "docx-template"}
+
+// manifestSkipFlags change how a run goes but not what it computes, are
+// secret, or name the config, which is recorded by its hash.
+var manifestSkipFlags = map[string]bool{
+	"user": true, "download-token": true, "password": true, "save": true, "jobs": true, "resume": true, "checkpoint-every": true, "manifest": true,
+	"config": true, "profile": true, "profile-dir": true,
+}
+
+// manifestOutputFlags say which exports to write, where and how to show
+// them, and leave the results as they are.
+var manifestOutputFlags = map[string]bool{
+	"export": true, "export-csv": true, "export-xlsx": true, "export-html": true, "pdf": true, "docx": true, "latex": true,
+	"template-out": true, "annotate": true, "split-branches": true, "batch-out": true, "per-branch": true, "mask-ids": true,
+	"timings": true, "followup-ics": true, "followup-by": true, "followup-start": true, "followup-minutes": true,
+}
+
+// buildManifest describes a run over inputs, the files the students were
+// read from.
+func buildManifest(inputs []string) Manifest {
+	m := Manifest{Tool: toolVersion(), Inputs: []ManifestFile{}, Flags: make(map[string]string)}
+	if configPath != "" {
+		f := hashManifestFile(configPath)
+		m.Config = &f
+	}
+	for _, path := range inputs {
+		m.Inputs = append(m.Inputs, hashManifestFile(path))
+	}
+	for _, name := range manifestFileFlags {
+		for _, path := range strings.Split(flag.Lookup(name).Value.String(), ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				f := hashManifestFile(path)
+				f.Flag = name
+				m.Inputs = append(m.Inputs, f)
+			}
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if !manifestSkipFlags[f.Name] && !manifestOutputFlags[f.Name] && !slices.Contains(manifestFileFlags, f.Name) {
+			m.Flags[f.Name] = f.Value.String()
+		}
+	})
+	if numClusters > 0 {
+		m.Seeds = map[string]int64{"clusters": clusterSeed}
+	}
+
+	m.RunID = m.id()
+	return m
+}
+
+// forTenant is m for a run saved under tenant.
+func (m Manifest) forTenant(tenant string) Manifest {
+	m.Tenant = tenant
+	m.RunID = m.id()
+	return m
+}
+
+func (m Manifest) id() string {
+	m.Inputs = slices.Clone(m.Inputs)
+	for i := range m.Inputs {
+		m.Inputs[i].Name = ""
+	}
+	if m.Config != nil {
+		config := *m.Config
+		config.Name = ""
+		m.Config = &config
+	}
+	data, _ := json.Marshal(m)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// hashManifestFile hashes the file at path, recording it under the name
+// reports use for it (the URL of a download, say, rather than its copy).
+func hashManifestFile(path string) ManifestFile {
+	f := ManifestFile{Name: path}
+	if path == inputCopy {
+		f.Name = inputName
+	}
+	if isGoogleSheet(path) {
+		f.Note = "live Google Sheet"
+		return f
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		f.Note = err.Error()
+		return f
+	}
+	defer in.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, in)
+	if err != nil {
+		f.Note = err.Error()
+		return f
+	}
+	f.SHA256, f.Bytes = hex.EncodeToString(h.Sum(nil)), n
+	return f
+}
+
+func toolVersion() ToolVersion {
+	v := ToolVersion{Module: "unknown", Version: "unknown"}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	v.Module, v.Version, v.Go = info.Main.Path, info.Main.Version, info.GoVersion
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestManifestLeavesOutSecrets(t *testing.T) {
+	before := buildManifest(nil)
+	secrets := map[string]string{"password": "workbook-secret", "download-token": "token-secret"}
+	for name, value := range secrets {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for name := range secrets {
+			flag.Set(name, "")
+		}
+	}()
+
+	m := buildManifest(nil)
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range secrets {
+		if _, ok := m.Flags[name]; ok {
+			t.Errorf("manifest records -%s", name)
+		}
+		if strings.Contains(string(data), value) {
+			t.Errorf("manifest contains the -%s value", name)
+		}
+	}
+	if m.RunID != before.RunID {
+		t.Errorf("run ID changed from %s to %s with the secrets set", before.RunID, m.RunID)
+	}
+}
+
+func TestManifestIgnoresExports(t *testing.T) {
+	before := buildManifest(nil)
+	exports := map[string]string{"export-xlsx": "true", "pdf": "summary.pdf", "mask-ids": "4", "timings": "true"}
+	for name, value := range exports {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for name := range exports {
+			f := flag.Lookup(name)
+			f.Value.Set(f.DefValue)
+		}
+	}()
+
+	if m := buildManifest(nil); m.RunID != before.RunID {
+		t.Errorf("run ID changed from %s to %s with exports asked for (flags %v)", before.RunID, m.RunID, m.Flags)
+	}
+}
+
+func TestUploadedRunUsesManifestID(t *testing.T) {
+	path := writeMarksWorkbook(t)
+
+	ids := make(map[string]string)
+	for _, course := range []string{"CS F111", "CS F111", "CS F211"} {
+		run, err := buildRun(context.Background(), path, course)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Manifest == nil || run.ID != run.Manifest.RunID {
+			t.Fatalf("run %s has manifest %+v", run.ID, run.Manifest)
+		}
+		if id, ok := ids[course]; ok && id != run.ID {
+			t.Errorf("the same upload for %s got run IDs %s and %s", course, id, run.ID)
+		}
+		ids[course] = run.ID
+	}
+	if ids["CS F111"] == ids["CS F211"] {
+		t.Errorf("uploads for two courses share run ID %s", ids["CS F111"])
+	}
+}
+
+func TestSameUploadForTwoTenants(t *testing.T) {
+	path := writeMarksWorkbook(t)
+	store, err := openMemoryStorage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{store: store, ranks: newRankCache()}
+
+	ids := make(map[string]string)
+	for _, tenant := range []string{"cs", "ee"} {
+		user := User{Name: tenant + "-grader", Tenant: tenant}
+		run, _, status, err := s.importRun(context.Background(), user, scopeStorage(store, tenant), path, "marks.xlsx", "CS F111")
+		if err != nil {
+			t.Fatalf("tenant %s: %d %v", tenant, status, err)
+		}
+		ids[tenant] = run.ID
+	}
+	if ids["cs"] == ids["ee"] {
+		t.Errorf("both tenants' runs have ID %s", ids["cs"])
+	}
+
+	user := User{Name: "cs-grader", Tenant: "cs"}
+	if _, _, status, err := s.importRun(context.Background(), user, scopeStorage(store, "cs"), path, "marks.xlsx", "CS F111"); status != http.StatusConflict {
+		t.Errorf("importing the sheet again gave %d %v, want %d", status, err, http.StatusConflict)
+	}
+}
+
+// writeMarksWorkbook writes a standard sheet with one student.
+func writeMarksWorkbook(t *testing.T) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	rows := [][]interface{}{
+		{"Class No.", "EmpID", "CampusID", "Quiz (30)", "Mid-Sem (60)", "Lab Test (30)", "Weekly Labs (30)", "Pre-Compre (150)", "Compre (90)", "Final Total (240)"},
+		{"1", "12320220001", "2022A7PS0001G", 20, 40, 20, 20, 100, 60, 160},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(f.GetSheetName(0), cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "marks.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ColumnMapping pins a course's sheet layout to columns, given as letters or
+// 1-based numbers, for sheets whose headers are not recognised. It is
+// written by the map command, or read from its own file with -mapping.
+type ColumnMapping struct {
+	HeaderRows int               `json:"header_rows"`
+	ClassNo    string            `json:"class_no,omitempty"`
+	EmpID      string            `json:"emp_id"`
+	CampusID   string            `json:"campus_id"`
+	Branch     string            `json:"branch,omitempty"`
+	Total      string            `json:"total,omitempty"`
+	Components []MappedComponent `json:"components"`
+}
+
+type MappedComponent struct {
+	Name   string  `json:"name"`
+	Column string  `json:"column"`
+	Max    float64 `json:"max,omitempty"`
+}
+
+func (m *ColumnMapping) layout() (Layout, error) {
+	col := func(role, letter string, required bool) (int, error) {
+		if letter == "" {
+			if required {
+				return -1, fmt.Errorf("mapping needs a %s column", role)
+			}
+			return -1, nil
+		}
+		n, err := strconv.Atoi(letter)
+		if err == nil && (n < 1 || n > excelize.MaxColumns) {
+			return -1, fmt.Errorf("mapping %s: column %d out of range", role, n)
+		}
+		if err != nil {
+			n, err = excelize.ColumnNameToNumber(letter)
+		}
+		if err != nil {
+			return -1, fmt.Errorf("mapping %s: %w", role, err)
+		}
+		return n - 1, nil
+	}
+
+	layout := Layout{HeaderRows: m.HeaderRows, Columns: make(map[string]int)}
+	var err error
+	if layout.ClassNo, err = col("Class No.", m.ClassNo, false); err != nil {
+		return Layout{}, err
+	}
+	if layout.EmpID, err = col("EmpID", m.EmpID, true); err != nil {
+		return Layout{}, err
+	}
+	if layout.CampusID, err = col("CampusID", m.CampusID, true); err != nil {
+		return Layout{}, err
+	}
+	if layout.Branch, err = col("Branch", m.Branch, false); err != nil {
+		return Layout{}, err
+	}
+	if layout.Total, err = col("Total", m.Total, false); err != nil {
+		return Layout{}, err
+	}
+	if len(m.Components) == 0 {
+		return Layout{}, fmt.Errorf("mapping needs at least one component")
+	}
+
+	var names []string
+	maxMarks := make(map[string]float64)
+	for _, c := range m.Components {
+		if c.Name == "" {
+			return Layout{}, fmt.Errorf("mapping component in column %s has no name", c.Column)
+		}
+		if _, dup := layout.Columns[c.Name]; dup {
+			return Layout{}, fmt.Errorf("mapping lists component %s twice", c.Name)
+		}
+		if layout.Columns[c.Name], err = col(c.Name, c.Column, true); err != nil {
+			return Layout{}, err
+		}
+		names = append(names, c.Name)
+		if c.Max > 0 {
+			maxMarks[c.Name] = c.Max
+		} else if v, ok := componentMax[c.Name]; ok {
+			maxMarks[c.Name] = v
+		}
+	}
+
+	// Only a mapping of something other than the standard components
+	// switches the later stages over to its own component list.
+	if !slices.Equal(sortedCopy(names), sortedCopy(components)) {
+		layout.Components = names
+		layout.Max = maxMarks
+	}
+	return layout, nil
+}
+
+// loadMapping reads a column mapping from its own file: JSON, in the form
+// saved under "mapping" in the config, or for .yaml and .yml files the same
+// fields as YAML, e.g.
+//
+//	header_rows: 1
+//	emp_id: B
+//	campus_id: 3
+//	components:
+//	  - name: Quiz
+//	    column: D
+//	    max: 30
+func loadMapping(path string) (*ColumnMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m ColumnMapping
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = parseMappingYAML(string(data), &m)
+	default:
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// parseMappingYAML reads the YAML a mapping needs, which is plain keys and
+// the list of components, without pulling in a YAML library.
+func parseMappingYAML(text string, m *ColumnMapping) error {
+	inComponents := false
+	for n, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i == 0 || i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		nested := line[0] == ' ' || line[0] == '\t' || line[0] == '-'
+		if nested && inComponents && strings.HasPrefix(trimmed, "-") {
+			m.Components = append(m.Components, MappedComponent{})
+			trimmed = strings.TrimSpace(trimmed[1:])
+			if trimmed == "" {
+				continue
+			}
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", n+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		var err error
+		if nested {
+			if !inComponents || len(m.Components) == 0 {
+				return fmt.Errorf("line %d: unexpected indentation", n+1)
+			}
+			c := &m.Components[len(m.Components)-1]
+			switch key {
+			case "name":
+				c.Name = value
+			case "column":
+				c.Column = strings.ToUpper(value)
+			case "max":
+				c.Max, err = strconv.ParseFloat(value, 64)
+			default:
+				return fmt.Errorf("line %d: unknown component field %q", n+1, key)
+			}
+		} else {
+			inComponents = false
+			switch key {
+			case "header_rows":
+				m.HeaderRows, err = strconv.Atoi(value)
+			case "class_no":
+				m.ClassNo = strings.ToUpper(value)
+			case "emp_id":
+				m.EmpID = strings.ToUpper(value)
+			case "campus_id":
+				m.CampusID = strings.ToUpper(value)
+			case "branch":
+				m.Branch = strings.ToUpper(value)
+			case "total":
+				m.Total = strings.ToUpper(value)
+			case "components":
+				if value != "" {
+					return fmt.Errorf("line %d: components must be a list", n+1)
+				}
+				inComponents = true
+			default:
+				return fmt.Errorf("line %d: unknown field %q", n+1, key)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n+1, key, err)
+		}
+	}
+	return nil
+}
+
+func sortedCopy(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+func runMap(args []string) {
+	fs := flag.NewFlagSet("map", flag.ExitOnError)
+	out := fs.String("o", configPath, "Config file to save the mapping to (created if missing)")
+	preview := fs.Int("rows", 5, "Number of rows to show")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . map [-o course.json] <path-to-excel-file>")
+		return
+	}
+	sheet, err := loadRows(context.Background(), fs.Arg(0))
+	if err != nil {
+		return
+	}
+	rows := sheet.Rows
+	showRows(rows, *preview)
+
+	suggested, ok := discoverLayout(rows)
+	if !ok {
+		suggested, _ = detectLayout(rows)
+	}
+	letter := func(col int) string {
+		if col < 0 {
+			return ""
+		}
+		name, _ := excelize.ColumnNumberToName(col + 1)
+		return name
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	ask := func(prompt, def string) string {
+		fmt.Printf("%s [%s]: ", prompt, def)
+		if !in.Scan() {
+			return def
+		}
+		if answer := strings.TrimSpace(in.Text()); answer != "" {
+			if answer == "-" {
+				return ""
+			}
+			return answer
+		}
+		return def
+	}
+
+	var m ColumnMapping
+	m.HeaderRows, _ = strconv.Atoi(ask("Header rows", strconv.Itoa(max(suggested.HeaderRows, 1))))
+	fmt.Println("Enter a column letter or number, or - for none.")
+	m.EmpID = strings.ToUpper(ask("EmpID column", letter(suggested.EmpID)))
+	m.CampusID = strings.ToUpper(ask("CampusID column", letter(suggested.CampusID)))
+	m.ClassNo = strings.ToUpper(ask("Class No. column", letter(suggested.ClassNo)))
+	m.Branch = strings.ToUpper(ask("Branch column", letter(suggested.Branch)))
+	m.Total = strings.ToUpper(ask("Total column", letter(suggested.Total)))
+
+	var defaults []string
+	for _, name := range suggested.components() {
+		col, ok := suggested.Columns[name]
+		if !ok {
+			continue
+		}
+		pair := name + "=" + letter(col)
+		if v, custom := suggested.Max[name]; custom && v != componentMax[name] {
+			pair += ":" + strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		defaults = append(defaults, pair)
+	}
+	fmt.Println("Components as Name=Column[:max], comma separated.")
+	for _, pair := range strings.Split(ask("Components", strings.Join(defaults, ", ")), ",") {
+		name, rest, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			fmt.Printf("Error: %q is not Name=Column\n", pair)
+			return
+		}
+		c := MappedComponent{Name: strings.TrimSpace(name)}
+		column, maxMarks, _ := strings.Cut(rest, ":")
+		c.Column = strings.ToUpper(strings.TrimSpace(column))
+		if maxMarks != "" {
+			if c.Max, err = strconv.ParseFloat(strings.TrimSpace(maxMarks), 64); err != nil {
+				fmt.Printf("Error: invalid max for %s: %v\n", c.Name, err)
+				return
+			}
+		}
+		m.Components = append(m.Components, c)
+	}
+
+	if _, err := m.layout(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	cfg, err := readConfigFile(*out)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error:", err)
+		return
+	}
+	cfg.Mapping = &m
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := writeConfigFile(*out, cfg); err != nil {
+		fmt.Println("Error saving mapping:", err)
+		return
+	}
+	if profileName != "" && *out == configPath {
+		fmt.Printf("Saved mapping to profile %s (%s)\n", profileName, *out)
+		return
+	}
+	fmt.Printf("Saved mapping to %s; use it with -config %s\n", *out, *out)
+}
+
+func showRows(rows [][]string, n int) {
+	width := 0
+	for _, row := range rows[:min(n, len(rows))] {
+		width = max(width, len(row))
+	}
+	cell := func(s string) string {
+		if r := []rune(s); len(r) > 12 {
+			s = string(r[:11]) + "…"
+		}
+		return fmt.Sprintf("%-13s", s)
+	}
+
+	var b strings.Builder
+	b.WriteString("    ")
+	for col := 0; col < width; col++ {
+		name, _ := excelize.ColumnNumberToName(col + 1)
+		b.WriteString(cell(name))
+	}
+	fmt.Println(strings.TrimRight(b.String(), " "))
+	for i, row := range rows[:min(n, len(rows))] {
+		b.Reset()
+		fmt.Fprintf(&b, "%-4d", i+1)
+		for _, v := range row {
+			b.WriteString(cell(v))
+		}
+		fmt.Println(strings.TrimRight(b.String(), " "))
+	}
+}
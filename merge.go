@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// runMerge reports on several section sheets of one course as a whole: the
+// students of every sheet are combined, each EmpID once, and go through the
+// same validation, averages, rankings and exports as a single sheet.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	fs.Parse(args)
+
+	var paths []string
+	for _, arg := range fs.Args() {
+		expanded, ok, err := batchInputs(arg)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if !ok {
+			expanded = []string{arg}
+		}
+		paths = append(paths, expanded...)
+	}
+	if len(paths) < 2 {
+		fmt.Println("Usage: go run . [flags] merge <section-file> <section-file>... (or a directory or glob)")
+		return
+	}
+
+	students, layout, issues, failure, err := mergeSections(paths)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	report(strings.Join(paths, ","), paths, students, layout, issues, failure)
+}
+
+// mergeSections parses each section sheet and combines their students. A
+// student listed in more than one sheet is counted once: with a warning
+// when the sheets agree, and as an error, keeping the first sheet's marks,
+// when they do not. Every sheet must have the same components.
+func mergeSections(paths []string) ([]Student, Layout, []Issue, *PartialError, error) {
+	var students []Student
+	var issues []Issue
+	var layout Layout
+	var failure *PartialError
+	index := make(map[string]int)
+	owner := make(map[string]string)
+
+	quietParse = true
+	defer func() { quietParse = false }()
+	fmt.Println("Sections:")
+	for i, path := range paths {
+		parsed, l, warnings, err := parseExcel(path)
+		var partial *PartialError
+		if err != nil && !errors.As(err, &partial) {
+			return nil, Layout{}, nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if i == 0 {
+			layout = l
+		} else if !slices.Equal(l.components(), layout.components()) {
+			return nil, Layout{}, nil, nil, fmt.Errorf("%s has components %s, but %s has %s", path,
+				strings.Join(l.components(), ", "), paths[0], strings.Join(layout.components(), ", "))
+		}
+		if partial != nil && failure == nil {
+			failure = partial
+		}
+
+		base := filepath.Base(path)
+		for _, issue := range warnings {
+			issues = append(issues, issue.inFile(path))
+		}
+		classes := make(map[string]bool)
+		for _, s := range parsed {
+			if s.ClassNo != "" {
+				classes[s.ClassNo] = true
+			}
+			k, seen := index[s.EmpID]
+			if !seen {
+				index[s.EmpID] = len(students)
+				owner[s.EmpID] = base
+				students = append(students, s)
+				continue
+			}
+			if diff := markDifferences(students[k], s); len(diff) > 0 {
+				issues = append(issues, studentError("duplicate-empid", s, "EmpID %s is in %s and %s with different marks (%s); keeping %s",
+					s.EmpID, owner[s.EmpID], base, strings.Join(diff, ", "), owner[s.EmpID]))
+			} else {
+				issues = append(issues, studentWarning("duplicate-empid", s, "EmpID %s is in both %s and %s; counted once", s.EmpID, owner[s.EmpID], base))
+			}
+		}
+
+		line := fmt.Sprintf("%s: %d students", path, len(parsed))
+		if len(classes) > 0 {
+			line += " | Class No. " + strings.Join(sortedKeys(classes), ", ")
+		}
+		if partial != nil {
+			line += " | PARTIAL: " + partial.Error()
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("Merged: %d students from %d sections\n", len(students), len(paths))
+	printIssues(issues, layout)
+	return students, layout, issues, failure, nil
+}
+
+// markDifferences lists where two records of the same student disagree.
+func markDifferences(a, b Student) []string {
+	var diff []string
+	if a.CampusID != b.CampusID {
+		diff = append(diff, fmt.Sprintf("CampusID %s vs %s", a.CampusID, b.CampusID))
+	}
+	names := make(map[string]bool)
+	for name := range a.Marks {
+		names[name] = true
+	}
+	for name := range b.Marks {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		if a.Marks[name] != b.Marks[name] {
+			diff = append(diff, fmt.Sprintf("%s %s vs %s", name, formatNumber(a.Marks[name]), formatNumber(b.Marks[name])))
+		}
+	}
+	return diff
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
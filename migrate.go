@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// sqlMigrations[i] upgrades a database from schema version i to i+1. Never
+// edit an entry once released; append a new one instead.
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS runs (
+			id TEXT PRIMARY KEY,
+			course TEXT NOT NULL,
+			source TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			mismatches TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS students (
+			run_id TEXT NOT NULL,
+			pos INTEGER NOT NULL,
+			emp_id TEXT NOT NULL,
+			branch TEXT NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (run_id, pos)
+		)`,
+		`CREATE TABLE IF NOT EXISTS audit (
+			run_id TEXT NOT NULL,
+			at TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			detail TEXT NOT NULL
+		)`,
+	},
+	{`ALTER TABLE runs ADD COLUMN status TEXT NOT NULL DEFAULT 'draft'`},
+	{`ALTER TABLE runs ADD COLUMN approval TEXT NOT NULL DEFAULT 'null'`},
+	{`ALTER TABLE runs ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`},
+	{
+		`CREATE INDEX IF NOT EXISTS runs_tenant ON runs (tenant)`,
+		`CREATE INDEX IF NOT EXISTS students_run ON students (run_id, pos)`,
+		`CREATE INDEX IF NOT EXISTS audit_run ON audit (run_id)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS stats (
+			run_id TEXT PRIMARY KEY,
+			tenant TEXT NOT NULL,
+			course TEXT NOT NULL,
+			at TEXT NOT NULL,
+			students INTEGER NOT NULL,
+			mean REAL NOT NULL,
+			stddev REAL NOT NULL,
+			pass_rate REAL NOT NULL,
+			grades TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS stats_course ON stats (course, at)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS disputes (
+			id TEXT PRIMARY KEY,
+			run_id TEXT NOT NULL,
+			tenant TEXT NOT NULL,
+			emp_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			opened_at TEXT NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS disputes_run ON disputes (run_id, opened_at)`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS notes (
+			id TEXT PRIMARY KEY,
+			run_id TEXT NOT NULL,
+			tenant TEXT NOT NULL,
+			emp_id TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS notes_run ON notes (run_id, created_at)`,
+	},
+	{`ALTER TABLE runs ADD COLUMN components TEXT NOT NULL DEFAULT 'null'`},
+	{`ALTER TABLE runs ADD COLUMN manifest TEXT NOT NULL DEFAULT 'null'`},
+}
+
+func (s *sqlStorage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		// MAX over an empty table is NULL: the database predates versioning.
+		version = s.detectVersion()
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range sqlMigrations[version] {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("version %d: %w", version+1, err)
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(s.rebind(`INSERT INTO schema_version (version) VALUES (?)`), version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectVersion works out the schema of a database created before the
+// schema_version table existed by probing for the columns each version
+// added.
+func (s *sqlStorage) detectVersion() int {
+	if _, err := s.db.Exec(`SELECT id FROM runs LIMIT 1`); err != nil {
+		return 0
+	}
+	version := 1
+	for _, column := range []string{"status", "approval", "tenant"} {
+		if _, err := s.db.Exec(`SELECT ` + column + ` FROM runs LIMIT 1`); err != nil {
+			break
+		}
+		version++
+	}
+	return version
+}
+
+// runSchemaVersion is the version of the JSON report format written by
+// -export and kept by the memory store. Version 1 is the original export
+// of students and mismatches only.
+const runSchemaVersion = 2
+
+// runMigrations[i] upgrades a decoded run from version i+1 to i+2.
+var runMigrations = []func(run *Run){
+	func(run *Run) {
+		if run.Status == "" {
+			run.Status = statusDraft
+		}
+		if run.CreatedAt.IsZero() {
+			run.CreatedAt = time.Unix(0, 0).UTC()
+		}
+		for i := range run.Students {
+			if run.Students[i].Total == 0 {
+				run.Students[i].Total = run.componentSet().total(run.Students[i])
+			}
+		}
+	},
+}
+
+// migrateRun upgrades a run decoded from an older report or store file to
+// the current schema.
+func migrateRun(run *Run) error {
+	version := run.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	if version > runSchemaVersion {
+		return fmt.Errorf("report schema version %d is newer than this tool supports (%d)", version, runSchemaVersion)
+	}
+	for ; version < runSchemaVersion; version++ {
+		runMigrations[version-1](run)
+	}
+	run.SchemaVersion = runSchemaVersion
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Note is an instructor's free-text remark on a run, or on one student of
+// it when EmpID is set, such as "grace marks approved by DUGC on 12/05".
+// Notes can be added at any stage, published runs included, since they
+// explain the marks rather than change them.
+type Note struct {
+	ID        string    `json:"id"`
+	RunID     string    `json:"run_id"`
+	Tenant    string    `json:"tenant,omitempty"`
+	EmpID     string    `json:"emp_id,omitempty"`
+	Text      string    `json:"text"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func addNote(store Storage, runID, empID, text, author string) (Note, error) {
+	run, err := store.GetRun(runID)
+	if err != nil {
+		return Note{}, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Note{}, fmt.Errorf("a note needs some text")
+	}
+	if empID != "" {
+		found := false
+		for _, s := range run.Students {
+			if s.EmpID == empID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return Note{}, fmt.Errorf("%w: student %s is not in run %s", errNotFound, empID, runID)
+		}
+	}
+
+	n := Note{ID: randomHex(6), RunID: runID, EmpID: empID, Text: text, Author: author, CreatedAt: time.Now().UTC()}
+	if err := store.SaveNote(n); err != nil {
+		return Note{}, err
+	}
+	detail := n.ID + ": " + text
+	if empID != "" {
+		detail = n.ID + ": " + empID + ": " + text
+	}
+	store.AppendAudit(AuditEntry{RunID: runID, Time: time.Now(), Actor: author, Action: "note.added", Detail: detail})
+	return n, nil
+}
+
+// studentNotes are the notes a student's report card shows: those on the
+// whole run and those on the student.
+func studentNotes(notes []Note, empID string) []Note {
+	var out []Note
+	for _, n := range notes {
+		if n.EmpID == "" || n.EmpID == empID {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (n Note) line() string {
+	about := "run"
+	if n.EmpID != "" {
+		about = "EmpID: " + n.EmpID
+	}
+	return fmt.Sprintf("%s | %s | %s | %s | %s", n.ID, n.CreatedAt.Format("2006-01-02 15:04"), n.Author, about, n.Text)
+}
+
+func (s *server) addNote(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		EmpID string `json:"emp_id"`
+		Text  string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	n, err := addNote(store, r.PathValue("id"), req.EmpID, req.Text, user.Name)
+	if err != nil {
+		writeDisputeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (s *server) listNotes(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	if _, err := store.GetRun(r.PathValue("id")); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	notes, err := store.ListNotes(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if empID := r.URL.Query().Get("emp_id"); empID != "" {
+		notes = studentNotes(notes, empID)
+	}
+	writeJSON(w, http.StatusOK, notes)
+}
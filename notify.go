@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type NotifyConfig struct {
+	WebhookURL      string      `json:"webhook_url"`
+	SlackWebhookURL string      `json:"slack_webhook_url"`
+	TeamsWebhookURL string      `json:"teams_webhook_url,omitempty"`
+	Email           EmailConfig `json:"email"`
+}
+
+type EmailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+type RunNotification struct {
+	Event  string  `json:"event"`
+	RunID  string  `json:"run_id"`
+	Course string  `json:"course"`
+	Status string  `json:"status"`
+	Count  int     `json:"students"`
+	Errors int     `json:"validation_errors"`
+	Digest *Digest `json:"digest,omitempty"`
+	// Alerts describe the alert rules the run set off, if it needs
+	// attention.
+	Alerts []string `json:"alerts,omitempty"`
+}
+
+func (n RunNotification) Text() string {
+	text := fmt.Sprintf("Run %s for %s: %d students, %d validation errors (%s)", n.RunID, n.Course, n.Count, n.Errors, n.Status)
+	if len(n.Alerts) > 0 {
+		text += "\n\nNeeds attention:\n- " + strings.Join(n.Alerts, "\n- ")
+	}
+	if n.Digest != nil {
+		text += "\n\n" + n.Digest.Text()
+	}
+	return text
+}
+
+func (n RunNotification) subject() string {
+	subject := "Grade run " + n.RunID + " for " + n.Course
+	if len(n.Alerts) > 0 {
+		subject = "[Needs attention] " + subject
+	}
+	return subject
+}
+
+func announceRun(cfg Config, store Storage, run Run) *Digest {
+	n := RunNotification{Event: "run.created", RunID: run.ID, Course: run.Course, Status: runStatus(run),
+		Count: len(run.Students), Errors: countIssues(run.Mismatches, severityError)}
+	if rules, err := alertRules(cfg); err == nil {
+		n.Alerts = alertTexts(evaluateAlerts(cfg, rules, run.Course, run.componentSet(), run.Students, run.Mismatches))
+	}
+
+	if prev, ok := previousRun(store, run); ok {
+		d := diffRuns(prev, run)
+		n.Digest = &d
+		store.AppendAudit(AuditEntry{RunID: prev.ID, Time: time.Now(), Actor: "system", Action: "run.superseded", Detail: "by " + run.ID})
+	}
+
+	for _, err := range sendNotifications(cfg.Notify, n) {
+		fmt.Println("Error sending notification:", err)
+	}
+	return n.Digest
+}
+
+// Notifier delivers run notifications to one destination. Each kind of
+// destination in the notify config has one; notifiers lists those set up.
+type Notifier interface {
+	// Name identifies the destination in errors, e.g. "slack".
+	Name() string
+	Send(n RunNotification) error
+}
+
+// notifiers returns a Notifier for every destination configured, in a
+// fixed order.
+func notifiers(cfg NotifyConfig) []Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var ns []Notifier
+	if cfg.WebhookURL != "" {
+		ns = append(ns, webhookNotifier{client, cfg.WebhookURL})
+	}
+	if cfg.SlackWebhookURL != "" {
+		ns = append(ns, slackNotifier{client, cfg.SlackWebhookURL})
+	}
+	if cfg.TeamsWebhookURL != "" {
+		ns = append(ns, teamsNotifier{client, cfg.TeamsWebhookURL})
+	}
+	if cfg.Email.Host != "" && len(cfg.Email.To) > 0 {
+		ns = append(ns, emailNotifier{cfg.Email})
+	}
+	return ns
+}
+
+func sendNotifications(cfg NotifyConfig, n RunNotification) []error {
+	var errs []error
+	for _, notifier := range notifiers(cfg) {
+		if err := notifier.Send(n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+	}
+	return errs
+}
+
+// webhookNotifier posts the notification itself as JSON.
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (w webhookNotifier) Name() string { return "webhook" }
+
+func (w webhookNotifier) Send(n RunNotification) error {
+	return postJSON(w.client, w.url, n)
+}
+
+// slackNotifier posts the notification's text to a Slack incoming webhook.
+type slackNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (s slackNotifier) Name() string { return "slack" }
+
+func (s slackNotifier) Send(n RunNotification) error {
+	return postJSON(s.client, s.url, map[string]string{"text": n.Text()})
+}
+
+// teamsNotifier posts the notification as a message card to a Microsoft
+// Teams incoming webhook. Teams runs lines together unless they end in two
+// spaces.
+type teamsNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (t teamsNotifier) Name() string { return "teams" }
+
+func (t teamsNotifier) Send(n RunNotification) error {
+	return postJSON(t.client, t.url, map[string]string{
+		"@type":    "MessageCard",
+		"@context": "http://schema.org/extensions",
+		"summary":  n.subject(),
+		"title":    n.subject(),
+		"text":     strings.ReplaceAll(n.Text(), "\n", "  \n"),
+	})
+}
+
+// emailNotifier mails the notification's text to the configured recipients.
+type emailNotifier struct {
+	cfg EmailConfig
+}
+
+func (e emailNotifier) Name() string { return "email" }
+
+func (e emailNotifier) Send(n RunNotification) error {
+	return sendEmail(e.cfg, n.subject(), n.Text())
+}
+
+func postJSON(client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+func sendEmail(cfg EmailConfig, subject, body string) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, port)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		cfg.From, strings.Join(cfg.To, ", "), subject, body)
+	return smtp.SendMail(addr, auth, cfg.From, cfg.To, []byte(msg))
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// isODS reports whether path should be read as an OpenDocument spreadsheet
+// (LibreOffice Calc): with -format auto that is decided by the extension.
+func isODS(path string) bool {
+	if inputFormat != "auto" {
+		return inputFormat == "ods"
+	}
+	return strings.EqualFold(filepath.Ext(path), ".ods")
+}
+
+// odsTable collects one table of content.xml as rows, the way readRows
+// does for a workbook. Calc writes the unused rest of a sheet as a few
+// elements repeated up to a million times, so empty rows and cells are only
+// counted and written out once something follows them.
+type odsTable struct {
+	rows       [][]string
+	comments   map[string]string
+	merges     []excelize.MergeCell
+	hiddenRows map[int]bool
+	hiddenCols map[int]bool
+	cols       int
+	emptyRows  int
+}
+
+// loadODSRows reads the sheet selected by -sheet from an .ods file, with its
+// comments, merged header cells and hidden rows and columns. Cell colours
+// are not read, so -color-tags has no effect on ODS input.
+func loadODSRows(ctx context.Context, path string) (Sheet, error) {
+	if err := acquireWorkbook(); err != nil {
+		return Sheet{}, err
+	}
+	defer releaseWorkbook()
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		fmt.Println("Error opening the file:", err)
+		return Sheet{}, err
+	}
+	defer zr.Close()
+
+	var names []string
+	err = walkODSTables(zr, func(d *xml.Decoder, name string) (bool, error) {
+		names = append(names, name)
+		return true, d.Skip()
+	})
+	var syntax *xml.SyntaxError
+	if err != nil && (!errors.As(err, &syntax) || len(names) == 0) {
+		return Sheet{}, err
+	}
+	if len(names) == 0 {
+		return Sheet{}, fmt.Errorf("%s has no sheets", path)
+	}
+	sheet, err := pickSheet(names)
+	if err != nil {
+		return Sheet{}, err
+	}
+
+	t := &odsTable{comments: make(map[string]string), hiddenRows: make(map[int]bool), hiddenCols: make(map[int]bool)}
+	err = walkODSTables(zr, func(d *xml.Decoder, name string) (bool, error) {
+		if name != sheet {
+			return true, d.Skip()
+		}
+		return false, t.read(ctx, d)
+	})
+	if err != nil {
+		// A damaged file still leaves the rows before the damage usable.
+		if !errors.As(err, &syntax) || len(t.rows) == 0 {
+			return Sheet{}, err
+		}
+		return Sheet{Rows: t.rows, Name: sheet}, &PartialError{Stage: "read", Row: len(t.rows) + 1, Err: err}
+	}
+
+	flattenMergedHeaders(t.rows, t.merges)
+	hidden, err := newHiddenFilter(t.hiddenCols)
+	if err != nil {
+		return Sheet{}, err
+	}
+	for i := range t.rows {
+		t.rows[i] = hidden.row(t.rows[i], t.hiddenRows[i+1])
+	}
+	hidden.report()
+	return Sheet{Rows: t.rows, Name: sheet, Comments: t.comments, Merged: mergedRows(t.merges)}, nil
+}
+
+// walkODSTables calls fn with each table in content.xml, in order, until it
+// returns false. fn must consume the table's element.
+func walkODSTables(zr *zip.ReadCloser, fn func(d *xml.Decoder, name string) (bool, error)) error {
+	var content *zip.File
+	for _, zf := range zr.File {
+		if zf.Name == "content.xml" {
+			content = zf
+		}
+	}
+	if content == nil {
+		return fmt.Errorf("not an .ods file: content.xml is missing")
+	}
+	rc, err := content.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	d := xml.NewDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid content.xml: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "table" {
+			more, err := fn(d, xmlAttr(se, "name"))
+			if err != nil || !more {
+				return err
+			}
+		}
+	}
+}
+
+func xmlAttr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func odsRepeat(se xml.StartElement, local string) int {
+	n, err := strconv.Atoi(xmlAttr(se, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// odsHidden reports whether a row or column is hidden, by hand or by a
+// filter.
+func odsHidden(se xml.StartElement) bool {
+	v := xmlAttr(se, "visibility")
+	return v == "collapse" || v == "filter"
+}
+
+func (t *odsTable) read(ctx context.Context, d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "table-column":
+				n := odsRepeat(tok, "number-columns-repeated")
+				if odsHidden(tok) {
+					for c := 1; c <= n && t.cols+c <= maxODSColumns; c++ {
+						t.hiddenCols[t.cols+c] = true
+					}
+				}
+				t.cols += n
+			case "table-row":
+				if err := t.readRow(ctx, d, tok); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if tok.Name.Local == "table" {
+				return nil
+			}
+		}
+	}
+}
+
+// maxODSColumns is the widest sheet Calc writes; hidden columns past it are
+// the repeated filler after the last one in use.
+const maxODSColumns = 16384
+
+func (t *odsTable) readRow(ctx context.Context, d *xml.Decoder, se xml.StartElement) error {
+	n := odsRepeat(se, "number-rows-repeated")
+	hidden := odsHidden(se)
+	rowNum := len(t.rows) + t.emptyRows + 1
+
+	var row []string
+	emptyCells := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if end, ok := tok.(xml.EndElement); ok && end.Name.Local == "table-row" {
+			break
+		}
+		cell, ok := tok.(xml.StartElement)
+		if !ok || (cell.Name.Local != "table-cell" && cell.Name.Local != "covered-table-cell") {
+			continue
+		}
+		col := len(row) + emptyCells + 1
+		text, comment, err := readODSCell(d, cell)
+		if err != nil {
+			return err
+		}
+		ref, _ := excelize.CoordinatesToCellName(col, rowNum)
+		if comment != "" {
+			t.comments[ref] = comment
+		}
+		spanCols, spanRows := odsRepeat(cell, "number-columns-spanned"), odsRepeat(cell, "number-rows-spanned")
+		if spanCols > 1 || spanRows > 1 {
+			end, _ := excelize.CoordinatesToCellName(col+spanCols-1, rowNum+spanRows-1)
+			t.merges = append(t.merges, excelize.MergeCell{ref + ":" + end, text})
+		}
+
+		repeat := odsRepeat(cell, "number-columns-repeated")
+		if text == "" {
+			emptyCells += repeat
+			continue
+		}
+		for ; emptyCells > 0; emptyCells-- {
+			row = append(row, "")
+		}
+		for range repeat {
+			row = append(row, text)
+		}
+	}
+
+	if len(row) == 0 {
+		t.emptyRows += n
+		return nil
+	}
+	for ; t.emptyRows > 0; t.emptyRows-- {
+		t.rows = append(t.rows, nil)
+	}
+	for k := range n {
+		if len(t.rows)%jobStepRows == 0 {
+			if err := jobStep(ctx, "read", len(t.rows)); err != nil {
+				return err
+			}
+		}
+		t.rows = append(t.rows, append([]string(nil), row...))
+		if hidden {
+			t.hiddenRows[rowNum+k] = true
+		}
+		if err := checkStudentLimit(len(t.rows)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readODSCell returns a cell's text as displayed, its paragraphs joined by
+// newlines, and the text of its comment if it has one.
+func readODSCell(d *xml.Decoder, se xml.StartElement) (text, comment string, err error) {
+	var paras, notes []string
+	var b *strings.Builder
+	inNote := false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", "", err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "annotation":
+				inNote = true
+			case "p":
+				b = &strings.Builder{}
+			case "s":
+				if b != nil {
+					b.WriteString(strings.Repeat(" ", odsRepeat(tok, "c")))
+				}
+			case "tab":
+				if b != nil {
+					b.WriteString("\t")
+				}
+			case "line-break":
+				if b != nil {
+					b.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if b != nil {
+				b.Write(tok)
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "p":
+				if inNote {
+					notes = append(notes, b.String())
+				} else {
+					paras = append(paras, b.String())
+				}
+				b = nil
+			case "annotation":
+				inNote = false
+			case se.Name.Local:
+				text = strings.Join(paras, "\n")
+				if text == "" {
+					text = xmlAttr(se, "value")
+				}
+				return text, strings.TrimSpace(strings.Join(notes, "\n")), nil
+			}
+		}
+	}
+}
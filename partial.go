@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// PartialError reports a fatal error part-way through a run. Whatever was
+// processed before the failure is returned alongside it.
+type PartialError struct {
+	Stage string
+	Row   int
+	Err   error
+}
+
+func (e *PartialError) Error() string {
+	if e.Row > 0 {
+		return fmt.Sprintf("%s failed at row %d: %v", e.Stage, e.Row, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %v", e.Stage, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+func recoverPartial(failure **PartialError, stage string, row int) {
+	if r := recover(); r != nil {
+		*failure = &PartialError{Stage: stage, Row: row, Err: fmt.Errorf("%v", r)}
+	}
+}
+
+// runStage runs one report stage, turning a panic into a recorded failure.
+// Once a stage has failed the remaining ones are skipped.
+func runStage(failure **PartialError, stage string, fn func()) {
+	if *failure != nil {
+		return
+	}
+	defer recoverPartial(failure, stage, 0)
+	fn()
+}
+
+func printPartialMarker(failure *PartialError) {
+	fmt.Println("\n*** PARTIAL REPORT ***")
+	fmt.Println("Processing stopped early:", failure)
+	fmt.Println("Results above cover only the data processed before the failure.")
+}
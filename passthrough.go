@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var keepColumns bool
+
+// ExtraColumn is a headed column of the sheet that the layout reads
+// nothing from, such as remarks or a tutorial section. Its cells are kept
+// with each student, as they are in the sheet, so that the exports can
+// carry them on (see -keep-columns).
+type ExtraColumn struct {
+	Name string
+	Col  int
+}
+
+// extraColumns lists the headed columns the layout leaves unread, other
+// than those ignored. A header used by more than one of them is told apart
+// by the column letter, e.g. "Remarks (M)".
+func extraColumns(layout Layout, ignored map[int]bool) []ExtraColumn {
+	if layout.HeaderRows == 0 {
+		return nil
+	}
+	used := map[int]bool{layout.ClassNo: true, layout.EmpID: true, layout.CampusID: true, layout.Branch: true, layout.Total: true}
+	for _, col := range layout.Columns {
+		used[col] = true
+	}
+	for _, item := range layout.Items {
+		used[item.Col] = true
+	}
+
+	var extra []ExtraColumn
+	count := make(map[string]int)
+	for col, name := range layout.Headers {
+		if name == "" || used[col] || ignored[col] {
+			continue
+		}
+		extra = append(extra, ExtraColumn{Name: name, Col: col})
+		count[name]++
+	}
+	for i, c := range extra {
+		if count[c.Name] > 1 {
+			letter, _ := excelize.ColumnNumberToName(c.Col + 1)
+			extra[i].Name = c.Name + " (" + letter + ")"
+		}
+	}
+	return extra
+}
+
+// extraCells reads a row's cells in the extra columns, leaving out blanks.
+func extraCells(row []string, extra []ExtraColumn) map[string]string {
+	var cells map[string]string
+	for _, c := range extra {
+		if c.Col >= len(row) || row[c.Col] == "" {
+			continue
+		}
+		if cells == nil {
+			cells = make(map[string]string, len(extra))
+		}
+		cells[c.Name] = row[c.Col]
+	}
+	return cells
+}
+
+// extraNames are the names of the extra columns the students carry: the
+// layout's in sheet order, then any others, such as those of students read
+// back from JSON or from another file, alphabetically.
+func extraNames(layout Layout, students []Student) []string {
+	var names []string
+	for _, c := range layout.Extra {
+		names = append(names, c.Name)
+	}
+	var others []string
+	for _, s := range students {
+		for name := range s.Extra {
+			if !slices.Contains(names, name) && !slices.Contains(others, name) {
+				others = append(others, name)
+			}
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/xuri/excelize/v2"
+)
+
+// workbookPassword opens .xlsx workbooks protected with a password to open,
+// which Excel saves encrypted. It comes from -password or, to keep it out of
+// shell history and process listings, WORKBOOK_PASSWORD. Workbooks saved
+// from a protected one are protected with it too.
+var workbookPassword string
+
+// oleSignature begins an OLE compound file: an .xls workbook, or an .xlsx
+// one encrypted with a password.
+var oleSignature = []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
+
+// encryptedWorkbook reports whether the .xlsx workbook at path is encrypted,
+// that is, stored as a compound file rather than a zip package.
+func encryptedWorkbook(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(oleSignature))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, oleSignature)
+}
+
+// encryptedPackage reports whether the compound file at path holds an
+// encrypted .xlsx package, in EncryptionInfo and EncryptedPackage streams,
+// rather than an .xls workbook.
+func encryptedPackage(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	doc, err := mscfb.New(f)
+	if err != nil {
+		return false
+	}
+	streams := make(map[string]bool)
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		streams[entry.Name] = true
+	}
+	return streams["EncryptionInfo"] && streams["EncryptedPackage"]
+}
+
+// passwordError explains why an encrypted workbook could not be opened.
+func passwordError(path string) error {
+	if workbookPassword == "" {
+		return fmt.Errorf("%s is password-protected; pass -password or set WORKBOOK_PASSWORD", path)
+	}
+	return fmt.Errorf("%s: %w", path, excelize.ErrWorkbookPassword)
+}
+
+// openPackage opens the zip package of an .xlsx workbook, decrypting it
+// first with workbookPassword if it is encrypted.
+func openPackage(path string) (*zip.Reader, func() error, error) {
+	if !encryptedWorkbook(path) {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &zr.Reader, zr.Close, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := excelize.Decrypt(raw, &excelize.Options{Password: workbookPassword})
+	if err != nil {
+		return nil, nil, passwordError(path)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, passwordError(path)
+	}
+	return zr, func() error { return nil }, nil
+}
+
+// openError is err from opening the workbook at path, made plain when the
+// workbook is encrypted: excelize reports a missing or wrong password as an
+// unsupported format or a broken zip.
+func openError(path string, err error) error {
+	if encryptedWorkbook(path) {
+		return passwordError(path)
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var pdfOut string
+
+// A4 in points, and the margin around the text.
+const (
+	pdfWidth  = 595.28
+	pdfHeight = 841.89
+	pdfMargin = 50.0
+)
+
+// pdfRenderer writes the summary report as a printable PDF: component and
+// branch averages, the top 3 tables and the validation issues, for the
+// minutes of the moderation committee. It is written without a PDF
+// library, in the standard Helvetica fonts every reader has.
+type pdfRenderer struct {
+	path string
+}
+
+func (p pdfRenderer) Render(rep *Report) error {
+	d := &pdfDoc{}
+	d.newPage()
+
+	title := "Grade Summary"
+	if rep.Course != "" {
+		title += ": " + rep.Course
+	}
+	if rep.Branch != "" {
+		title += " (Branch " + rep.Branch + ")"
+	}
+	d.text(pdfMargin, 16, true, title)
+	d.advance(18)
+	d.text(pdfMargin, 9, false, fmt.Sprintf("%d students | Generated %s | Run %s", len(rep.Students), time.Now().Format("2006-01-02 15:04"), rep.Manifest.RunID))
+	if rep.Failure != nil {
+		d.advance(12)
+		d.text(pdfMargin, 9, true, "PARTIAL REPORT: "+rep.Failure.Error())
+	}
+
+	d.heading("Component Averages")
+	cols := []float64{pdfMargin, 200, 270, 340, 410, 480}
+	d.row(cols, true, "Component", "Mean", "P25", "Median", "P75", "Std Dev")
+	for _, c := range append(rep.components(), rep.TotalStats) {
+		d.row(cols, false, c.Name, formatNumber(c.Mean), formatNumber(c.P25), formatNumber(c.Median), formatNumber(c.P75), formatNumber(c.StdDev))
+	}
+
+	// The report is shared beyond the grading team, so small branches are
+	// pooled (see cohortPools).
+	branches, notes := pooledBranchStats(rep.Branches)
+	pools, _ := branchPools(rep.Branches)
+	d.heading("Branch-wise Averages")
+	cols = []float64{pdfMargin, 200, 270}
+	d.row(cols, true, "Branch", "Students", "Mean Total")
+	for _, b := range branches {
+		d.row(cols, false, b.Branch, strconv.Itoa(b.Students), formatNumber(b.AverageTotal))
+	}
+	for _, note := range notes {
+		d.row([]float64{pdfMargin}, false, note)
+	}
+
+	d.heading("Top 3 Students")
+	cols = []float64{pdfMargin, 90, 200, 270}
+	d.row(cols, true, "Rank", "EmpID", "Branch", "Computed Total")
+	for i := 0; i < 3 && i < len(rep.Students); i++ {
+		s := rep.Students[i]
+		d.row(cols, false, strconv.Itoa(rep.Ranks[i]), s.EmpID, sharedBranch(pools, s.Branch), formatNumber(s.Total))
+	}
+	byBranch := make(map[string][]Student)
+	for _, s := range rep.Students {
+		byBranch[s.Branch] = append(byBranch[s.Branch], s)
+	}
+	for _, b := range branches {
+		if b.Branch == pooledBranch {
+			continue
+		}
+		d.subheading("Branch " + b.Branch)
+		for i := 0; i < 3 && i < len(byBranch[b.Branch]); i++ {
+			s := byBranch[b.Branch][i]
+			d.row(cols, false, strconv.Itoa(i+1), s.EmpID, s.Branch, formatNumber(s.Total))
+		}
+	}
+
+	d.heading(fmt.Sprintf("Validation Issues (%d errors, %d warnings)", countIssues(rep.Issues, severityError), countIssues(rep.Issues, severityWarning)))
+	if len(rep.Issues) == 0 {
+		d.row([]float64{pdfMargin}, false, "No validation issues found.")
+	}
+	for _, issue := range rep.Issues {
+		label := strings.ToUpper(issue.Severity[:1]) + issue.Severity[1:]
+		for i, line := range wrapText(issue.Message, 90) {
+			if i > 0 {
+				label = ""
+			}
+			d.row([]float64{pdfMargin, 110}, false, label, line)
+		}
+	}
+
+	if err := os.WriteFile(p.path, d.bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing PDF report: %w", err)
+	}
+	fmt.Println("PDF report written to", p.path)
+	return nil
+}
+
+// pdfDoc lays out lines of text top to bottom, starting a new page when
+// one is full.
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfHeight - pdfMargin
+}
+
+// advance moves down by h points, to a new page if the line would not fit.
+func (d *pdfDoc) advance(h float64) {
+	d.y -= h
+	if d.y < pdfMargin {
+		d.newPage()
+		d.y -= h
+	}
+}
+
+func (d *pdfDoc) text(x, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, pdfString(s))
+}
+
+func (d *pdfDoc) heading(s string) {
+	d.advance(26)
+	d.text(pdfMargin, 12, true, s)
+	d.advance(4)
+}
+
+func (d *pdfDoc) subheading(s string) {
+	d.advance(16)
+	d.text(pdfMargin, 10, true, s)
+}
+
+// row writes cells at the x positions in cols.
+func (d *pdfDoc) row(cols []float64, bold bool, cells ...string) {
+	d.advance(13)
+	for i, cell := range cells {
+		d.text(cols[i], 9, bold, cell)
+	}
+}
+
+// bytes assembles the document: the catalog, the page tree, the two fonts,
+// then each page with its content stream and page number.
+func (d *pdfDoc) bytes() []byte {
+	var b bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		fmt.Fprintf(page, "BT /F1 8 Tf %.2f %.2f Td (Page %d of %d) Tj ET\n", pdfWidth-pdfMargin-50, pdfMargin/2, i+1, len(d.pages))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes()
+}
+
+// pdfString escapes s for a PDF string in the fonts' Latin-1 based
+// encoding; characters outside it print as "?".
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 128:
+			b.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrapText breaks s into lines of at most width characters, at spaces
+// where it can.
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for len(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,110 @@
+package main
+
+import "sync"
+
+// pipelineBuffer is how far the source may run ahead of the slower stage.
+const pipelineBuffer = 256
+
+// analysis is what a report needs from the students, gathered in one pass:
+// the validation issues, the marks by column for the statistics, and each
+// student's computed total, summed by branch, for the rankings.
+type analysis struct {
+	Set         ComponentSet
+	Mismatches  []Issue
+	Table       MarkTable
+	Totals      []float64
+	BranchTotal map[string]float64
+	BranchCount map[string]int
+	// BranchMarks sum each column of Table by branch.
+	BranchMarks map[string][]float64
+}
+
+// analyze reads the students once, fanning each out to the validation and
+// aggregation stages, which run concurrently and are joined before it
+// returns. Validation sees the students in sheet order, so the issues come
+// out as a sequential check would give them. A panic in either stage is
+// raised again here, for runStage to record.
+func analyze(students []Student, set ComponentSet) analysis {
+	a := analysis{
+		Set:         set,
+		Table:       newMarkTable(append(append([]string(nil), set.names()...), "Final Total"), len(students)),
+		Totals:      make([]float64, len(students)),
+		BranchTotal: make(map[string]float64),
+		BranchCount: make(map[string]int),
+		BranchMarks: make(map[string][]float64),
+	}
+	toValidate := make(chan int, pipelineBuffer)
+	toAggregate := make(chan int, pipelineBuffer)
+	issues := make(chan Issue, pipelineBuffer)
+
+	go func() {
+		defer close(toValidate)
+		defer close(toAggregate)
+		for i := range students {
+			toValidate <- i
+			toAggregate <- i
+		}
+	}()
+
+	var panics [2]interface{}
+	var validated, aggregated sync.WaitGroup
+	validated.Add(1)
+	go func() {
+		defer validated.Done()
+		defer recoverStage(&panics[0], toValidate)
+		v := newValidator(set)
+		for i := range toValidate {
+			v.check(students[i], issues)
+		}
+	}()
+	aggregated.Add(1)
+	go func() {
+		defer aggregated.Done()
+		defer recoverStage(&panics[1], toAggregate)
+		for i := range toAggregate {
+			a.add(i, students[i])
+		}
+	}()
+	go func() {
+		validated.Wait()
+		close(issues)
+	}()
+
+	for issue := range issues {
+		a.Mismatches = append(a.Mismatches, issue)
+	}
+	aggregated.Wait()
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+	return a
+}
+
+func (a *analysis) add(i int, s Student) {
+	a.Table.Branch[i] = s.Branch
+	sums := a.BranchMarks[s.Branch]
+	if sums == nil {
+		sums = make([]float64, len(a.Table.Names))
+		a.BranchMarks[s.Branch] = sums
+	}
+	for j, name := range a.Table.Names {
+		a.Table.cols[j][i] = s.Marks[name]
+		sums[j] += s.Marks[name]
+	}
+	total := a.Set.total(s)
+	a.Totals[i] = total
+	a.BranchTotal[s.Branch] += total
+	a.BranchCount[s.Branch]++
+}
+
+// recoverStage keeps a failed stage draining its input, so that the source
+// and the other stage run to the end, and keeps the panic for analyze.
+func recoverStage(p *interface{}, in <-chan int) {
+	if r := recover(); r != nil {
+		*p = r
+		for range in {
+		}
+	}
+}
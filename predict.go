@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var predictorComponents = []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs"}
+
+type compreModel struct {
+	Coef   []float64
+	XtXInv [][]float64
+	Sigma  float64
+	DF     int
+}
+
+type comprePrediction struct {
+	EmpID     string
+	Branch    string
+	Predicted float64
+	Low       float64
+	High      float64
+}
+
+func predictorRow(s Student) []float64 {
+	x := []float64{1}
+	for _, comp := range predictorComponents {
+		x = append(x, s.Marks[comp])
+	}
+	return x
+}
+
+func fitCompreModel(training []Student) (compreModel, error) {
+	p := len(predictorComponents) + 1
+	if len(training) <= p {
+		return compreModel{}, fmt.Errorf("need more than %d training students, have %d", p, len(training))
+	}
+
+	xtx := make([][]float64, p)
+	for i := range xtx {
+		xtx[i] = make([]float64, p)
+	}
+	xty := make([]float64, p)
+	for _, s := range training {
+		x := predictorRow(s)
+		y := s.Marks["Compre"]
+		for i := 0; i < p; i++ {
+			xty[i] += x[i] * y
+			for j := 0; j < p; j++ {
+				xtx[i][j] += x[i] * x[j]
+			}
+		}
+	}
+
+	inv, err := invertMatrix(xtx)
+	if err != nil {
+		return compreModel{}, err
+	}
+
+	coef := make([]float64, p)
+	for i := range coef {
+		for j := range xty {
+			coef[i] += inv[i][j] * xty[j]
+		}
+	}
+
+	rss := 0.0
+	for _, s := range training {
+		r := s.Marks["Compre"] - dot(coef, predictorRow(s))
+		rss += r * r
+	}
+	df := len(training) - p
+
+	return compreModel{Coef: coef, XtXInv: inv, Sigma: math.Sqrt(rss / float64(df)), DF: df}, nil
+}
+
+func (m compreModel) predict(s Student) (float64, float64) {
+	x := predictorRow(s)
+	leverage := 0.0
+	for i := range x {
+		for j := range x {
+			leverage += x[i] * m.XtXInv[i][j] * x[j]
+		}
+	}
+	halfWidth := tQuantile975(m.DF) * m.Sigma * math.Sqrt(1+leverage)
+	return dot(m.Coef, x), halfWidth
+}
+
+func tQuantile975(df int) float64 {
+	z := 1.959964
+	v := float64(df)
+	return z + (z*z*z+z)/(4*v) + (5*math.Pow(z, 5)+16*z*z*z+3*z)/(96*v*v)
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func invertMatrix(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	a := make([][]float64, n)
+	for i := range m {
+		a[i] = make([]float64, 2*n)
+		copy(a[i], m[i])
+		a[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, errors.New("training data is singular (constant or collinear components)")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		scale := a[col][col]
+		for j := range a[col] {
+			a[col][j] /= scale
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			factor := a[r][col]
+			for j := range a[r] {
+				a[r][j] -= factor * a[col][j]
+			}
+		}
+	}
+
+	inv := make([][]float64, n)
+	for i := range a {
+		inv[i] = a[i][n:]
+	}
+	return inv, nil
+}
+
+func predictCompre(students []Student, set ComponentSet, priorRuns string, riskBelowPct float64) []comprePrediction {
+	var training []Student
+	for _, path := range strings.Split(priorRuns, ",") {
+		run, err := loadExportedRun(strings.TrimSpace(path))
+		if err != nil {
+			fmt.Println("Error loading prior run:", err)
+			return nil
+		}
+		training = append(training, run.Students...)
+	}
+
+	model, err := fitCompreModel(training)
+	if err != nil {
+		fmt.Println("Error fitting Compre model:", err)
+		return nil
+	}
+
+	threshold := set.max("Compre") * riskBelowPct / 100
+	var risks []comprePrediction
+	for _, s := range students {
+		predicted, halfWidth := model.predict(s)
+		if predicted < threshold {
+			risks = append(risks, comprePrediction{
+				EmpID:     s.EmpID,
+				Branch:    s.Branch,
+				Predicted: math.Max(0, predicted),
+				Low:       math.Max(0, predicted-halfWidth),
+				High:      math.Min(set.max("Compre"), predicted+halfWidth),
+			})
+		}
+	}
+	sort.Slice(risks, func(i, j int) bool { return risks[i].Predicted < risks[j].Predicted })
+
+	fmt.Printf("\nCompre Prediction (fit on %d prior students, residual SD %s):\n", len(training), formatNumber(model.Sigma))
+	fmt.Printf("At-risk students (predicted Compre below %s):\n", formatNumber(threshold))
+	if len(risks) == 0 {
+		fmt.Println("No students at risk.")
+	}
+	for _, r := range risks {
+		fmt.Printf("EmpID: %s | Branch: %s | Predicted: %s | 95%% PI: [%s, %s]\n", maskID(r.EmpID), r.Branch, formatNumber(r.Predicted), formatNumber(r.Low), formatNumber(r.High))
+	}
+	return risks
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ExportConfig holds report preferences a config (usually a profile) can
+// set; flags given on the command line still win.
+type ExportConfig struct {
+	JSON       bool   `json:"json,omitempty"`
+	CSV        bool   `json:"csv,omitempty"`
+	XLSX       bool   `json:"xlsx,omitempty"`
+	HTML       bool   `json:"html,omitempty"`
+	Course     string `json:"course,omitempty"`
+	Decimals   *int   `json:"decimals,omitempty"`
+	DecimalSep string `json:"decimal_sep,omitempty"`
+	// Template is the institute's report workbook to fill, as -template.
+	Template     string `json:"template,omitempty"`
+	DocxTemplate string `json:"docx_template,omitempty"`
+	// KeepColumns is -keep-columns.
+	KeepColumns bool `json:"keep_columns,omitempty"`
+	// MaskIDs is -mask-ids, for courses whose results are reviewed on a
+	// projector.
+	MaskIDs int `json:"mask_ids,omitempty"`
+	// PerBranch is -per-branch.
+	PerBranch bool `json:"per_branch,omitempty"`
+	// MinCohort is -min-cohort.
+	MinCohort *int `json:"min_cohort,omitempty"`
+}
+
+func defaultProfileDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "profiles"
+	}
+	return filepath.Join(dir, "gradetool", "profiles")
+}
+
+// resolveProfile points -config at the named profile. A profile is an
+// ordinary config file kept in the profile directory.
+func resolveProfile() error {
+	if profileName == "" {
+		return nil
+	}
+	if configPath != "" {
+		return fmt.Errorf("-profile and -config cannot be used together")
+	}
+	if strings.ContainsAny(profileName, `/\`) || strings.HasPrefix(profileName, ".") {
+		return fmt.Errorf("invalid profile name %q", profileName)
+	}
+	configPath = filepath.Join(profileDir, profileName+".json")
+	return nil
+}
+
+func applyExportPrefs() error {
+	cfg, err := readConfigFile(configPath)
+	if os.IsNotExist(err) && profileName != "" {
+		// A new profile is being created, e.g. by map.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	e := cfg.Export
+	if e.JSON && !set["export"] {
+		exportJSON = true
+	}
+	if e.CSV && !set["export-csv"] {
+		exportCSV = true
+	}
+	if e.XLSX && !set["export-xlsx"] {
+		exportXLSX = true
+	}
+	if e.HTML && !set["export-html"] {
+		exportHTML = true
+	}
+	if e.KeepColumns && !set["keep-columns"] {
+		keepColumns = true
+	}
+	if e.MaskIDs > 0 && !set["mask-ids"] {
+		maskIDs = e.MaskIDs
+	}
+	if e.PerBranch && !set["per-branch"] {
+		perBranch = true
+	}
+	if e.MinCohort != nil && !set["min-cohort"] {
+		minCohort = *e.MinCohort
+	}
+	if e.Course != "" && !set["course"] {
+		courseName = e.Course
+	}
+	if e.Decimals != nil && !set["decimals"] {
+		decimals = *e.Decimals
+	}
+	if e.DecimalSep != "" && !set["decimal-sep"] {
+		decimalSep = e.DecimalSep
+	}
+	if e.Template != "" && !set["template"] {
+		templatePath = e.Template
+	}
+	if e.DocxTemplate != "" && !set["docx-template"] {
+		docxTemplate = e.DocxTemplate
+	}
+	return nil
+}
+
+// weightedColumn turns per-component weights into a "Weighted Total"
+// computed column, e.g. {"Quiz": 0.2, "Compre": 0.5}.
+func weightedColumn(weights map[string]float64) ComputedColumn {
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	terms := make([]string, len(names))
+	for i, name := range names {
+		terms[i] = strconv.FormatFloat(weights[name], 'f', -1, 64) + "*" + normalizeHeader(name)
+	}
+	return ComputedColumn{Name: "Weighted Total", Expr: strings.Join(terms, " + ")}
+}
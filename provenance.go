@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sourceColumns returns the column letter each field of a student is read
+// from. With the row, it names the cell behind every number in a report.
+func sourceColumns(layout Layout) map[string]string {
+	columns := map[string]int{"Class No.": layout.ClassNo, "EmpID": layout.EmpID, "CampusID": layout.CampusID,
+		"Branch": layout.Branch, "Final Total": layout.Total}
+	for _, comp := range layout.components() {
+		columns[comp] = layout.Columns[comp]
+	}
+	for _, item := range layout.Items {
+		columns[item.Key()] = item.Col
+	}
+
+	letters := make(map[string]string, len(columns))
+	for name, col := range columns {
+		if col < 0 {
+			continue
+		}
+		if letter, err := excelize.ColumnNumberToName(col + 1); err == nil {
+			letters[name] = letter
+		}
+	}
+	return letters
+}
+
+func sourceCells(letters map[string]string, row int) map[string]string {
+	cells := make(map[string]string, len(letters))
+	for name, letter := range letters {
+		cells[name] = letter + strconv.Itoa(row)
+	}
+	return cells
+}
+
+// setSource records the file the students were read from, unless they
+// already carry one, as students reloaded from an export do.
+func setSource(students []Student, source string) {
+	for i := range students {
+		if students[i].Source == "" {
+			students[i].Source = source
+		}
+	}
+}
+
+// provenance describes where a student's marks were read, e.g.
+// "marks.xlsx, sheet Grades, row 12".
+func (s Student) provenance() string {
+	if s.Source == "" {
+		return ""
+	}
+	parts := []string{s.Source}
+	if s.Sheet != "" {
+		parts = append(parts, "sheet "+s.Sheet)
+	}
+	if s.Row > 0 {
+		parts = append(parts, "row "+strconv.Itoa(s.Row))
+	}
+	return strings.Join(parts, ", ")
+}
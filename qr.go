@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// qrVersions are QR code versions 1 to 10 at error correction level M: the
+// error correction codewords per block, the data codewords of each block
+// and the alignment pattern positions. Version 10 holds 213 bytes, plenty
+// for a signed link.
+var qrVersions = []struct {
+	ecc    int
+	blocks []int
+	align  []int
+}{
+	{10, []int{16}, nil},
+	{16, []int{28}, []int{6, 18}},
+	{26, []int{44}, []int{6, 22}},
+	{18, []int{32, 32}, []int{6, 26}},
+	{24, []int{43, 43}, []int{6, 30}},
+	{16, []int{27, 27, 27, 27}, []int{6, 34}},
+	{18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	{22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	{22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	{26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+var qrFinderLike = []string{"10111010000", "00001011101"}
+
+type qrMatrix struct {
+	size  int
+	dark  [][]bool
+	fixed [][]bool
+}
+
+// qrEncode encodes data as a QR code (ISO/IEC 18004) in byte mode at error
+// correction level M, in the smallest version that holds it. Modules are
+// indexed [row][col], and true is dark.
+func qrEncode(data []byte) ([][]bool, error) {
+	version, capacity, countBits := 0, 0, 8
+	for v, spec := range qrVersions {
+		capacity = 0
+		for _, n := range spec.blocks {
+			capacity += n
+		}
+		if v+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*capacity {
+			version = v + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%d bytes are too long for a QR code", len(data))
+	}
+	spec := qrVersions[version-1]
+
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>i&1 == 1)
+		}
+	}
+	put(0b0100, 4)
+	put(len(data), countBits)
+	for _, c := range data {
+		put(int(c), 8)
+	}
+	put(0, min(4, 8*capacity-len(bits)))
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := 0xEC; len(bits) < 8*capacity; pad ^= 0xEC ^ 0x11 {
+		put(pad, 8)
+	}
+	codewords := make([]byte, capacity)
+	for i, b := range bits {
+		if b {
+			codewords[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+
+	// The blocks are interleaved a codeword at a time, data first.
+	var blocks, eccs [][]byte
+	for _, n := range spec.blocks {
+		blocks = append(blocks, codewords[:n])
+		eccs = append(eccs, qrReedSolomon(codewords[:n], spec.ecc))
+		codewords = codewords[n:]
+	}
+	var stream []byte
+	for i := 0; i < spec.blocks[len(spec.blocks)-1]; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				stream = append(stream, b[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecc; i++ {
+		for _, e := range eccs {
+			stream = append(stream, e[i])
+		}
+	}
+
+	q := newQRMatrix(version)
+	q.place(stream)
+	best, bestScore := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if score := q.penalty(); bestScore < 0 || score < bestScore {
+			best, bestScore = mask, score
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q.dark, nil
+}
+
+// newQRMatrix draws the function patterns of version, and reserves the
+// format areas, leaving the rest for data.
+func newQRMatrix(version int) *qrMatrix {
+	size := 17 + 4*version
+	q := &qrMatrix{size: size, dark: make([][]bool, size), fixed: make([][]bool, size)}
+	for i := range q.dark {
+		q.dark[i] = make([]bool, size)
+		q.fixed[i] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	for _, corner := range [][2]int{{3, 3}, {3, size - 4}, {size - 4, 3}} {
+		for dr := -4; dr <= 4; dr++ {
+			for dc := -4; dc <= 4; dc++ {
+				r, c := corner[0]+dr, corner[1]+dc
+				if r < 0 || r >= size || c < 0 || c >= size {
+					continue
+				}
+				dist := max(dr, -dr, dc, -dc)
+				q.set(r, c, dist != 2 && dist != 4)
+			}
+		}
+	}
+	align := qrVersions[version-1].align
+	last := len(align) - 1
+	for i, r := range align {
+		for j, c := range align {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dr := -2; dr <= 2; dr++ {
+				for dc := -2; dc <= 2; dc++ {
+					q.set(r+dr, c+dc, max(dr, -dr, dc, -dc) != 1)
+				}
+			}
+		}
+	}
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ rem>>11*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			q.set(b, a, bits>>i&1 == 1)
+			q.set(a, b, bits>>i&1 == 1)
+		}
+	}
+	q.drawFormat(0)
+	return q
+}
+
+func (q *qrMatrix) set(row, col int, dark bool) {
+	q.dark[row][col] = dark
+	q.fixed[row][col] = true
+}
+
+// drawFormat writes both copies of the format information (level M and
+// mask), and the dark module beside the lower one.
+func (q *qrMatrix) drawFormat(mask int) {
+	data := 0b00<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		q.set(i, 8, bit(i))
+	}
+	q.set(7, 8, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(8, 14-i, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(8, q.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(q.size-15+i, 8, bit(i))
+	}
+	q.set(q.size-8, 8, true)
+}
+
+// place fills the data modules in the standard zigzag: two columns at a
+// time from the right, alternately upwards and downwards, stepping over the
+// vertical timing pattern.
+func (q *qrMatrix) place(stream []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			row := vert
+			if upward {
+				row = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if q.fixed[row][col] || i >= 8*len(stream) {
+					continue
+				}
+				q.dark[row][col] = stream[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by mask; applying it twice
+// undoes it.
+func (q *qrMatrix) applyMask(mask int) {
+	for r := 0; r < q.size; r++ {
+		for c := 0; c < q.size; c++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (r+c)%2 == 0
+			case 1:
+				flip = r%2 == 0
+			case 2:
+				flip = c%3 == 0
+			case 3:
+				flip = (r+c)%3 == 0
+			case 4:
+				flip = (r/2+c/3)%2 == 0
+			case 5:
+				flip = r*c%2+r*c%3 == 0
+			case 6:
+				flip = (r*c%2+r*c%3)%2 == 0
+			case 7:
+				flip = ((r+c)%2+r*c%3)%2 == 0
+			}
+			if flip && !q.fixed[r][c] {
+				q.dark[r][c] = !q.dark[r][c]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the masked symbol is to read: long runs, 2x2
+// blocks, patterns that look like finders and an uneven share of dark
+// modules all count against it.
+func (q *qrMatrix) penalty() int {
+	score := 0
+	line := func(at func(i int) bool) {
+		run := 0
+		for i := 0; i < q.size; i++ {
+			if i > 0 && at(i) == at(i-1) {
+				run++
+			} else {
+				run = 1
+			}
+			if run == 5 {
+				score += 3
+			} else if run > 5 {
+				score++
+			}
+		}
+		for i := -4; i <= q.size-7; i++ {
+			for _, p := range qrFinderLike {
+				match := true
+				for k := 0; k < len(p) && match; k++ {
+					j := i + k
+					match = (j >= 0 && j < q.size && at(j)) == (p[k] == '1')
+				}
+				if match {
+					score += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for r := 0; r < q.size; r++ {
+		line(func(i int) bool { return q.dark[r][i] })
+		line(func(i int) bool { return q.dark[i][r] })
+		for c := 0; c < q.size; c++ {
+			if q.dark[r][c] {
+				dark++
+			}
+			if r > 0 && c > 0 && q.dark[r][c] == q.dark[r-1][c] && q.dark[r][c] == q.dark[r][c-1] && q.dark[r][c] == q.dark[r-1][c-1] {
+				score += 3
+			}
+		}
+	}
+	total := q.size * q.size
+	return score + 10*((max(dark*20-total*10, total*10-dark*20)+total-1)/total-1)
+}
+
+// qrReedSolomon returns the n error correction codewords for data.
+func qrReedSolomon(data []byte, n int) []byte {
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := range gen {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	rem := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11D
+		z ^= int(y) >> i & 1 * int(x)
+	}
+	return byte(z)
+}
+
+// qrText draws a QR code in Unicode half blocks, two rows to a line, inside
+// the four-module quiet zone, for printing in a monospaced font.
+func qrText(modules [][]bool) string {
+	const quiet = 4
+	n := len(modules)
+	dark := func(r, c int) bool {
+		r, c = r-quiet, c-quiet
+		return r >= 0 && r < n && c >= 0 && c < n && modules[r][c]
+	}
+	var b strings.Builder
+	for r := 0; r < n+2*quiet; r += 2 {
+		for c := 0; c < n+2*quiet; c++ {
+			cell := 0
+			if dark(r, c) {
+				cell |= 2
+			}
+			if dark(r+1, c) {
+				cell |= 1
+			}
+			b.WriteString([]string{" ", "▄", "▀", "█"}[cell])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ComponentRank is a student's standing in one component. Equal scores
+// share a rank (1, 2, 2, 4).
+type ComponentRank struct {
+	EmpID      string  `json:"emp_id"`
+	Branch     string  `json:"branch"`
+	Score      float64 `json:"score"`
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
+}
+
+// rankIndex holds every component's ranking of a run, best first, so that
+// pages and single-student lookups need no sorting at request time.
+type rankIndex struct {
+	names   []string
+	ranking map[string][]ComponentRank
+	pos     map[string]map[string]int
+}
+
+// componentName matches name to one of the run's components, ignoring case
+// and punctuation ("midsem" finds "Mid-Sem").
+func (x *rankIndex) componentName(name string) (string, bool) {
+	for _, n := range x.names {
+		if normalizeHeader(n) == normalizeHeader(name) {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+func buildRankIndex(students []Student, set ComponentSet) *rankIndex {
+	seen := make(map[string]bool)
+	x := &rankIndex{ranking: make(map[string][]ComponentRank), pos: make(map[string]map[string]int)}
+	for _, s := range students {
+		for name := range s.Marks {
+			if !seen[name] {
+				seen[name] = true
+				x.names = append(x.names, name)
+			}
+		}
+	}
+	sort.Strings(x.names)
+	x.names = append(x.names, "Total")
+
+	for _, name := range x.names {
+		ranks := make([]ComponentRank, len(students))
+		for i, s := range students {
+			score := s.Marks[name]
+			if name == "Total" {
+				score = set.total(s)
+			}
+			ranks[i] = ComponentRank{EmpID: s.EmpID, Branch: s.Branch, Score: score}
+		}
+		sort.SliceStable(ranks, func(i, j int) bool { return ranks[i].Score > ranks[j].Score })
+
+		ascending := make([]float64, len(ranks))
+		for i, r := range ranks {
+			ascending[len(ranks)-1-i] = r.Score
+		}
+		pos := make(map[string]int, len(ranks))
+		for i := range ranks {
+			if i > 0 && ranks[i].Score == ranks[i-1].Score {
+				ranks[i].Rank = ranks[i-1].Rank
+			} else {
+				ranks[i].Rank = i + 1
+			}
+			ranks[i].Percentile = percentileRank(ascending, ranks[i].Score)
+			pos[ranks[i].EmpID] = i
+		}
+		x.ranking[name] = ranks
+		x.pos[name] = pos
+	}
+	return x
+}
+
+// rankCache keeps the indexes of recently used runs. Runs are only written
+// on import, so entries never go stale; a run that is deleted is no longer
+// found by GetRun and its entry simply goes unused.
+type rankCache struct {
+	mu      sync.Mutex
+	indexes map[string]*rankIndex
+}
+
+const rankCacheSize = 64
+
+func newRankCache() *rankCache {
+	return &rankCache{indexes: make(map[string]*rankIndex)}
+}
+
+func (c *rankCache) get(run Run) *rankIndex {
+	c.mu.Lock()
+	x, ok := c.indexes[run.ID]
+	c.mu.Unlock()
+	if ok {
+		return x
+	}
+	return c.put(run)
+}
+
+func (c *rankCache) put(run Run) *rankIndex {
+	x := buildRankIndex(run.Students, run.componentSet())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.indexes) >= rankCacheSize {
+		for id := range c.indexes {
+			delete(c.indexes, id)
+			break
+		}
+	}
+	c.indexes[run.ID] = x
+	return x
+}
+
+// runRanks looks up the run and its index, writing the error response itself
+// when either is missing.
+func (s *server) runRanks(w http.ResponseWriter, r *http.Request) (*rankIndex, string, bool) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return nil, "", false
+	}
+	x := s.ranks.get(run)
+	if r.PathValue("component") == "" {
+		return x, "", true
+	}
+	name, ok := x.componentName(r.PathValue("component"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run has no component "+r.PathValue("component"))
+		return nil, "", false
+	}
+	return x, name, true
+}
+
+func (s *server) listComponents(w http.ResponseWriter, r *http.Request) {
+	x, _, ok := s.runRanks(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, x.names)
+}
+
+// componentRanking serves one page of a ranking: ?offset=0&limit=100, and
+// optionally ?branch= to keep only one branch (ranks stay run-wide).
+func (s *server) componentRanking(w http.ResponseWriter, r *http.Request) {
+	x, name, ok := s.runRanks(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	offset, err := queryInt(q.Get("offset"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid offset: "+err.Error())
+		return
+	}
+	limit, err := queryInt(q.Get("limit"), 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit: "+err.Error())
+		return
+	}
+
+	ranking := x.ranking[name]
+	if branch := q.Get("branch"); branch != "" {
+		var filtered []ComponentRank
+		for _, cr := range ranking {
+			if cr.Branch == branch {
+				filtered = append(filtered, cr)
+			}
+		}
+		ranking = filtered
+	}
+	total := len(ranking)
+	ranking = ranking[min(offset, total):min(offset+limit, total)]
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"component": name,
+		"students":  total,
+		"offset":    offset,
+		"ranking":   ranking,
+	})
+}
+
+func (s *server) componentRank(w http.ResponseWriter, r *http.Request) {
+	x, name, ok := s.runRanks(w, r)
+	if !ok {
+		return
+	}
+	i, found := x.pos[name][r.PathValue("empid")]
+	if !found {
+		writeError(w, http.StatusNotFound, "student not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, x.ranking[name][i])
+}
+
+func queryInt(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err == nil && n < 0 {
+		err = fmt.Errorf("must not be negative")
+	}
+	return n, err
+}
+
+func runRank(args []string) {
+	fs := flag.NewFlagSet("rank", flag.ExitOnError)
+	component := fs.String("component", "", "Component to rank by (default: every component)")
+	n := fs.Int("n", 10, "Students to list per component")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . rank [-component Compre] [-n 10] <path-to-excel-file>")
+		return
+	}
+	students, layout, _, err := parseExcel(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	x := buildRankIndex(students, layout.componentSet())
+	names := x.names
+	if *component != "" {
+		name, ok := x.componentName(*component)
+		if !ok {
+			fmt.Printf("Error: no component %q (have %v)\n", *component, x.names)
+			return
+		}
+		names = []string{name}
+	}
+	for _, name := range names {
+		fmt.Printf("\n%s ranking:\n", name)
+		for _, cr := range x.ranking[name][:min(*n, len(x.ranking[name]))] {
+			fmt.Printf("%d. EmpID: %s | Branch: %s | Score: %s | Percentile: %s\n",
+				cr.Rank, maskID(cr.EmpID), cr.Branch, formatNumber(cr.Score), formatNumber(cr.Percentile))
+		}
+	}
+}
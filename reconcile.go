@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Disagreement struct {
+	EmpID    string
+	Computed float64
+	External float64
+}
+
+type Reconciliation struct {
+	Agreed        int
+	Disagreements []Disagreement
+	OnlyGradebook []string
+	OnlyExternal  []string
+}
+
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	tolerance := fs.Float64("tolerance", 0.01, "Largest difference between totals still counted as agreement")
+	registration := fs.String("registration", "", "Registration roster (EmpID, and Branch or Campus ID) to check the marks sheet against instead of a totals file")
+	out := fs.String("o", "", "With -registration, also write the discrepancies to this CSV file")
+	fs.Parse(args)
+
+	if *registration != "" && fs.NArg() == 1 {
+		runRegistrationReconcile(fs.Arg(0), *registration, *out)
+		return
+	}
+	if fs.NArg() < 2 {
+		fmt.Println("Usage: go run . reconcile [-tolerance 0.01] <path-to-excel-file> <totals.csv|totals.xlsx>")
+		fmt.Println("       go run . reconcile -registration <roster.csv|roster.xlsx> [-o discrepancies.csv] <path-to-excel-file>")
+		return
+	}
+
+	students, layout, _, err := parseExcel(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	totals, err := loadTotals(fs.Arg(1))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	rec := reconcile(students, layout.componentSet(), totals, *tolerance)
+	fmt.Printf("\nReconciliation of %s against %s\n", fs.Arg(0), fs.Arg(1))
+	fmt.Printf("Agreed: %d\n", rec.Agreed)
+	fmt.Printf("Disagreed: %d\n", len(rec.Disagreements))
+	for _, d := range rec.Disagreements {
+		fmt.Printf("  EmpID %s | computed %s | totals file %s | difference %s\n", maskID(d.EmpID),
+			formatNumber(d.Computed), formatNumber(d.External), formatNumber(d.Computed-d.External))
+	}
+	fmt.Printf("Only in gradebook: %d\n", len(rec.OnlyGradebook))
+	for _, id := range rec.OnlyGradebook {
+		fmt.Println("  EmpID", maskID(id))
+	}
+	fmt.Printf("Only in totals file: %d\n", len(rec.OnlyExternal))
+	for _, id := range rec.OnlyExternal {
+		fmt.Println("  EmpID", maskID(id))
+	}
+
+	if len(rec.Disagreements)+len(rec.OnlyGradebook)+len(rec.OnlyExternal) > 0 {
+		os.Exit(1)
+	}
+}
+
+// reconcile compares each student's computed total with the total the
+// other source reports for the same EmpID.
+func reconcile(students []Student, set ComponentSet, totals map[string]float64, tolerance float64) Reconciliation {
+	var rec Reconciliation
+	seen := make(map[string]bool, len(students))
+	for _, s := range students {
+		seen[s.EmpID] = true
+		external, ok := totals[s.EmpID]
+		if !ok {
+			rec.OnlyGradebook = append(rec.OnlyGradebook, s.EmpID)
+			continue
+		}
+		computed := set.total(s)
+		if math.Abs(computed-external) > tolerance {
+			rec.Disagreements = append(rec.Disagreements, Disagreement{EmpID: s.EmpID, Computed: computed, External: external})
+			continue
+		}
+		rec.Agreed++
+	}
+	for id := range totals {
+		if !seen[id] {
+			rec.OnlyExternal = append(rec.OnlyExternal, id)
+		}
+	}
+	sort.Strings(rec.OnlyExternal)
+	return rec
+}
+
+// readRecords reads the rows of a CSV file or of the first sheet of a
+// workbook; what names the file in errors.
+func readRecords(path, what string) ([][]string, error) {
+	var records [][]string
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		if records, err = r.ReadAll(); err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %w", what, path, err)
+		}
+	} else {
+		f, err := openWorkbook(path)
+		if err != nil {
+			return nil, err
+		}
+		defer closeWorkbook(f)
+		if records, err = f.GetRows(f.GetSheetName(0)); err != nil {
+			return nil, err
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s %s is empty", what, path)
+	}
+	return records, nil
+}
+
+// loadTotals reads a CSV or the first sheet of a workbook whose header row
+// names an EmpID column and a Total column.
+func loadTotals(path string) (map[string]float64, error) {
+	records, err := readRecords(path, "totals file")
+	if err != nil {
+		return nil, err
+	}
+
+	empCol, totalCol := -1, -1
+	for col, header := range records[0] {
+		switch normalizeHeader(header) {
+		case "empid", "emplid", "employeeid":
+			empCol = col
+		case "total", "finaltotal", "totalmarks", "marks":
+			totalCol = col
+		}
+	}
+	if empCol < 0 || totalCol < 0 {
+		return nil, fmt.Errorf("totals file %s needs EmpID and Total columns", path)
+	}
+
+	totals := make(map[string]float64, len(records)-1)
+	for i, record := range records[1:] {
+		if empCol >= len(record) || strings.TrimSpace(record[empCol]) == "" {
+			continue
+		}
+		id := strings.TrimSpace(record[empCol])
+		if _, dup := totals[id]; dup {
+			return nil, fmt.Errorf("totals file %s: row %d: duplicate EmpID %s", path, i+2, id)
+		}
+		var raw string
+		if totalCol < len(record) {
+			raw = strings.TrimSpace(record[totalCol])
+		}
+		v, err := parseMark(raw)
+		if err != nil || raw == "" {
+			return nil, fmt.Errorf("totals file %s: row %d: invalid total %q for EmpID %s%s", path, i+2, raw, id, markHint(raw))
+		}
+		totals[id] = v
+	}
+	return totals, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Registration struct {
+	EmpID  string
+	Name   string
+	Branch string
+	Row    int
+}
+
+type BranchMismatch struct {
+	Registration Registration
+	Student      Student
+}
+
+// RosterReconciliation compares the registration roster with the students
+// of a marks sheet, by EmpID.
+type RosterReconciliation struct {
+	Matched          int
+	Unmarked         []Registration
+	Unregistered     []Student
+	BranchMismatches []BranchMismatch
+}
+
+// loadRegistrations reads a registration roster: a CSV or the first sheet
+// of a workbook whose header row names an EmpID column, and a Branch or
+// Campus ID column to check branch codes against. Without a Branch column
+// the branch is taken from the Campus ID, as for the marks sheet.
+func loadRegistrations(path string, cfg BranchConfig) ([]Registration, error) {
+	records, err := readRecords(path, "registration roster")
+	if err != nil {
+		return nil, err
+	}
+
+	empCol, nameCol, campusCol, branchCol := -1, -1, -1, -1
+	for col, header := range records[0] {
+		switch normalizeHeader(header) {
+		case "empid", "emplid", "employeeid":
+			empCol = col
+		case "name", "studentname", "fullname":
+			nameCol = col
+		case "campusid", "idno", "idnumber":
+			campusCol = col
+		case "branch", "branchcode", "program":
+			branchCol = col
+		}
+	}
+	if empCol < 0 {
+		return nil, fmt.Errorf("registration roster %s needs an EmpID column", path)
+	}
+
+	field := func(record []string, col int) string {
+		if col < 0 || col >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[col])
+	}
+	cfg.Column = ""
+	branches := newBranchExtractor(cfg, Layout{Branch: -1})
+	seen := make(map[string]int, len(records)-1)
+	var regs []Registration
+	for i, record := range records[1:] {
+		id := field(record, empCol)
+		if id == "" {
+			continue
+		}
+		if row, dup := seen[id]; dup {
+			return nil, fmt.Errorf("registration roster %s: row %d: EmpID %s is already on row %d", path, i+2, id, row)
+		}
+		seen[id] = i + 2
+		reg := Registration{EmpID: id, Name: field(record, nameCol), Row: i + 2}
+		if branchCol >= 0 {
+			reg.Branch = strings.ToUpper(field(record, branchCol))
+		} else if campusCol >= 0 {
+			reg.Branch, _ = branches.extract(nil, field(record, campusCol))
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+// reconcileRegistrations finds the students registered but missing from
+// the marks sheet, those with marks but not registered, and those whose
+// branch codes differ. A registration without a branch is not compared.
+func reconcileRegistrations(students []Student, regs []Registration) RosterReconciliation {
+	var rec RosterReconciliation
+	registered := make(map[string]Registration, len(regs))
+	for _, reg := range regs {
+		registered[reg.EmpID] = reg
+	}
+	marked := make(map[string]bool, len(students))
+	for _, s := range students {
+		marked[s.EmpID] = true
+		reg, ok := registered[s.EmpID]
+		if !ok {
+			rec.Unregistered = append(rec.Unregistered, s)
+			continue
+		}
+		rec.Matched++
+		if reg.Branch != "" && reg.Branch != s.Branch {
+			rec.BranchMismatches = append(rec.BranchMismatches, BranchMismatch{Registration: reg, Student: s})
+		}
+	}
+	for _, reg := range regs {
+		if !marked[reg.EmpID] {
+			rec.Unmarked = append(rec.Unmarked, reg)
+		}
+	}
+	return rec
+}
+
+func (rec RosterReconciliation) discrepancies() int {
+	return len(rec.Unmarked) + len(rec.Unregistered) + len(rec.BranchMismatches)
+}
+
+func runRegistrationReconcile(sheetPath, rosterPath, out string) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	students, _, _, err := parseExcel(sheetPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	regs, err := loadRegistrations(rosterPath, cfg.Branch)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	rec := reconcileRegistrations(students, regs)
+	fmt.Printf("\nReconciliation of %s against registration roster %s\n", sheetPath, rosterPath)
+	fmt.Printf("Registered: %d | In marks sheet: %d | Matched: %d\n", len(regs), len(students), rec.Matched)
+	fmt.Printf("Registered but missing marks: %d\n", len(rec.Unmarked))
+	for _, reg := range rec.Unmarked {
+		line := "  EmpID " + maskID(reg.EmpID)
+		if reg.Name != "" {
+			line += " | " + reg.Name
+		}
+		if reg.Branch != "" {
+			line += " | branch " + reg.Branch
+		}
+		fmt.Printf("%s | roster row %d\n", line, reg.Row)
+	}
+	fmt.Printf("With marks but not registered: %d\n", len(rec.Unregistered))
+	for _, s := range rec.Unregistered {
+		fmt.Printf("  EmpID %s | CampusID %s | row %d\n", maskID(s.EmpID), maskID(s.CampusID), s.Row)
+	}
+	fmt.Printf("Branch mismatches: %d\n", len(rec.BranchMismatches))
+	for _, m := range rec.BranchMismatches {
+		fmt.Printf("  EmpID %s | registered %s (roster row %d) | marks sheet %s (row %d)\n", maskID(m.Student.EmpID),
+			m.Registration.Branch, m.Registration.Row, m.Student.Branch, m.Student.Row)
+	}
+
+	if out != "" {
+		if err := writeRegistrationCSV(out, rec); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Discrepancies written to", out)
+	}
+	if rec.discrepancies() > 0 {
+		os.Exit(1)
+	}
+}
+
+// writeRegistrationCSV writes one line per discrepancy, for sorting and
+// filtering in a spreadsheet.
+func writeRegistrationCSV(path string, rec RosterReconciliation) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"issue", "emp_id", "name", "registered_branch", "sheet_branch", "roster_row", "sheet_row"})
+	for _, reg := range rec.Unmarked {
+		w.Write([]string{"missing-marks", reg.EmpID, reg.Name, reg.Branch, "", strconv.Itoa(reg.Row), ""})
+	}
+	for _, s := range rec.Unregistered {
+		w.Write([]string{"not-registered", s.EmpID, s.Name, "", s.Branch, "", strconv.Itoa(s.Row)})
+	}
+	for _, m := range rec.BranchMismatches {
+		w.Write([]string{"branch-mismatch", m.Student.EmpID, m.Registration.Name, m.Registration.Branch, m.Student.Branch,
+			strconv.Itoa(m.Registration.Row), strconv.Itoa(m.Student.Row)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
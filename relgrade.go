@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var relativeGrading string
+
+// GradeQuota is the share (in percent) of each branch or section that gets
+// a grade under relative grading, e.g. {"grade": "A", "pct": 10}. Grades
+// without a quota get none, except the lowest, which takes what is left.
+type GradeQuota struct {
+	Grade string  `json:"grade"`
+	Pct   float64 `json:"pct"`
+}
+
+func validateRelativeGrading(mode string) error {
+	switch mode {
+	case "", "branch", "section":
+		return nil
+	}
+	return fmt.Errorf("invalid -relative-grading %q (want branch or section)", mode)
+}
+
+func validateGradeQuotas(quotas []GradeQuota, grades []GradeBoundary) error {
+	known := make(map[string]bool, len(grades))
+	for _, g := range grades {
+		known[g.Grade] = true
+	}
+	sum := 0.0
+	for _, q := range quotas {
+		if !known[q.Grade] {
+			return fmt.Errorf("grade quota for unknown grade %q", q.Grade)
+		}
+		if q.Pct < 0 {
+			return fmt.Errorf("grade quota for %s is negative", q.Grade)
+		}
+		sum += q.Pct
+	}
+	if sum > 100+1e-9 {
+		return fmt.Errorf("grade quotas add up to %g%%, more than 100%%", sum)
+	}
+	return nil
+}
+
+// RelativeGrade compares a student's grade under the course-wide boundaries
+// with the grade they get relative to their own branch or section.
+type RelativeGrade struct {
+	Student  Student
+	Group    string
+	Global   GradeBoundary
+	Relative GradeBoundary
+}
+
+// relativeGrades grades every group separately. Within a group the best
+// students get each grade in proportion to the quotas, or, without quotas,
+// in the proportions the whole course gets under the global boundaries.
+// Students tied at a cut all get the better grade. It also returns the
+// lowest percentage that earned each grade in each group.
+func relativeGrades(cfg Config, set ComponentSet, students []Student, group func(Student) string) ([]RelativeGrade, map[string][]GradeBoundary) {
+	grades := append([]GradeBoundary(nil), cfg.Grades...)
+	sort.SliceStable(grades, func(i, j int) bool { return grades[i].MinPct > grades[j].MinPct })
+	course := cfg.course(courseName)
+	pct := func(s Student) float64 { return set.total(s) / course.MaxTotal * 100 }
+
+	out := make([]RelativeGrade, len(students))
+	shares := make([]float64, len(grades))
+	index := make(map[string]int, len(grades))
+	for i, g := range grades {
+		index[g.Grade] = i
+	}
+	for i, s := range students {
+		out[i] = RelativeGrade{Student: s, Group: group(s), Global: cfg.grade(pct(s))}
+		if len(cfg.Quotas) == 0 {
+			if j, ok := index[out[i].Global.Grade]; ok {
+				shares[j] += 100 / float64(len(students))
+			}
+		}
+	}
+	for _, q := range cfg.Quotas {
+		shares[index[q.Grade]] += q.Pct
+	}
+
+	members := make(map[string][]int)
+	for i := range out {
+		members[out[i].Group] = append(members[out[i].Group], i)
+	}
+	boundaries := make(map[string][]GradeBoundary, len(members))
+	for name, idx := range members {
+		sort.SliceStable(idx, func(a, b int) bool { return pct(students[idx[a]]) > pct(students[idx[b]]) })
+		n, pos, cum := len(idx), 0, 0.0
+		for gi, g := range grades {
+			cum += shares[gi]
+			end := max(pos, int(math.Round(cum/100*float64(n))))
+			if gi == len(grades)-1 {
+				end = n
+			}
+			for end > pos && end < n && pct(students[idx[end]]) == pct(students[idx[end-1]]) {
+				end++
+			}
+			for _, i := range idx[pos:end] {
+				out[i].Relative = g
+			}
+			if end > pos {
+				b := g
+				b.MinPct = pct(students[idx[end-1]])
+				boundaries[name] = append(boundaries[name], b)
+			}
+			pos = end
+		}
+	}
+	return out, boundaries
+}
+
+func printRelativeGrading(cfg Config, set ComponentSet, students []Student, mode string) {
+	group := func(s Student) string { return s.Branch }
+	label := "Branch"
+	if mode == "section" {
+		group = func(s Student) string { return s.ClassNo }
+		label = "Section"
+	}
+	results, boundaries := relativeGrades(cfg, set, students, group)
+
+	var names []string
+	for name := range boundaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\nRelative Grading (per %s):\n", mode)
+	for _, name := range names {
+		up, down, n := 0, 0, 0
+		for _, r := range results {
+			if r.Group != name {
+				continue
+			}
+			n++
+			switch {
+			case r.Relative.Points > r.Global.Points:
+				up++
+			case r.Relative.Points < r.Global.Points:
+				down++
+			}
+		}
+		fmt.Printf("\n%s %s (%d students): %d higher, %d lower than with global boundaries\n", label, name, n, up, down)
+		for _, b := range boundaries[name] {
+			fmt.Printf("  %-3s from %s%%\n", b.Grade, formatNumber(b.MinPct))
+		}
+	}
+
+	fmt.Println("\nGrade changes (global -> relative):")
+	changed := 0
+	for _, r := range results {
+		if r.Relative.Grade == r.Global.Grade {
+			continue
+		}
+		changed++
+		fmt.Printf("EmpID: %s | %s: %s | Total: %s | %s -> %s\n", maskID(r.Student.EmpID), label, r.Group,
+			formatNumber(set.total(r.Student)), r.Global.Grade, r.Relative.Grade)
+	}
+	if changed == 0 {
+		fmt.Println("No grade changes.")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isExport tells whether path is a JSON report to read back in, rather
+// than a sheet.
+func isExport(path string) bool {
+	if fromJSON {
+		return true
+	}
+	return inputFormat == "auto" && strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// exportedComponent records a discovered or mapped component in an export,
+// so that reading the export back restores it.
+type exportedComponent struct {
+	Name string  `json:"name"`
+	Max  float64 `json:"max"`
+}
+
+// loadExportedStudents reads a JSON report written by -export (or a stored
+// run export) back in as input, so saved reports can be re-analysed without
+// the original workbook.
+func loadExportedStudents(path string) ([]Student, Layout, error) {
+	run, err := loadExportedRun(path)
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	if len(run.Students) == 0 {
+		return nil, Layout{}, fmt.Errorf("%s contains no students", path)
+	}
+	if err := checkStudentLimit(len(run.Students) - maxHeaderRows); err != nil {
+		return nil, Layout{}, err
+	}
+	if courseName == "" {
+		courseName = run.Course
+	}
+	name := path
+	if path == inputCopy {
+		name = inputName
+	}
+	fmt.Printf("Loaded %d students from exported report %s\n", len(run.Students), name)
+
+	for i := range run.Students {
+		if run.Students[i].Marks == nil {
+			run.Students[i].Marks = make(map[string]float64)
+		}
+	}
+
+	layout := defaultLayout()
+	layout.Items = exportedItems(path, run.Students)
+	set := run.componentSet()
+	layout.Components, layout.Max = set.Names, set.Max
+	return run.Students, layout, nil
+}
+
+// exportedItems returns the item columns recorded in the export, or rebuilds
+// them from the "Exam Name" keys stored on each student for older exports.
+// Rebuilt items have no maximum, so item analysis falls back to the highest
+// observed score.
+func exportedItems(path string, students []Student) []ItemColumn {
+	var export struct {
+		Items []ItemColumn `json:"items"`
+	}
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &export) == nil && len(export.Items) > 0 {
+		return export.Items
+	}
+
+	seen := make(map[string]bool)
+	var items []ItemColumn
+	for _, s := range students {
+		for key := range s.Items {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			exam, name := "Exam", key
+			if i := strings.LastIndex(key, " "); i >= 0 {
+				exam, name = key[:i], key[i+1:]
+			}
+			items = append(items, ItemColumn{Exam: exam, Name: name, Col: -1})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key() < items[j].Key() })
+	return items
+}
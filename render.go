@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var perBranch bool
+
+// Report is what a run shows, computed once from the students and their
+// analysis and then handed to every Renderer, so that the console and each
+// export agree on the numbers.
+type Report struct {
+	Course string
+	// Branch is set on the reports of -per-branch exports, which hold one
+	// branch's students (see branchReports).
+	Branch string
+	// Students are in ranking order, with Total set; Ranks are their
+	// ranks by total, equal totals sharing one (1, 2, 2, 4).
+	Students []Student
+	Ranks    []int
+	// Set is the components the students were marked in.
+	Set ComponentSet
+	// Marks are the marks exported per student: the components and, when
+	// the sheet has one, its Final Total.
+	Marks      []string
+	Components []MarkStats
+	// Columns summarise the item and -computed columns, over the students
+	// that have them.
+	Columns []MarkStats
+	// Extra are the sheet's extra columns exported per student, with
+	// -keep-columns.
+	Extra []string
+	// TotalStats summarise the computed totals.
+	TotalStats MarkStats
+	Branches   []BranchStats
+	Issues     []Issue
+	Items      []ItemColumn
+	Queries    []QueryResult
+	Manifest   Manifest
+	Failure    *PartialError
+	// Alerts are the alert rules the run set off; any make it need
+	// attention.
+	Alerts []Alert
+}
+
+// MarkStats summarise one column of marks.
+type MarkStats struct {
+	Name   string
+	Mean   float64
+	P25    float64
+	Median float64
+	P75    float64
+	Min    float64
+	Max    float64
+	StdDev float64
+}
+
+// Renderer presents a Report in one format.
+type Renderer interface {
+	Render(rep *Report) error
+}
+
+func markStats(name string, col []float64) MarkStats {
+	return MarkStats{Name: name, Mean: mean(col), P25: percentile(col, 25), Median: percentile(col, 50), P75: percentile(col, 75),
+		Min: minOf(col), Max: maxOf(col), StdDev: stdDev(col)}
+}
+
+func minOf(col []float64) float64 {
+	if len(col) == 0 {
+		return 0
+	}
+	return slices.Min(col)
+}
+
+func maxOf(col []float64) float64 {
+	if len(col) == 0 {
+		return 0
+	}
+	return slices.Max(col)
+}
+
+// buildReport gathers the report on students, already ranked (see
+// rankStudents), from the analysis of them.
+func buildReport(students []Student, a analysis) *Report {
+	rep := &Report{Course: courseName, Students: students, Set: a.Set, Marks: markNames(students, a.Set)}
+	if rep.Students == nil {
+		rep.Students = []Student{}
+	}
+
+	totals := make([]float64, len(students))
+	for i, s := range students {
+		totals[i] = s.Total
+	}
+	rep.Ranks = competitionRanks(totals)
+	rep.TotalStats = markStats("Computed Total", totals)
+	for _, name := range a.Table.Names {
+		rep.Components = append(rep.Components, markStats(name, a.Table.Column(name)))
+	}
+	cols := make(map[string][]float64)
+	var names []string
+	for _, s := range students {
+		for _, values := range []map[string]float64{s.Items, s.Computed} {
+			for name, v := range values {
+				if _, ok := cols[name]; !ok {
+					names = append(names, name)
+				}
+				cols[name] = append(cols[name], v)
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rep.Columns = append(rep.Columns, markStats(name, cols[name]))
+	}
+
+	for branch, total := range a.BranchTotal {
+		n := a.BranchCount[branch]
+		b := BranchStats{Branch: branch, Students: n, AverageTotal: total / float64(n), Means: make(map[string]float64)}
+		for j, name := range a.Table.Names {
+			b.Means[name] = a.BranchMarks[branch][j] / float64(n)
+		}
+		rep.Branches = append(rep.Branches, b)
+	}
+	sort.Slice(rep.Branches, func(i, j int) bool { return rep.Branches[i].Branch < rep.Branches[j].Branch })
+	return rep
+}
+
+// stats finds the statistics of a column named as a template names it (see
+// normalizeHeader): Total, a component, or an item or -computed column.
+func (rep *Report) stats(name string) (MarkStats, bool) {
+	key := normalizeHeader(name)
+	if key == "total" {
+		return rep.TotalStats, true
+	}
+	for _, stats := range [][]MarkStats{rep.Components, rep.Columns} {
+		for _, m := range stats {
+			if normalizeHeader(m.Name) == key {
+				return m, true
+			}
+		}
+	}
+	return MarkStats{}, false
+}
+
+// components are the statistics of the marks exported per student.
+func (rep *Report) components() []MarkStats {
+	var stats []MarkStats
+	for _, c := range rep.Components {
+		if slices.Contains(rep.Marks, c.Name) {
+			stats = append(stats, c)
+		}
+	}
+	return stats
+}
+
+// consoleRenderer prints the averages and the top of the rankings.
+type consoleRenderer struct{}
+
+func (consoleRenderer) Render(rep *Report) error {
+	fmt.Println("\nAverage Marks per Component:")
+	for _, c := range rep.Components {
+		fmt.Printf("%s: %s (P25 %s, median %s, P75 %s)\n", c.Name, formatNumber(c.Mean), formatNumber(c.P25), formatNumber(c.Median), formatNumber(c.P75))
+	}
+
+	fmt.Println("\nBranch-wise Averages:")
+	for _, b := range rep.Branches {
+		fmt.Printf("Branch %s: %s\n", b.Branch, formatNumber(b.AverageTotal))
+	}
+
+	order := ""
+	if sortSpec != "" {
+		order = " (by " + sortSpec + ")"
+	}
+	fmt.Printf("\nOverall Top 3 Students%s:\n", order)
+	for i := 0; i < 3 && i < len(rep.Students); i++ {
+		fmt.Printf("%d. EmpID: %s | Computed Total: %s\n", i+1, maskID(rep.Students[i].EmpID), formatNumber(rep.Students[i].Total))
+	}
+
+	byBranch := make(map[string][]Student)
+	for _, s := range rep.Students {
+		byBranch[s.Branch] = append(byBranch[s.Branch], s)
+	}
+	fmt.Printf("\nTop 3 Students per Branch%s:\n", order)
+	for _, b := range rep.Branches {
+		fmt.Printf("\nBranch %s:\n", b.Branch)
+		for i := 0; i < 3 && i < len(byBranch[b.Branch]); i++ {
+			fmt.Printf("%d. EmpID: %s | Computed Total: %s\n", i+1, maskID(byBranch[b.Branch][i].EmpID), formatNumber(byBranch[b.Branch][i].Total))
+		}
+	}
+	return nil
+}
+
+// renderers returns the file renderers asked for on the command line or in
+// the config's export preferences.
+func renderers() []Renderer {
+	return branchRenderers("")
+}
+
+// branchRenderers returns the file renderers for one branch's report, their
+// file names marked with the branch, e.g. output-A7.json; for "" they are
+// the renderers of the whole report.
+func branchRenderers(branch string) []Renderer {
+	name := func(path string) string {
+		if branch == "" {
+			return path
+		}
+		ext := filepath.Ext(path)
+		return strings.TrimSuffix(path, ext) + "-" + branch + ext
+	}
+	var rs []Renderer
+	if exportJSON {
+		rs = append(rs, jsonRenderer{name("output.json")})
+	}
+	if exportCSV {
+		rs = append(rs, csvRenderer{name("students.csv"), name("mismatches.csv")})
+	}
+	if exportXLSX {
+		rs = append(rs, xlsxRenderer{name("output.xlsx")})
+	}
+	if exportHTML {
+		rs = append(rs, htmlRenderer{name("output.html")})
+	}
+	if pdfOut != "" {
+		rs = append(rs, pdfRenderer{name(pdfOut)})
+	}
+	return rs
+}
+
+// branchReports splits the report by branch, for -per-branch, so that each
+// branch coordinator is sent only their own students' marks: each report
+// has the branch's students, ranked within it, their statistics, and the
+// issues about them. Issues about the sheet as a whole, query results and
+// alerts, which draw on every branch, are left out.
+func branchReports(rep *Report) []*Report {
+	var reps []*Report
+	for _, b := range rep.Branches {
+		var students []Student
+		ids := make(map[string]bool)
+		rows := make(map[int]bool)
+		for _, s := range rep.Students {
+			if s.Branch == b.Branch {
+				students = append(students, s)
+				ids[s.EmpID] = true
+				rows[s.Row] = true
+			}
+		}
+		sub := buildReport(students, analyze(students, rep.Set))
+		sub.Branch = b.Branch
+		for _, issue := range rep.Issues {
+			if ids[issue.EmpID] || issue.EmpID == "" && issue.Row > 0 && rows[issue.Row] {
+				sub.Issues = append(sub.Issues, issue)
+			}
+		}
+		sub.Items, sub.Manifest, sub.Failure, sub.Extra = rep.Items, rep.Manifest, rep.Failure, rep.Extra
+		reps = append(reps, sub)
+	}
+	return reps
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renderReportCard renders a student's report card. notes are the run's
+// notes; the card shows those on the whole run and on the student.
+func renderReportCard(cfg Config, run Run, s Student, notes []Note) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Report Card\n")
+	fmt.Fprintf(&b, "Course: %s\n", run.Course)
+	if s.Name != "" {
+		fmt.Fprintf(&b, "Name: %s\n", s.Name)
+	}
+	fmt.Fprintf(&b, "EmpID: %s\n", s.EmpID)
+	fmt.Fprintf(&b, "Campus ID: %s\n", s.CampusID)
+	fmt.Fprintf(&b, "Branch: %s\n\n", s.Branch)
+	set := run.componentSet()
+	for _, comp := range set.names() {
+		fmt.Fprintf(&b, "%-12s %7s / %.0f\n", comp+":", formatNumber(s.Marks[comp]), set.max(comp))
+	}
+	fmt.Fprintf(&b, "%-12s %7s\n", "Total:", formatNumber(s.Total))
+	trace := traceGrade(cfg, run, s)
+	fmt.Fprintf(&b, "%-12s %7s\n", "Grade:", trace.Grade)
+	fmt.Fprintf(&b, "\nHow this grade was derived:\n%s", trace.Text())
+	if notes := studentNotes(notes, s.EmpID); len(notes) > 0 {
+		fmt.Fprintf(&b, "\nNotes:\n")
+		for _, n := range notes {
+			fmt.Fprintf(&b, "- %s (%s, %s)\n", n.Text, n.Author, n.CreatedAt.Format("2006-01-02"))
+		}
+	}
+	if source := s.provenance(); source != "" {
+		fmt.Fprintf(&b, "\nSource: %s\n", source)
+	}
+	if footer := verificationFooter(cfg, run, s); footer != "" {
+		b.WriteString("\n" + footer)
+	}
+	return b.String()
+}
+
+func writeReportCards(cfg Config, run Run, notes []Note, dir string) (int, error) {
+	if err := requirePublished(run); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	for _, s := range run.Students {
+		path := filepath.Join(dir, s.EmpID+".txt")
+		if err := os.WriteFile(path, []byte(renderReportCard(cfg, run, s, notes)), 0o644); err != nil {
+			return 0, err
+		}
+	}
+	return len(run.Students), nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type researchColumn struct {
+	Name, Description string
+	// Mark is the student mark the column holds, empty for the columns
+	// that are not marks.
+	Mark string
+}
+
+// researchColumns are the dataset's columns: the pseudonym and the
+// generalized quasi-identifiers, then a column named like sqlColumn for
+// each of the set's components and, when the sheet has one, its Final
+// Total, then the total computed from the set.
+func researchColumns(set ComponentSet, students []Student) []researchColumn {
+	cols := []researchColumn{
+		{Name: "pseudo_id", Description: "Random pseudonym, stable only within this export"},
+		{Name: "branch", Description: "Two-character branch code; OTHER when pooled for k-anonymity"},
+		{Name: "admit_year", Description: "Admission year from Campus ID; * when generalized for k-anonymity"},
+	}
+	parts := set.totalParts()
+	totalMax := 0.0
+	for _, name := range parts {
+		totalMax += set.max(name)
+	}
+	for _, name := range markNames(students, set) {
+		desc := fmt.Sprintf("%s marks (max %s)", name, formatMark(set.max(name)))
+		switch name {
+		case "Pre-Compre":
+			desc = fmt.Sprintf("Pre-Comprehensive total as recorded in the sheet (max %s)", formatMark(set.max(name)))
+		case "Final Total":
+			desc = fmt.Sprintf("Final total as recorded in the sheet (max %s)", formatMark(totalMax))
+		}
+		cols = append(cols, researchColumn{Name: sqlColumn(name), Description: desc, Mark: name})
+	}
+	return append(cols, researchColumn{Name: "computed_total",
+		Description: strings.Join(parts, " + ") + " as computed by this tool"})
+}
+
+type researchRecord struct {
+	pseudoID string
+	branch   string
+	year     string
+	student  Student
+}
+
+func runResearch(args []string) {
+	fs := flag.NewFlagSet("research", flag.ExitOnError)
+	outDir := fs.String("o", "research", "Output directory")
+	k := fs.Int("k", 5, "Minimum group size for branch/admission-year combinations")
+	salt := fs.String("salt", "", "Pseudonym salt (random when empty, making the export unlinkable)")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: go run . research [flags] <path-to-excel-file>")
+		return
+	}
+
+	students, layout, _, err := parseExcel(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	set := layout.componentSet()
+	for i := range students {
+		students[i].Total = set.total(students[i])
+	}
+
+	key := []byte(*salt)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	records, notes := anonymize(students, key, *k)
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Println("Error creating output directory:", err)
+		return
+	}
+	cols := researchColumns(set, students)
+	if err := writeResearchCSV(filepath.Join(*outDir, "research.csv"), cols, records); err != nil {
+		fmt.Println("Error writing research dataset:", err)
+		return
+	}
+	if err := writeDataDictionary(filepath.Join(*outDir, "data_dictionary.md"), cols, len(records), *k, notes); err != nil {
+		fmt.Println("Error writing data dictionary:", err)
+		return
+	}
+
+	fmt.Println("\nk-Anonymity Check:")
+	for _, note := range notes {
+		fmt.Println(note)
+	}
+	fmt.Printf("Research dataset with %d records exported to %s\n", len(records), *outDir)
+}
+
+func anonymize(students []Student, key []byte, k int) ([]researchRecord, []string) {
+	records := make([]researchRecord, len(students))
+	for i, s := range students {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(s.EmpID))
+		year := ""
+		if len(s.CampusID) >= 4 {
+			year = s.CampusID[:4]
+		}
+		records[i] = researchRecord{pseudoID: hex.EncodeToString(mac.Sum(nil))[:12], branch: s.Branch, year: year, student: s}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].pseudoID < records[j].pseudoID })
+
+	var notes []string
+	groupSizes := func() map[string]int {
+		sizes := make(map[string]int)
+		for _, r := range records {
+			sizes[r.branch+"/"+r.year]++
+		}
+		return sizes
+	}
+	smallest := func(sizes map[string]int) int {
+		least := len(records)
+		for _, n := range sizes {
+			least = min(least, n)
+		}
+		return least
+	}
+
+	if smallest(groupSizes()) >= k {
+		return records, append(notes, fmt.Sprintf("All branch/admission-year groups have at least %d students; no generalization needed.", k))
+	}
+
+	for i := range records {
+		records[i].year = "*"
+	}
+	notes = append(notes, "Admission year generalized to * for all records.")
+
+	sizes := groupSizes()
+	var pooled []string
+	for i, r := range records {
+		if sizes[r.branch+"/*"] < k {
+			if !containsString(pooled, r.branch) {
+				pooled = append(pooled, r.branch)
+			}
+			records[i].branch = "OTHER"
+		}
+	}
+	if len(pooled) > 0 {
+		sort.Strings(pooled)
+		notes = append(notes, fmt.Sprintf("Branches with fewer than %d students pooled into OTHER: %s", k, strings.Join(pooled, ", ")))
+	}
+
+	if groupSizes()["OTHER/*"] > 0 && groupSizes()["OTHER/*"] < k {
+		kept := records[:0]
+		suppressed := 0
+		for _, r := range records {
+			if r.branch == "OTHER" {
+				suppressed++
+				continue
+			}
+			kept = append(kept, r)
+		}
+		records = kept
+		notes = append(notes, fmt.Sprintf("OTHER group still below %d; suppressed %d records.", k, suppressed))
+	}
+
+	return records, notes
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeResearchCSV(path string, cols []researchColumn, records []researchRecord) error {
+	rows := make([][]string, 0, len(records)+1)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	rows = append(rows, header)
+
+	for _, r := range records {
+		row := []string{r.pseudoID, r.branch, r.year}
+		for _, c := range cols[len(row) : len(cols)-1] {
+			row = append(row, formatMark(r.student.Marks[c.Mark]))
+		}
+		rows = append(rows, append(row, formatMark(r.student.Total)))
+	}
+	return writeCSV(path, rows)
+}
+
+func writeDataDictionary(path string, cols []researchColumn, count, k int, notes []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Research Dataset: Data Dictionary\n\n")
+	fmt.Fprintf(&b, "Generated: %s\n\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&b, "Records: %d\n\n", count)
+	fmt.Fprintf(&b, "Student identifiers (EmpID, Campus ID, class number) and free-text comments are removed. ")
+	fmt.Fprintf(&b, "Pseudonyms are keyed hashes and cannot be linked across exports made with different salts.\n\n")
+	fmt.Fprintf(&b, "## Columns\n\n| Column | Description |\n|---|---|\n")
+	for _, c := range cols {
+		fmt.Fprintf(&b, "| %s | %s |\n", c.Name, c.Description)
+	}
+	fmt.Fprintf(&b, "\n## Disclosure Control (k = %d)\n\n", k)
+	for _, note := range notes {
+		fmt.Fprintf(&b, "- %s\n", note)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+)
+
+type RosterConfig struct {
+	// AllowedDomains limits student emails to these domains (and their
+	// subdomains); empty allows any domain.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+type Contact struct {
+	Name    string
+	Email   string
+	Advisor string
+}
+
+// Roster is the official list of enrolled students given by -roster.
+type Roster struct {
+	Contacts map[string]Contact
+	// Emails is whether the roster has an Email column. Without one it is
+	// only checked against the sheet, not mailed.
+	Emails bool
+}
+
+// loadRoster reads a CSV roster with a header row naming at least the EmpID
+// column; Name, Email and Advisor columns are optional.
+func loadRoster(path string) (Roster, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Roster{}, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return Roster{}, fmt.Errorf("invalid roster %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return Roster{}, fmt.Errorf("roster %s is empty", path)
+	}
+
+	empCol, nameCol, emailCol, advisorCol := -1, -1, -1, -1
+	for col, header := range records[0] {
+		switch normalizeHeader(header) {
+		case "empid", "emplid", "employeeid":
+			empCol = col
+		case "name", "studentname", "fullname":
+			nameCol = col
+		case "email", "emailid", "emailaddress", "mail":
+			emailCol = col
+		case "advisor", "facultyadvisor", "mentor":
+			advisorCol = col
+		}
+	}
+	if empCol < 0 {
+		return Roster{}, fmt.Errorf("roster %s needs an EmpID column", path)
+	}
+
+	field := func(record []string, col int) string {
+		if col < 0 || col >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[col])
+	}
+	roster := Roster{Contacts: make(map[string]Contact, len(records)-1), Emails: emailCol >= 0}
+	for _, record := range records[1:] {
+		if id := field(record, empCol); id != "" {
+			roster.Contacts[id] = Contact{Name: field(record, nameCol), Email: field(record, emailCol), Advisor: field(record, advisorCol)}
+		}
+	}
+	return roster, nil
+}
+
+// joinRoster copies names and emails onto students and flags anyone not
+// enrolled, or who could not be mailed a report card.
+func joinRoster(students []Student, roster Roster, cfg RosterConfig) []Issue {
+	var issues []Issue
+	for i := range students {
+		s := &students[i]
+		contact, ok := roster.Contacts[s.EmpID]
+		if !ok {
+			issues = append(issues, studentWarning("contact", *s, "EmpID %s is not on the roster", s.EmpID))
+			continue
+		}
+		s.Name, s.Email, s.Advisor = contact.Name, contact.Email, contact.Advisor
+		if !roster.Emails {
+			continue
+		}
+		if msg := checkEmail(contact.Email, cfg.AllowedDomains); msg != "" {
+			issues = append(issues, studentWarning("contact", *s, "EmpID %s: %s", s.EmpID, msg))
+		}
+	}
+	return issues
+}
+
+// missingStudents flags the enrolled students with no row in the sheet.
+func missingStudents(students []Student, roster Roster) []Issue {
+	present := make(map[string]bool, len(students))
+	for _, s := range students {
+		present[s.EmpID] = true
+	}
+	ids := make([]string, 0, len(roster.Contacts))
+	for id := range roster.Contacts {
+		if !present[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	issues := make([]Issue, 0, len(ids))
+	for _, id := range ids {
+		s := Student{EmpID: id, Name: roster.Contacts[id].Name}
+		name := ""
+		if s.Name != "" {
+			name = " (" + s.Name + ")"
+		}
+		issues = append(issues, studentWarning("missing-student", s, "Enrolled EmpID %s%s is missing from the marks sheet", id, name))
+	}
+	return issues
+}
+
+func checkEmail(email string, domains []string) string {
+	if email == "" {
+		return "missing email"
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Sprintf("malformed email %q", email)
+	}
+	if len(domains) == 0 {
+		return ""
+	}
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	for _, allowed := range domains {
+		allowed = strings.ToLower(strings.TrimPrefix(allowed, "@"))
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("email %s is outside the allowed domains (%s)", email, strings.Join(domains, ", "))
+}
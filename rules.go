@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// RuleDoc describes one check that can raise an Issue. Columns are the layout
+// roles the rule reads, used to point at the exact cells involved.
+type RuleDoc struct {
+	ID      string
+	Checks  string
+	Fix     string
+	Columns []string
+}
+
+var ruleDocs = []RuleDoc{
+	{ID: "header", Checks: "The header rows name the expected columns (EmpID, CampusID, components, Total).",
+		Fix: "Rename the headers to the standard names, or give the columns with -mapping, or check that the marks start on the first sheet."},
+	{ID: "truncated-row", Checks: "Every data row reaches the last column of the layout.",
+		Fix: "Fill in the missing trailing cells (use 0 or leave a blank for absent marks) or delete the stray row."},
+	{ID: "campus-id", Checks: "The CampusID (e.g. 2024A7PS0001H) carries a branch code; rows without one are skipped.",
+		Fix: "Correct the CampusID from the registration records.", Columns: []string{"CampusID"}},
+	{ID: "branch", Checks: "A branch can be extracted from the configured branch source for the row.",
+		Fix: "Correct the source cell, or adjust the \"branch\" section of the config.", Columns: []string{"CampusID"}},
+	{ID: "invalid-mark", Checks: "Every mark cell is empty or a number.",
+		Fix: "Replace text such as \"AB\" with a number and leave the cell empty for absent; rerun with -decimal-comma if the sheet writes marks like \"12,5\"."},
+	{ID: "partial", Checks: "The whole sheet was read and every stage ran to completion.",
+		Fix: "Look at the row named in the message; re-save the workbook in Excel if the file is damaged, then rerun with -resume."},
+	{ID: "skipped-row", Checks: "Rows with no digits in either ID cell are titles, footers, summaries or notes, not students.",
+		Fix: "Nothing, if the row is not a student; otherwise fill in its EmpID and Campus ID."},
+	{ID: "duplicate-empid", Checks: "Each EmpID appears on only one row.",
+		Fix: "Remove the duplicate row, or correct the EmpID that was mistyped.", Columns: []string{"EmpID"}},
+	{ID: "out-of-range", Checks: "Each component mark lies between 0 and the component's maximum.",
+		Fix: "Correct the mark; if -1 or similar was used for absent, leave the cell empty instead."},
+	{ID: "pre-compre-sum", Checks: "Quiz + Mid-Sem + Lab Test + Weekly Labs equals Pre-Compre (E+F+G+H = I).",
+		Fix:     "Recompute Pre-Compre with a SUM formula, or correct the component that was entered wrongly.",
+		Columns: []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Pre-Compre"}},
+	{ID: "total-sum", Checks: "Pre-Compre + Compre equals the Final Total (I+J = K).",
+		Fix:     "Recompute the total with a formula; if grace marks were awarded, record them with a waiver.",
+		Columns: []string{"Pre-Compre", "Compre", "Final Total"}},
+	{ID: "id-consistency", Checks: "The admission year in the EmpID matches the year in the CampusID.",
+		Fix: "Check both IDs against the registration records and correct the wrong one.", Columns: []string{"EmpID", "CampusID"}},
+	{ID: "contact", Checks: "With -roster, every student is on the roster, with a well-formed email in an allowed domain when the roster has emails.",
+		Fix: "Add or correct the student's row in the roster, or update roster.allowed_domains in the config.", Columns: []string{"EmpID"}},
+	{ID: "missing-student", Checks: "With -roster, every enrolled student has a row in the marks sheet.",
+		Fix: "Add the student's marks to the sheet, or remove them from the roster if they withdrew."},
+	{ID: "legacy", Checks: "Stored by an older version before issues had rules.",
+		Fix: "Re-run the sheet to get a classified issue."},
+}
+
+func ruleDoc(id string) (RuleDoc, bool) {
+	for _, doc := range ruleDocs {
+		if doc.ID == id {
+			return doc, true
+		}
+	}
+	return RuleDoc{}, false
+}
+
+func runExplain(args []string) {
+	if len(args) == 0 {
+		for _, doc := range ruleDocs {
+			fmt.Printf("%-16s %s\n", doc.ID, doc.Checks)
+		}
+		return
+	}
+	doc, ok := ruleDoc(args[0])
+	if !ok {
+		fmt.Printf("Unknown rule %q (run \"explain\" with no arguments to list rules)\n", args[0])
+		return
+	}
+	fmt.Printf("Rule: %s\nChecks: %s\nFix: %s\n", doc.ID, doc.Checks, doc.Fix)
+	if len(doc.Columns) > 0 {
+		fmt.Printf("Columns: %s\n", strings.Join(doc.Columns, ", "))
+	}
+}
+
+// cells returns the sheet cells an issue refers to, e.g. I12, J12, K12.
+func (l Layout) cells(issue Issue) []string {
+	if issue.Row <= 0 {
+		return nil
+	}
+	names := []string{issue.Column}
+	if issue.Column == "" {
+		doc, _ := ruleDoc(issue.Rule)
+		names = doc.Columns
+	}
+	var cells []string
+	for _, name := range names {
+		if col := l.roleColumn(name); col >= 0 {
+			cell, err := excelize.CoordinatesToCellName(col+1, issue.Row)
+			if err == nil {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+func (l Layout) roleColumn(name string) int {
+	switch name {
+	case "EmpID":
+		return l.EmpID
+	case "CampusID":
+		return l.CampusID
+	case "Branch":
+		return l.Branch
+	case "Final Total":
+		return l.Total
+	}
+	if col, ok := l.Columns[name]; ok {
+		return col
+	}
+	for _, item := range l.Items {
+		if item.Key() == name {
+			return item.Col
+		}
+	}
+	return -1
+}
+
+func explainIssue(issue Issue, layout Layout) string {
+	doc, ok := ruleDoc(issue.Rule)
+	if !ok {
+		return ""
+	}
+	s := fmt.Sprintf("    rule %s: %s\n", doc.ID, doc.Checks)
+	if cells := layout.cells(issue); len(cells) > 0 {
+		s += fmt.Sprintf("    cells: %s\n", strings.Join(cells, ", "))
+	}
+	return s + fmt.Sprintf("    fix: %s\n", doc.Fix)
+}
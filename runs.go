@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func runRuns(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: go run . [-user name] runs list | show <id> | audit <id> | transition <id> <status> |")
+		fmt.Println("       request-approval <id> | approve <id> | cards <id> | share <id> <resource> |")
+		fmt.Println("       note [-student empid] <id> <text> | notes <id>")
+		return
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		fmt.Println("Error opening storage:", err)
+		return
+	}
+	defer store.Close()
+	store = scopeStorage(store, cfg.user(userName).Tenant)
+
+	fs := flag.NewFlagSet("runs "+args[0], flag.ExitOnError)
+	force := fs.Bool("force", false, "Allow validating a run that still has validation errors")
+	outDir := fs.String("o", "reportcards", "Output directory for report cards")
+	ttl := fs.Duration("ttl", 7*24*time.Hour, "How long a shared link stays valid")
+	student := fs.String("student", "", "EmpID of the student a note is about (default: the whole run)")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "list":
+		runs, err := store.ListRuns()
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		for _, run := range runs {
+			fmt.Printf("%s | %s | %s | %s | %d errors\n", run.ID, runStatus(run), run.Course, run.CreatedAt.Format("2006-01-02 15:04"), countIssues(run.Mismatches, severityError))
+		}
+	case "show":
+		run, err := store.GetRun(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Run: %s\nCourse: %s\nStatus: %s\nSource: %s\nCreated: %s\nStudents: %d\nValidation errors: %d\nWaived: %d\n",
+			run.ID, run.Course, runStatus(run), run.Source, run.CreatedAt.Format("2006-01-02 15:04:05"), len(run.Students), countIssues(run.Mismatches, severityError), countIssues(run.Mismatches, severityWaived))
+		if a := run.Approval; a != nil {
+			fmt.Printf("Approval requested by %s at %s\n", a.RequestedBy, a.RequestedAt.Format("2006-01-02 15:04:05"))
+			if a.ApprovedBy != "" {
+				fmt.Printf("Approved by %s at %s\n", a.ApprovedBy, a.ApprovedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+		if disputes, err := store.ListDisputes(run.ID); err == nil && len(disputes) > 0 {
+			printDisputeSummary(summarizeDisputes(disputes))
+		}
+		if notes, err := store.ListNotes(run.ID); err == nil && len(notes) > 0 {
+			fmt.Printf("Notes: %d\n", len(notes))
+			for _, n := range notes {
+				fmt.Println("  " + n.line())
+			}
+		}
+	case "audit":
+		entries, err := store.ListAudit(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		for _, e := range entries {
+			fmt.Printf("%s | %s | %s | %s | %s\n", e.Time.Format("2006-01-02 15:04:05"), e.RunID, e.Actor, e.Action, e.Detail)
+		}
+	case "transition":
+		if fs.NArg() < 2 {
+			fmt.Println("Usage: go run . runs transition [-force] <id> <draft|validated|published|archived>")
+			return
+		}
+		run, err := transitionRun(store, fs.Arg(0), fs.Arg(1), userName, *force)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Run %s is now %s\n", run.ID, runStatus(run))
+	case "request-approval":
+		if _, err := requestApproval(store, fs.Arg(0), cfg.user(userName)); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Approval requested for run %s by %s\n", fs.Arg(0), userName)
+	case "approve":
+		if _, err := approveRun(store, fs.Arg(0), cfg.user(userName)); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Run %s approved by %s\n", fs.Arg(0), userName)
+	case "cards":
+		run, err := store.GetRun(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		notes, err := store.ListNotes(run.ID)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		n, err := writeReportCards(cfg, run, notes, *outDir)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Wrote %d report cards to %s\n", n, *outDir)
+		if cfg.BaseURL == "" || len(cfg.Keys) == 0 {
+			fmt.Println("The cards carry no verification QR code: set base_url and run admin rotate-keys to add one")
+		}
+	case "share":
+		if fs.NArg() < 2 {
+			fmt.Println("Usage: go run . runs share [-ttl 168h] <id> <summary|cards/empid>")
+			return
+		}
+		if _, err := store.GetRun(fs.Arg(0)); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		link, err := shareLink(cfg.Keys, fs.Arg(0), fs.Arg(1), *ttl)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		store.AppendAudit(AuditEntry{RunID: fs.Arg(0), Time: time.Now(), Actor: userName, Action: "run.shared", Detail: fs.Arg(1) + " for " + ttl.String()})
+		fmt.Println(strings.TrimSuffix(cfg.BaseURL, "/") + link)
+	case "note":
+		if fs.NArg() < 2 {
+			fmt.Println("Usage: go run . runs note [-student empid] <id> <text>")
+			return
+		}
+		n, err := addNote(store, fs.Arg(0), *student, strings.Join(fs.Args()[1:], " "), userName)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Note %s added to run %s\n", n.ID, n.RunID)
+	case "notes":
+		notes, err := store.ListNotes(fs.Arg(0))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		for _, n := range notes {
+			fmt.Println(n.line())
+		}
+	default:
+		fmt.Println("Unknown runs command:", args[0])
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+func runSample(args []string) {
+	fs := flag.NewFlagSet("sample", flag.ExitOnError)
+	n := fs.Int("n", 10, "Number of students to sample")
+	seed := fs.Int64("seed", 1, "Random seed; the same seed and sheet always give the same sample")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || *n < 1 {
+		fmt.Println("Usage: go run . sample [-n 10] [-seed 1] <path-to-excel-file>")
+		return
+	}
+	path := fs.Arg(0)
+
+	students, layout, _, err := parseExcel(path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	var rows [][]string
+	if !isExport(path) {
+		sheet, err := loadRows(context.Background(), path)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		rows = sheet.Rows
+	}
+
+	picked := sampleStudents(students, *n, *seed)
+	fmt.Printf("\nSample of %d of %d students (seed %d)\n", len(picked), len(students), *seed)
+	for _, s := range picked {
+		fmt.Printf("\nRow %d | EmpID: %s | Campus ID: %s | Branch: %s\n", s.Row, maskID(s.EmpID), maskID(s.CampusID), s.Branch)
+		if s.Row > 0 && s.Row <= len(rows) {
+			fmt.Println("  Source:")
+			for i, v := range rows[s.Row-1] {
+				if v == "" {
+					continue
+				}
+				name := fmt.Sprintf("Column %d", i+1)
+				if i < len(layout.Headers) && layout.Headers[i] != "" {
+					name = layout.Headers[i]
+				}
+				fmt.Printf("    %-24s %s\n", name, v)
+			}
+		}
+		fmt.Println("  Computed:")
+		for _, comp := range layout.components() {
+			fmt.Printf("    %-24s %s\n", comp, formatNumber(s.Marks[comp]))
+		}
+		fmt.Printf("    %-24s %s\n", "Computed Total", formatNumber(layout.componentSet().total(s)))
+		fmt.Printf("    %-24s %s\n", "Final Total", formatNumber(s.Marks["Final Total"]))
+		names := make([]string, 0, len(s.Computed))
+		for name := range s.Computed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %-24s %s\n", name, formatNumber(s.Computed[name]))
+		}
+	}
+}
+
+// sampleStudents picks n students at random from seed, returned in sheet
+// order so they are easy to find in the source.
+func sampleStudents(students []Student, n int, seed int64) []Student {
+	sorted := append([]Student(nil), students...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Row < sorted[j].Row })
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	idx := rng.Perm(len(sorted))[:n]
+	sort.Ints(idx)
+	picked := make([]Student, n)
+	for i, j := range idx {
+		picked[i] = sorted[j]
+	}
+	return picked
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var strictSchema bool
+
+// SchemaError lists every structural problem -strict found in a sheet
+// before any student was read from it.
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("sheet does not match the expected layout:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// schemaColumn is a field of the layout and the column it is read from.
+type schemaColumn struct {
+	name string
+	col  int
+}
+
+// checkSchema checks a sheet against the layout it is to be read with: that
+// the header has every expected column, once, under the expected name, and
+// that the ID and mark columns below it hold IDs and numbers. recognised is
+// whether the layout came from the header (or a mapping) rather than the
+// default positions. Rows that would be skipped as titles, footers or notes
+// are left out of the type checks.
+func checkSchema(rows [][]string, layout Layout, recognised, mapped bool) []string {
+	var problems []string
+	letter := func(col int) string {
+		name, _ := excelize.ColumnNumberToName(col + 1)
+		return name
+	}
+
+	if !recognised {
+		if len(layout.Headers) == 0 || len(layout.Missing) == 3+len(components) {
+			problems = append(problems, "header not recognised: none of EmpID, CampusID, Total or the components is named")
+		} else {
+			for _, role := range layout.Missing {
+				problems = append(problems, fmt.Sprintf("header has no %s column", role))
+			}
+		}
+		for _, role := range layout.Moved {
+			problems = append(problems, fmt.Sprintf("%s column is not at its default position", role))
+		}
+	}
+
+	// Two columns under the same name leave it to chance which is read.
+	seen := make(map[string]int)
+	for col, header := range layout.Headers {
+		role := headerRole(header)
+		if role == "" {
+			continue
+		}
+		if first, dup := seen[role]; dup {
+			problems = append(problems, fmt.Sprintf("columns %s and %s are both headed %s", letter(first), letter(col), role))
+			continue
+		}
+		seen[role] = col
+	}
+
+	roles := []schemaColumn{{"EmpID", layout.EmpID}, {"CampusID", layout.CampusID}}
+	for _, comp := range layout.components() {
+		if col, ok := layout.Columns[comp]; ok {
+			roles = append(roles, schemaColumn{comp, col})
+		}
+	}
+	if layout.Total >= 0 {
+		roles = append(roles, schemaColumn{"Final Total", layout.Total})
+	}
+
+	if mapped && layout.HeaderRows > 0 {
+		for _, r := range roles {
+			header := ""
+			if r.col < len(layout.Headers) {
+				header = layout.Headers[r.col]
+			}
+			if header == "" {
+				problems = append(problems, fmt.Sprintf("column %s, mapped to %s, has no header", letter(r.col), r.name))
+			} else if !headerNames(header, r.name) {
+				problems = append(problems, fmt.Sprintf("column %s, mapped to %s, is headed %q", letter(r.col), r.name, header))
+			}
+		}
+	}
+
+	if !recognised && len(layout.Moved) > 0 {
+		// The columns are known to be wrong, so their contents say nothing.
+		return problems
+	}
+	for _, r := range roles {
+		bad, first, value := 0, 0, ""
+		for i := layout.HeaderRows; i < len(rows); i++ {
+			row := rows[i]
+			if isBlankRow(row) || r.col >= len(row) {
+				continue
+			}
+			if kind, _ := structuralRow(row, layout, false); kind != "" {
+				continue
+			}
+			cell := strings.TrimSpace(row[r.col])
+			if cell == "" {
+				continue
+			}
+			ok := true
+			switch r.name {
+			case "EmpID", "CampusID":
+				ok = strings.ContainsAny(cell, "0123456789")
+			default:
+				_, err := parseMark(cell)
+				ok = err == nil
+			}
+			if !ok {
+				if bad == 0 {
+					first, value = i+1, cell
+				}
+				bad++
+			}
+		}
+		if bad == 0 {
+			continue
+		}
+		cells := fmt.Sprintf("%d non-numeric cells", bad)
+		if r.name == "EmpID" || r.name == "CampusID" {
+			cells = fmt.Sprintf("%d cells without an ID", bad)
+		}
+		if bad == 1 {
+			cells = strings.Replace(cells, "cells", "cell", 1)
+		}
+		problems = append(problems, fmt.Sprintf("column %s (%s) has %s, first at row %d (%q)", letter(r.col), r.name, cells, first, value))
+	}
+	return problems
+}
+
+// headerNames tells whether a header names the field name, exactly or as
+// one of its aliases.
+func headerNames(header, name string) bool {
+	if normalizeHeader(header) == normalizeHeader(name) {
+		return true
+	}
+	if name == "Final Total" {
+		name = "Total"
+	}
+	role := headerRole(header)
+	if role == "" {
+		role = fuzzyHeaderRole(header)
+	}
+	return role != "" && role == headerRole(name)
+}
@@ -0,0 +1,714 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type server struct {
+	cfg   Config
+	store Storage
+	oidc  *oidcVerifier
+	jobs  *jobRegistry
+	ranks *rankCache
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Listen address")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		fmt.Println("Error opening storage:", err)
+		return
+	}
+	defer store.Close()
+
+	srv := &server{cfg: cfg, store: store, jobs: newJobRegistry(), ranks: newRankCache()}
+	if cfg.Auth.OIDC.Issuer != "" {
+		srv.oidc = newOIDCVerifier(cfg.Auth.OIDC)
+	}
+	fmt.Printf("Listening on %s (storage: %s)\n", *addr, storageName(cfg.Storage))
+	if err := http.ListenAndServe(*addr, srv.routes()); err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.createRun)
+	mux.HandleFunc("POST /validate", s.validateUpload)
+	mux.HandleFunc("GET /runs", s.listRuns)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("GET /runs/{id}/students", s.listStudents)
+	mux.HandleFunc("GET /runs/{id}/audit", s.listAudit)
+	mux.HandleFunc("POST /runs/{id}/transition", s.transition)
+	mux.HandleFunc("POST /runs/{id}/approval-request", s.requestApproval)
+	mux.HandleFunc("POST /runs/{id}/approve", s.approve)
+	mux.HandleFunc("GET /runs/{id}/cards/{empid}", s.reportCard)
+	mux.HandleFunc("GET /runs/{id}/cards.zip", s.reportCardsZip)
+	mux.HandleFunc("GET /runs/{id}/students/{empid}/grade", s.gradeTrace)
+	mux.HandleFunc("GET /runs/{id}/summary", s.summary)
+	mux.HandleFunc("GET /runs/{id}/components", s.listComponents)
+	mux.HandleFunc("GET /runs/{id}/components/{component}/ranking", s.componentRanking)
+	mux.HandleFunc("GET /runs/{id}/components/{component}/ranking/{empid}", s.componentRank)
+	mux.HandleFunc("POST /runs/{id}/disputes", s.openDispute)
+	mux.HandleFunc("GET /runs/{id}/disputes", s.listDisputes)
+	mux.HandleFunc("GET /disputes/{id}", s.getDispute)
+	mux.HandleFunc("POST /disputes/{id}/status", s.updateDispute)
+	mux.HandleFunc("POST /disputes/{id}/corrections", s.linkCorrection)
+	mux.HandleFunc("POST /runs/{id}/notes", s.addNote)
+	mux.HandleFunc("GET /runs/{id}/notes", s.listNotes)
+	mux.HandleFunc("POST /runs/{id}/share", s.share)
+	mux.HandleFunc("GET /shared/runs/{id}/{resource...}", s.shared)
+	mux.HandleFunc("GET /verify/runs/{id}/{empid}", s.verifyCard)
+	mux.HandleFunc("POST /jobs", s.createJob)
+	mux.HandleFunc("GET /jobs", s.listJobs)
+	mux.HandleFunc("GET /jobs/{id}", s.getJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.cancelJob)
+	mux.HandleFunc("GET /usage", s.usage)
+	mux.HandleFunc("GET /courses", s.listCourses)
+	mux.HandleFunc("GET /courses/{course}/stats", s.courseStats)
+	return mux
+}
+
+func (s *server) createRun(w http.ResponseWriter, r *http.Request) {
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	path, name, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	defer os.Remove(path)
+
+	run, warning, status, err := s.importRun(r.Context(), user, store, path, name, r.FormValue("course"))
+	if errors.Is(err, errRejected) {
+		writeJSON(w, status, map[string]interface{}{"error": err.Error(), "run": summarizeRun(run)})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, errBusy) {
+			w.Header().Set("Retry-After", "5")
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	if warning != "" {
+		w.Header().Set("X-Quota-Warning", warning)
+	}
+	writeJSON(w, http.StatusCreated, summarizeRun(run))
+}
+
+// ValidationResult is what POST /validate reports on an uploaded sheet,
+// which is parsed and validated but not stored. Problems are the structural
+// problems found by -strict, when it refused to read the sheet at all.
+type ValidationResult struct {
+	Source   string   `json:"source"`
+	Valid    bool     `json:"valid"`
+	Students int      `json:"students"`
+	Errors   int      `json:"errors"`
+	Warnings int      `json:"warnings"`
+	Issues   []Issue  `json:"issues"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// validateUpload checks an uploaded sheet as POST /runs would, without
+// creating a run, so that a grader can fix it before the real upload. A
+// sheet is valid when it has no errors, or with -fail-on warning, no
+// warnings either.
+func (s *server) validateUpload(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	path, name, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	defer os.Remove(path)
+
+	result := ValidationResult{Source: name, Issues: []Issue{}}
+	run, err := buildRun(r.Context(), path, "")
+	var schema *SchemaError
+	switch {
+	case errors.As(err, &schema):
+		result.Problems = schema.Problems
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	case errors.Is(err, errBusy):
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, errLimit):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	policy := failOn
+	if policy == "never" {
+		policy = "error"
+	}
+	result.Valid = !shouldFail(policy, run.Mismatches)
+	result.Students = len(run.Students)
+	result.Errors = countIssues(run.Mismatches, severityError)
+	result.Warnings = countIssues(run.Mismatches, severityWarning)
+	if run.Mismatches != nil {
+		result.Issues = run.Mismatches
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// receiveUpload copies the multipart "file" field to a temporary file, which
+// the caller removes.
+func receiveUpload(w http.ResponseWriter, r *http.Request) (path, name string, ok bool) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "missing multipart field \"file\"")
+		return "", "", false
+	}
+	defer file.Close()
+
+	tmp, err := os.CreateTemp("", "upload-*"+filepath.Ext(header.Filename))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return "", "", false
+	}
+	_, err = io.Copy(tmp, file)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return "", "", false
+	}
+	return tmp.Name(), header.Filename, true
+}
+
+// importRun parses an uploaded sheet and saves it as a new run, returning
+// any soft quota warning. On error it also returns the HTTP status
+// describing it. A run refused by -fail-on is returned unsaved, with an
+// errRejected error.
+func (s *server) importRun(ctx context.Context, user User, store Storage, path, source, course string) (Run, string, int, error) {
+	run, err := buildRun(ctx, path, course)
+	switch {
+	case errors.Is(err, errBusy):
+		return Run{}, "", http.StatusServiceUnavailable, err
+	case errors.Is(err, errLimit):
+		return Run{}, "", http.StatusRequestEntityTooLarge, err
+	case err != nil:
+		return Run{}, "", http.StatusUnprocessableEntity, err
+	}
+	run.Source = source
+	run.Manifest.Inputs[0].Name = source
+	for i := range run.Students {
+		run.Students[i].Source = source
+	}
+	run.Tenant = user.Tenant
+	*run.Manifest = run.Manifest.forTenant(user.Tenant)
+	run.ID = run.Manifest.RunID
+	if shouldFail(failOn, run.Mismatches) {
+		return run, "", http.StatusUnprocessableEntity, fmt.Errorf("%w: run has %d errors and %d warnings (fail-on %s)",
+			errRejected, countIssues(run.Mismatches, severityError), countIssues(run.Mismatches, severityWarning), failOn)
+	}
+
+	warning, err := checkQuota(store, s.cfg.tenant(user.Tenant), run)
+	if err != nil {
+		return Run{}, "", http.StatusTooManyRequests, err
+	}
+	if err := jobStep(ctx, "save", len(run.Students)); err != nil {
+		return Run{}, "", http.StatusServiceUnavailable, err
+	}
+	// The ID is the manifest's, so the same sheet imported again is the
+	// same run, left as it is rather than replaced by a new draft.
+	if _, err := store.GetRun(run.ID); err == nil {
+		return Run{}, "", http.StatusConflict, fmt.Errorf("the sheet was already imported as run %s", run.ID)
+	}
+	if err := store.SaveRun(run); err != nil {
+		// Another tenant's run with the ID is not found in this one's
+		// scope; either way the ID is taken.
+		if errors.Is(err, errNotFound) || errors.Is(err, errRunImmutable) {
+			return Run{}, "", http.StatusConflict, fmt.Errorf("run ID %s is already in use", run.ID)
+		}
+		return Run{}, "", http.StatusInternalServerError, err
+	}
+	store.AppendAudit(AuditEntry{RunID: run.ID, Time: time.Now(), Actor: user.Name, Action: "run.created", Detail: run.Source})
+	recordStats(s.cfg, store, run)
+	s.ranks.put(run)
+	announceRun(s.cfg, store, run)
+	return run, warning, 0, nil
+}
+
+func (s *server) listRuns(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	runs, err := store.ListRuns()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *server) getRun(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (s *server) listStudents(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	students := run.Students
+	if spec := r.URL.Query().Get("sort"); spec != "" {
+		keys, err := parseSortSpec(spec)
+		if err == nil {
+			err = sortStudents(students, run.componentSet(), keys)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, students)
+}
+
+func (s *server) listAudit(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	entries, err := store.ListAudit(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *server) transition(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+		Force  bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+
+	run, err := transitionRun(store, r.PathValue("id"), req.Status, user.Name, req.Force)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, summarizeRun(run))
+}
+
+func (s *server) requestApproval(w http.ResponseWriter, r *http.Request) {
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := requestApproval(store, r.PathValue("id"), user)
+	s.writeApprovalResult(w, run, err)
+}
+
+func (s *server) approve(w http.ResponseWriter, r *http.Request) {
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := approveRun(store, r.PathValue("id"), user)
+	s.writeApprovalResult(w, run, err)
+}
+
+func (s *server) writeApprovalResult(w http.ResponseWriter, run Run, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, summarizeRun(run))
+}
+
+func (s *server) authenticate(w http.ResponseWriter, r *http.Request) (User, bool) {
+	if len(s.cfg.Users) == 0 && !s.cfg.Auth.enabled() {
+		return User{Name: "api"}, true
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.oidc != nil {
+		name, groups, err := s.oidc.verify(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return User{}, false
+		}
+		return s.cfg.Auth.directoryUser(name, groups), true
+	}
+
+	user, ok := s.cfg.userByKey(r.Header.Get("X-API-Key"))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing or invalid X-API-Key or bearer token")
+		return User{}, false
+	}
+	return user, true
+}
+
+// scope authenticates the request and returns storage restricted to the
+// caller's tenant.
+func (s *server) scope(w http.ResponseWriter, r *http.Request) (User, Storage, bool) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return User{}, nil, false
+	}
+	return user, scopeStorage(s.store, user.Tenant), true
+}
+
+func (s *server) reportCard(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	notes, err := store.ListNotes(run.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeReportCard(w, s.cfg, run, notes, r.PathValue("empid"))
+}
+
+func writeReportCard(w http.ResponseWriter, cfg Config, run Run, notes []Note, empID string) {
+	if err := requirePublished(run); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	for _, student := range run.Students {
+		if student.EmpID == empID {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, renderReportCard(cfg, run, student, notes))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "student not found in run")
+}
+
+// reportCardsZip streams every report card of a run, or of one ?branch=, as
+// a ZIP archive, rendering each card as it is written.
+func (s *server) reportCardsZip(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if err := requirePublished(run); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	notes, err := store.ListNotes(run.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	branch := r.URL.Query().Get("branch")
+	var students []Student
+	for _, student := range run.Students {
+		if branch == "" || strings.EqualFold(student.Branch, branch) {
+			students = append(students, student)
+		}
+	}
+	if len(students) == 0 {
+		writeError(w, http.StatusNotFound, "no students in branch "+branch)
+		return
+	}
+
+	name := "reportcards-" + run.ID
+	if branch != "" {
+		name += "-" + branch
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	zw := zip.NewWriter(w)
+	for _, student := range students {
+		if r.Context().Err() != nil {
+			return
+		}
+		f, err := zw.Create(student.EmpID + ".txt")
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(f, renderReportCard(s.cfg, run, student, notes)); err != nil {
+			return
+		}
+	}
+	zw.Close()
+}
+
+func (s *server) summary(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	run, err := store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	stats := runStatistics(run)
+	disputes, err := store.ListDisputes(run.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	sum := summarizeDisputes(disputes)
+	stats.Disputes = &sum
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *server) share(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Resource string `json:"resource"`
+		TTL      string `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	ttl := 7 * 24 * time.Hour
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ttl")
+			return
+		}
+		ttl = d
+	}
+
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if _, err := store.GetRun(id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	link, err := shareLink(s.cfg.Keys, id, req.Resource, ttl)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	store.AppendAudit(AuditEntry{RunID: id, Time: time.Now(), Actor: user.Name, Action: "run.shared", Detail: req.Resource + " for " + ttl.String()})
+
+	base := s.cfg.BaseURL
+	if base == "" {
+		base = "http://" + r.Host
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"url": strings.TrimSuffix(base, "/") + link})
+}
+
+// shared serves a resource through a signed link, without an account.
+func (s *server) shared(w http.ResponseWriter, r *http.Request) {
+	if err := verifyLink(s.cfg.Keys, r.URL.EscapedPath(), r.URL.Query()); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	resource := r.PathValue("resource")
+	if err := shareResource(resource); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	run, err := s.store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if resource == "summary" {
+		writeJSON(w, http.StatusOK, sharedStatistics(runStatistics(run)))
+		return
+	}
+	notes, err := s.store.ListNotes(run.ID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeReportCard(w, s.cfg, run, notes, strings.TrimPrefix(resource, "cards/"))
+}
+
+func buildRun(ctx context.Context, path, course string) (Run, error) {
+	students, layout, issues, err := parseExcelContext(ctx, path)
+	if err != nil {
+		return Run{}, err
+	}
+	set := layout.componentSet()
+	for i := range students {
+		students[i].Total = set.total(students[i])
+	}
+	if err := jobStep(ctx, "validate", len(students)); err != nil {
+		return Run{}, err
+	}
+
+	manifest := buildManifest([]string{path})
+	if course != "" {
+		manifest.Flags["course"] = course
+		manifest.RunID = manifest.id()
+	}
+	return Run{
+		ID:         manifest.RunID,
+		Course:     course,
+		Source:     path,
+		Status:     statusDraft,
+		CreatedAt:  time.Now().UTC(),
+		Students:   students,
+		Mismatches: append(issues, applyWaivers(collectMismatches(students, set), waivers)...),
+		Components: set.exported(),
+		Manifest:   &manifest,
+	}, nil
+}
+
+func saveToStorage(manifest Manifest, source string, set ComponentSet, students []Student, mismatches []Issue) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		fmt.Println("Error opening storage:", err)
+		return
+	}
+	defer store.Close()
+	user := cfg.user(userName)
+	store = scopeStorage(store, user.Tenant)
+
+	manifest = manifest.forTenant(user.Tenant)
+	if _, err := store.GetRun(manifest.RunID); err == nil {
+		fmt.Printf("Run %s is already saved (storage: %s)\n", manifest.RunID, storageName(cfg.Storage))
+		return
+	}
+	run := Run{
+		ID:         manifest.RunID,
+		Tenant:     user.Tenant,
+		Course:     courseName,
+		Source:     source,
+		Status:     statusDraft,
+		CreatedAt:  time.Now().UTC(),
+		Students:   students,
+		Mismatches: mismatches,
+		Components: set.exported(),
+		Manifest:   &manifest,
+	}
+	warning, err := checkQuota(store, cfg.tenant(user.Tenant), run)
+	if err != nil {
+		fmt.Println("Error saving run:", err)
+		return
+	}
+	if err := store.SaveRun(run); err != nil {
+		fmt.Println("Error saving run:", err)
+		return
+	}
+	store.AppendAudit(AuditEntry{RunID: run.ID, Time: time.Now(), Actor: userName, Action: "run.created", Detail: source})
+	recordStats(cfg, store, run)
+
+	fmt.Printf("Run saved as %s (storage: %s)\n", run.ID, storageName(cfg.Storage))
+	if warning != "" {
+		fmt.Println("Warning:", warning)
+	}
+
+	if digest := announceRun(cfg, store, run); digest != nil {
+		fmt.Println()
+		fmt.Print(digest.Text())
+	}
+}
+
+func storageName(cfg StorageConfig) string {
+	if cfg.Driver == "" {
+		return "memory"
+	}
+	return cfg.Driver
+}
+
+// writeJSON buffers the response so that one over -max-export-mb can still
+// be answered with an error instead of a truncated body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(limitExport(&buf)).Encode(v); err != nil {
+		status = http.StatusInsufficientStorage
+		buf.Reset()
+		json.NewEncoder(&buf).Encode(map[string]string{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, errRunImmutable) || errors.Is(err, errConflict) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
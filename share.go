@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errBadLink = errors.New("invalid or expired link")
+
+// shareResource checks that resource names something a link may expose:
+// the run summary or one student's report card.
+func shareResource(resource string) error {
+	if resource == "summary" {
+		return nil
+	}
+	if empID, ok := strings.CutPrefix(resource, "cards/"); ok && empID != "" && !strings.Contains(empID, "/") {
+		return nil
+	}
+	return fmt.Errorf("cannot share %q (want summary or cards/<empid>)", resource)
+}
+
+func signLink(key SigningKey, path string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(key.Secret))
+	fmt.Fprintf(mac, "%s\n%d", path, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// shareLink returns the signed path and query for resource of run, valid
+// for ttl. The newest signing key is used.
+func shareLink(keys []SigningKey, runID, resource string, ttl time.Duration) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no signing keys configured (run admin rotate-keys)")
+	}
+	if err := shareResource(resource); err != nil {
+		return "", err
+	}
+	path := "/shared/runs/" + url.PathEscape(runID) + "/" + resource
+	exp := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("kid", keys[0].ID)
+	q.Set("sig", signLink(keys[0], path, exp))
+	return path + "?" + q.Encode(), nil
+}
+
+// verifyLink accepts links signed with any configured key, so links issued
+// before a key rotation keep working until that key is retired.
+func verifyLink(keys []SigningKey, path string, q url.Values) error {
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return errBadLink
+	}
+	return checkSignature(keys, path, exp, q)
+}
+
+func checkSignature(keys []SigningKey, path string, exp int64, q url.Values) error {
+	for _, key := range keys {
+		if key.ID != q.Get("kid") {
+			continue
+		}
+		if hmac.Equal([]byte(signLink(key, path, exp)), []byte(q.Get("sig"))) {
+			return nil
+		}
+	}
+	return errBadLink
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortKey is one term of a -sort spec such as "total desc, compre desc".
+// Keys are expressions over the same names as computed columns, plus
+// percentile and branchpercentile (of Total, 0-100) and row.
+type SortKey struct {
+	Src  string
+	Desc bool
+	expr expr
+}
+
+var defaultSort = "total desc"
+
+func parseSortSpec(spec string) ([]SortKey, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = defaultSort
+	}
+	var keys []SortKey
+	for _, term := range splitSortSpec(spec) {
+		term = strings.TrimSpace(term)
+		key := SortKey{Src: term}
+		if fields := strings.Fields(term); len(fields) > 1 {
+			switch strings.ToLower(fields[len(fields)-1]) {
+			case "desc":
+				key.Desc = true
+				term = strings.Join(fields[:len(fields)-1], " ")
+			case "asc":
+				term = strings.Join(fields[:len(fields)-1], " ")
+			}
+		}
+		if term == "" {
+			return nil, fmt.Errorf("invalid -sort %q: empty sort key", spec)
+		}
+		e, err := parseExpr(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -sort key %q: %w", key.Src, err)
+		}
+		key.expr = e
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// splitSortSpec splits spec into its terms at the commas outside
+// parentheses, so that a key may call max(quiz, compre).
+func splitSortSpec(spec string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, spec[start:])
+}
+
+// sortStudents orders students in place by keys, keeping the current order
+// between students the keys cannot tell apart.
+func sortStudents(students []Student, set ComponentSet, keys []SortKey) error {
+	percentile := percentiles(students, set, func(Student) string { return "" })
+	branchPercentile := percentiles(students, set, func(s Student) string { return s.Branch })
+
+	values := make([][]float64, len(students))
+	for i, s := range students {
+		vars := studentVars(s, set)
+		vars["percentile"] = percentile[i]
+		vars["branchpercentile"] = branchPercentile[i]
+		vars["row"] = float64(s.Row)
+		values[i] = make([]float64, len(keys))
+		for k, key := range keys {
+			v, err := key.expr.eval(vars)
+			if err != nil {
+				return fmt.Errorf("sort key %q: %w", key.Src, err)
+			}
+			values[i][k] = v
+		}
+	}
+
+	order := make([]int, len(students))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		va, vb := values[order[a]], values[order[b]]
+		for k, key := range keys {
+			if va[k] != vb[k] {
+				return (va[k] > vb[k]) == key.Desc
+			}
+		}
+		return false
+	})
+
+	sorted := make([]Student, len(students))
+	for i, j := range order {
+		sorted[i] = students[j]
+	}
+	copy(students, sorted)
+	return nil
+}
+
+// percentiles gives each student's percentile rank of Total within its
+// group.
+func percentiles(students []Student, set ComponentSet, group func(Student) string) []float64 {
+	totals := make(map[string][]float64)
+	for _, s := range students {
+		g := group(s)
+		totals[g] = append(totals[g], set.total(s))
+	}
+	for _, t := range totals {
+		sort.Float64s(t)
+	}
+	out := make([]float64, len(students))
+	for i, s := range students {
+		out[i] = percentileRank(totals[group(s)], set.total(s))
+	}
+	return out
+}
+
+// percentileRank is the percentage of sorted (ascending) below v, counting
+// values equal to v as half.
+func percentileRank(sorted []float64, v float64) float64 {
+	below := sort.SearchFloat64s(sorted, v)
+	equal := sort.Search(len(sorted), func(j int) bool { return sorted[j] > v }) - below
+	return (float64(below) + float64(equal)/2) / float64(len(sorted)) * 100
+}
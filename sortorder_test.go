@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSortSpecFunctionCalls(t *testing.T) {
+	keys, err := parseSortSpec("max(quiz, compre) desc, round(total / 3, 1), row")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srcs []string
+	for _, key := range keys {
+		srcs = append(srcs, key.Src)
+	}
+	if want := []string{"max(quiz, compre) desc", "round(total / 3, 1)", "row"}; !slices.Equal(srcs, want) {
+		t.Fatalf("keys %q, want %q", srcs, want)
+	}
+	if !keys[0].Desc || keys[1].Desc {
+		t.Errorf("directions %v %v, want desc then asc", keys[0].Desc, keys[1].Desc)
+	}
+
+	students := []Student{
+		{EmpID: "a", Row: 1, Marks: map[string]float64{"Quiz": 10, "Compre": 50}},
+		{EmpID: "b", Row: 2, Marks: map[string]float64{"Quiz": 25, "Compre": 20}},
+		{EmpID: "c", Row: 3, Marks: map[string]float64{"Quiz": 5, "Compre": 70}},
+	}
+	if err := sortStudents(students, ComponentSet{}, keys[:1]); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, s := range students {
+		order = append(order, s.EmpID)
+	}
+	if want := []string{"c", "a", "b"}; !slices.Equal(order, want) {
+		t.Errorf("sorted by max(quiz, compre) desc: %v, want %v", order, want)
+	}
+
+	if _, err := parseSortSpec("max(quiz, compre"); err == nil {
+		t.Error("unbalanced parenthesis accepted")
+	}
+}
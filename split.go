@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var splitOut string
+
+// writeBranchSplit gives each branch coordinator their own slice of the
+// results: the branch's students, ranked within the branch, followed by
+// the branch's statistics. out is a directory that gets one workbook per
+// branch, or, when it ends in .xlsx, a single workbook with one sheet per
+// branch. Students keep the order of the rankings (-sort).
+func writeBranchSplit(students []Student, comps ComponentSet, out string) ([]string, error) {
+	byBranch := make(map[string][]Student)
+	for _, s := range students {
+		byBranch[s.Branch] = append(byBranch[s.Branch], s)
+	}
+	branches := make([]string, 0, len(byBranch))
+	for branch := range byBranch {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("no students to split")
+	}
+
+	if strings.EqualFold(filepath.Ext(out), ".xlsx") {
+		f := excelize.NewFile()
+		defer f.Close()
+		for i, branch := range branches {
+			if i == 0 {
+				f.SetSheetName(f.GetSheetName(0), branch)
+			} else if _, err := f.NewSheet(branch); err != nil {
+				return nil, err
+			}
+			if err := fillBranchSheet(f, branch, comps, byBranch[branch]); err != nil {
+				return nil, err
+			}
+		}
+		if err := f.SaveAs(out); err != nil {
+			return nil, err
+		}
+		return []string{out}, nil
+	}
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, branch := range branches {
+		f := excelize.NewFile()
+		f.SetSheetName(f.GetSheetName(0), branch)
+		path := filepath.Join(out, branch+".xlsx")
+		err := fillBranchSheet(f, branch, comps, byBranch[branch])
+		if err == nil {
+			err = f.SaveAs(path)
+		}
+		f.Close()
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// fillBranchSheet writes one branch's ranking and statistics to sheet.
+// Equal totals share a rank (1, 2, 2, 4).
+func fillBranchSheet(f *excelize.File, sheet string, comps ComponentSet, students []Student) error {
+	table := marksTable(students, comps)
+	named := false
+	for _, s := range students {
+		named = named || s.Name != ""
+	}
+
+	header := []interface{}{"Rank", "EmpID", "Campus ID", "Class No."}
+	if named {
+		header = append(header, "Name")
+	}
+	for _, name := range table.Names {
+		header = append(header, name)
+	}
+	header = append(header, "Computed Total")
+	row := 1
+	set := func(values []interface{}) error {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		row++
+		return f.SetSheetRow(sheet, cell, &values)
+	}
+	if err := set(header); err != nil {
+		return err
+	}
+
+	totals := make([]float64, len(students))
+	for i, s := range students {
+		totals[i] = comps.total(s)
+	}
+	for i, s := range students {
+		rank := 1
+		for _, t := range totals {
+			if t > totals[i] {
+				rank++
+			}
+		}
+		values := []interface{}{rank, s.EmpID, s.CampusID, s.ClassNo}
+		if named {
+			values = append(values, s.Name)
+		}
+		for _, name := range table.Names {
+			values = append(values, s.Marks[name])
+		}
+		values = append(values, totals[i])
+		if err := set(values); err != nil {
+			return err
+		}
+	}
+
+	row++
+	if err := set([]interface{}{"Statistics", "Students", len(students)}); err != nil {
+		return err
+	}
+	if err := set([]interface{}{"Component", "Mean", "Median", "Min", "Max", "Std Dev"}); err != nil {
+		return err
+	}
+	columns := append(append([]string(nil), table.Names...), "Computed Total")
+	for _, name := range columns {
+		col := totals
+		if name != "Computed Total" {
+			col = table.Column(name)
+		}
+		m := markStats(name, col)
+		values := []interface{}{name, m.Mean, m.Median, m.Min, m.Max, m.StdDev}
+		if err := set(values); err != nil {
+			return err
+		}
+	}
+	return f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthConfig enables campus single sign-on in server mode alongside API
+// keys. Directory groups are mapped to roles and tenants; the first group of
+// the user that has a mapping wins. LDAP directories are reached through the
+// campus OIDC provider, which exposes the same groups as a token claim.
+type AuthConfig struct {
+	OIDC         OIDCConfig        `json:"oidc,omitzero"`
+	GroupRoles   map[string]string `json:"group_roles,omitempty"`
+	GroupTenants map[string]string `json:"group_tenants,omitempty"`
+}
+
+type OIDCConfig struct {
+	Issuer      string `json:"issuer"`
+	ClientID    string `json:"client_id"`
+	GroupsClaim string `json:"groups_claim"`
+}
+
+func (a AuthConfig) enabled() bool {
+	return a.OIDC.Issuer != ""
+}
+
+func (a AuthConfig) directoryUser(name string, groups []string) User {
+	user := User{Name: name}
+	for _, g := range groups {
+		if role, ok := a.GroupRoles[g]; ok && user.Role == "" {
+			user.Role = role
+		}
+		if tenant, ok := a.GroupTenants[g]; ok && user.Tenant == "" {
+			user.Tenant = tenant
+		}
+	}
+	return user
+}
+
+var errBadToken = errors.New("invalid bearer token")
+
+// oidcVerifier checks RS256 ID tokens against the issuer's published keys,
+// fetched through OpenID discovery and cached until an unknown key ID shows
+// up.
+type oidcVerifier struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+func newOIDCVerifier(cfg OIDCConfig) *oidcVerifier {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &oidcVerifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (v *oidcVerifier) verify(token string) (name string, groups []string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, errBadToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", nil, errBadToken
+	}
+	if header.Alg != "RS256" {
+		return "", nil, fmt.Errorf("%w: unsupported alg %q", errBadToken, header.Alg)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, errBadToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return "", nil, errBadToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", nil, errBadToken
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(v.cfg.Issuer, "/") {
+		return "", nil, fmt.Errorf("%w: wrong issuer", errBadToken)
+	}
+	if !audienceContains(claims["aud"], v.cfg.ClientID) {
+		return "", nil, fmt.Errorf("%w: wrong audience", errBadToken)
+	}
+	if exp, _ := claims["exp"].(float64); time.Now().Unix() >= int64(exp) {
+		return "", nil, fmt.Errorf("%w: expired", errBadToken)
+	}
+
+	for _, claim := range []string{"preferred_username", "email", "sub"} {
+		if s, _ := claims[claim].(string); s != "" {
+			name = s
+			break
+		}
+	}
+	if list, ok := claims[v.cfg.GroupsClaim].([]interface{}); ok {
+		for _, g := range list {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return name, groups, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []interface{}:
+		for _, x := range a {
+			if x == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *oidcVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// Refetch on an unknown key ID, which is how providers roll keys, but
+	// not more than once a minute so bad tokens cannot hammer the issuer.
+	if time.Since(v.fetched) < time.Minute {
+		return nil, fmt.Errorf("%w: unknown key %q", errBadToken, kid)
+	}
+	keys, err := v.fetchKeys()
+	v.fetched = time.Now()
+	if err != nil {
+		return nil, fmt.Errorf("fetching OIDC keys: %w", err)
+	}
+	v.keys = keys
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", errBadToken, kid)
+}
+
+func (v *oidcVerifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (v *oidcVerifier) getJSON(url string, out interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// RunStats is the headline summary of a run: the dashboard numbers that get
+// shared outside the grading team.
+type RunStats struct {
+	RunID      string             `json:"run_id"`
+	Course     string             `json:"course"`
+	Students   int                `json:"students"`
+	Components map[string]float64 `json:"component_averages"`
+	Branches   []BranchStats      `json:"branches"`
+	Disputes   *DisputeSummary    `json:"disputes,omitempty"`
+	// Notes say which branches a shared summary pooled or withheld (see
+	// sharedStatistics).
+	Notes []string `json:"notes,omitempty"`
+}
+
+type BranchStats struct {
+	Branch       string  `json:"branch"`
+	Students     int     `json:"students"`
+	AverageTotal float64 `json:"average_total"`
+	// Means are the branch's average component marks, in reports.
+	Means map[string]float64 `json:"means,omitempty"`
+}
+
+func runStatistics(run Run) RunStats {
+	stats := RunStats{RunID: run.ID, Course: run.Course, Students: len(run.Students), Components: make(map[string]float64)}
+	set := run.componentSet()
+	table := marksTable(run.Students, set)
+	for _, comp := range table.Names {
+		stats.Components[comp] = mean(table.Column(comp))
+	}
+
+	totals := table.SumColumns(set.totalParts()...)
+	byBranch := make(map[string]*BranchStats)
+	for i, branch := range table.Branch {
+		b, ok := byBranch[branch]
+		if !ok {
+			b = &BranchStats{Branch: branch}
+			byBranch[branch] = b
+		}
+		b.Students++
+		b.AverageTotal += totals[i]
+	}
+	for _, b := range byBranch {
+		b.AverageTotal /= float64(b.Students)
+		stats.Branches = append(stats.Branches, *b)
+	}
+	sort.Slice(stats.Branches, func(i, j int) bool { return stats.Branches[i].Branch < stats.Branches[j].Branch })
+	return stats
+}
+
+// StatsSnapshot is the part of a run's statistics kept for longitudinal
+// dashboards, one per run.
+type StatsSnapshot struct {
+	RunID    string         `json:"run_id"`
+	Tenant   string         `json:"tenant,omitempty"`
+	Course   string         `json:"course"`
+	Time     time.Time      `json:"time"`
+	Students int            `json:"students"`
+	Mean     float64        `json:"mean"`
+	StdDev   float64        `json:"stddev"`
+	PassRate float64        `json:"pass_rate"`
+	Grades   map[string]int `json:"grades"`
+}
+
+func statsSnapshot(cfg Config, run Run) StatsSnapshot {
+	snap := StatsSnapshot{RunID: run.ID, Tenant: run.Tenant, Course: run.Course, Time: run.CreatedAt,
+		Students: len(run.Students), Grades: make(map[string]int)}
+	course := cfg.course(run.Course)
+	totals := make([]float64, len(run.Students))
+	passed := 0
+	set := run.componentSet()
+	for i, s := range run.Students {
+		totals[i] = set.total(s)
+		pct := totals[i] / course.MaxTotal * 100
+		snap.Grades[cfg.grade(pct).Grade]++
+		if pct >= course.PassPct {
+			passed++
+		}
+	}
+	snap.Mean = mean(totals)
+	snap.StdDev = stdDev(totals)
+	if len(totals) > 0 {
+		snap.PassRate = float64(passed) / float64(len(totals))
+	}
+	return snap
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	var ss float64
+	for _, v := range values {
+		ss += (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(len(values)-1))
+}
+
+// recordStats snapshots a newly saved run. Failing to do so is logged
+// rather than failing the import.
+func recordStats(cfg Config, store Storage, run Run) {
+	if err := store.SaveStats(statsSnapshot(cfg, run)); err != nil {
+		fmt.Printf("Error recording statistics for run %s: %v\n", run.ID, err)
+	}
+}
+
+type CourseHistory struct {
+	Course string    `json:"course"`
+	Runs   int       `json:"runs"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+func (s *server) listCourses(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	snaps, err := store.ListStats("")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	byCourse := make(map[string]*CourseHistory)
+	var courses []*CourseHistory
+	for _, snap := range snaps {
+		h, ok := byCourse[snap.Course]
+		if !ok {
+			h = &CourseHistory{Course: snap.Course, First: snap.Time}
+			byCourse[snap.Course] = h
+			courses = append(courses, h)
+		}
+		h.Runs++
+		h.Last = snap.Time
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Course < courses[j].Course })
+	writeJSON(w, http.StatusOK, courses)
+}
+
+func (s *server) courseStats(w http.ResponseWriter, r *http.Request) {
+	_, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	snaps, err := store.ListStats(r.PathValue("course"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(snaps) == 0 {
+		writeError(w, http.StatusNotFound, "no statistics for course "+r.PathValue("course"))
+		return
+	}
+	writeJSON(w, http.StatusOK, snaps)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// inputCopy is the temporary copy of the input when the file argument is "-"
+// or a URL, and inputName what reports call it. The copy is removed on exit
+// and never checkpointed.
+var inputCopy, inputName string
+
+// readStdin copies standard input to a temporary file, so that it can be read
+// like any other input.
+func readStdin() (string, error) {
+	return copyInput(os.Stdin, "standard input", "")
+}
+
+// copyInput copies r to a temporary file named with ext, when that is the
+// extension of a readable format. Otherwise, without -format, the extension
+// is chosen from the content: a JSON object is an exported report, a zip is
+// a workbook (or an ODS file, which names itself at the start of the
+// archive), an OLE compound document is a legacy .xls workbook unless it
+// holds an encrypted .xlsx package, text whose first line has more tabs
+// than commas is TSV and anything else is taken to be CSV.
+func copyInput(r io.Reader, name, ext string) (string, error) {
+	in := bufio.NewReaderSize(r, 512)
+	head, _ := in.Peek(512)
+	sniffed := false
+	switch strings.ToLower(ext) {
+	case ".xlsx", ".xlsm", ".xls", ".ods", ".csv", ".tsv", ".txt", ".json":
+	default:
+		ext, sniffed = sniffFormat(head), true
+	}
+	if fromJSON {
+		ext, sniffed = ".json", false
+	} else if inputFormat != "auto" {
+		ext, sniffed = "."+inputFormat, false
+	}
+
+	f, err := os.CreateTemp("", "input-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, in)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	copied := f.Name()
+	// The directory of a compound document is past the sniffed head, so an
+	// encrypted .xlsx is told from an .xls once copied.
+	if sniffed && ext == ".xls" && encryptedPackage(copied) {
+		renamed := strings.TrimSuffix(copied, ext) + ".xlsx"
+		if err := os.Rename(copied, renamed); err != nil {
+			os.Remove(copied)
+			return "", err
+		}
+		copied = renamed
+	}
+	inputCopy, inputName = copied, name
+	return inputCopy, nil
+}
+
+func sniffFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")):
+		return ".json"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		if bytes.Contains(head, []byte("mimetypeapplication/vnd.oasis.opendocument.spreadsheet")) {
+			return ".ods"
+		}
+		return ".xlsx"
+	case bytes.HasPrefix(head, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")):
+		return ".xls"
+	}
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	if tabs := bytes.Count(line, []byte("\t")); tabs > 0 && tabs > bytes.Count(line, []byte(",")) {
+		return ".tsv"
+	}
+	return ".csv"
+}
+
+func removeInputCopy() {
+	if inputCopy != "" {
+		os.Remove(inputCopy)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCopyInputEncryptedWorkbook(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	rows := [][]interface{}{
+		{"Class No.", "EmpID", "CampusID", "Quiz (30)", "Mid-Sem (60)", "Lab Test (30)", "Weekly Labs (30)", "Pre-Compre (150)", "Compre (90)", "Final Total (240)"},
+		{"1", "12320220001", "2022A7PS0001G", 20, 40, 20, 20, 100, 60, 160},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "marks.xlsx")
+	if err := f.SaveAs(path, excelize.Options{Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	copied, err := copyInput(in, "standard input", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		removeInputCopy()
+		inputCopy, inputName, workbookPassword = "", "", ""
+	}()
+	if ext := filepath.Ext(copied); ext != ".xlsx" {
+		t.Fatalf("encrypted workbook copied as %s, want .xlsx", ext)
+	}
+
+	workbookPassword = "secret"
+	students, _, _, err := parseExcel(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 1 || students[0].EmpID != "12320220001" {
+		t.Errorf("read %+v from the encrypted workbook", students)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Run struct {
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	ID            string    `json:"id,omitempty"`
+	Tenant        string    `json:"tenant,omitempty"`
+	Course        string    `json:"course"`
+	Source        string    `json:"source,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	Approval      *Approval `json:"approval,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+	Students      []Student `json:"students"`
+	Mismatches    []Issue   `json:"mismatches"`
+	// Components are the run's discovered or mapped components, absent
+	// for the standard ones.
+	Components []exportedComponent `json:"components,omitempty"`
+	// Manifest records what the run was computed from; its RunID is the
+	// run's ID.
+	Manifest *Manifest `json:"manifest,omitempty"`
+}
+
+func (r Run) componentSet() ComponentSet {
+	return componentSetOf(r.Components)
+}
+
+type AuditEntry struct {
+	RunID  string    `json:"run_id"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+type Storage interface {
+	SaveRun(run Run) error
+	GetRun(id string) (Run, error)
+	ListRuns() ([]Run, error)
+	TransitionRun(id, from, to string) error
+	UpdateApproval(id string, approval *Approval) error
+	ListStudents(runID string) ([]Student, error)
+	AppendAudit(entry AuditEntry) error
+	ListAudit(runID string) ([]AuditEntry, error)
+	DeleteRun(id string) error
+	// SaveStats records a run's headline statistics, replacing any earlier
+	// snapshot of the same run. Snapshots outlive the run itself.
+	SaveStats(snap StatsSnapshot) error
+	// ListStats returns snapshots oldest first, for one course or all of
+	// them when course is empty.
+	ListStats(course string) ([]StatsSnapshot, error)
+	// SaveDispute creates or replaces a dispute.
+	SaveDispute(d Dispute) error
+	GetDispute(id string) (Dispute, error)
+	// ListDisputes returns disputes oldest first, for one run or all of
+	// them when runID is empty.
+	ListDisputes(runID string) ([]Dispute, error)
+	SaveNote(n Note) error
+	// ListNotes returns a run's notes oldest first.
+	ListNotes(runID string) ([]Note, error)
+	Close() error
+}
+
+// maintainer is implemented by backends with offline maintenance tasks.
+type maintainer interface {
+	Reindex() error
+	Vacuum() error
+}
+
+type StorageConfig struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+var errNotFound = errors.New("not found")
+
+func openStorage(cfg StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return openMemoryStorage(cfg.DSN)
+	case "sqlite", "sqlite3":
+		return openSQLStorage("sqlite3", cfg.DSN)
+	case "postgres":
+		return openSQLStorage("postgres", cfg.DSN)
+	}
+	return nil, fmt.Errorf("unknown storage driver %q (want memory, sqlite or postgres)", cfg.Driver)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func summarizeRun(run Run) Run {
+	run.Students = nil
+	return run
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+type memoryStorage struct {
+	mu       sync.RWMutex
+	path     string
+	Runs     map[string]Run  `json:"runs"`
+	Audit    []AuditEntry    `json:"audit"`
+	Stats    []StatsSnapshot `json:"stats,omitempty"`
+	Disputes []Dispute       `json:"disputes,omitempty"`
+	Notes    []Note          `json:"notes,omitempty"`
+}
+
+func openMemoryStorage(path string) (*memoryStorage, error) {
+	m := &memoryStorage{path: path, Runs: make(map[string]Run)}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Runs == nil {
+		m.Runs = make(map[string]Run)
+	}
+	for id, run := range m.Runs {
+		if err := migrateRun(&run); err != nil {
+			return nil, fmt.Errorf("run %s: %w", id, err)
+		}
+		internStudents(run.Students)
+		m.Runs[id] = run
+	}
+	return m, nil
+}
+
+func (m *memoryStorage) persist() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func (m *memoryStorage) SaveRun(run Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.Runs[run.ID]; ok && isImmutable(runStatus(existing)) {
+		return errRunImmutable
+	}
+	run.SchemaVersion = runSchemaVersion
+	m.Runs[run.ID] = run
+	return m.persist()
+}
+
+func (m *memoryStorage) GetRun(id string) (Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.Runs[id]
+	if !ok {
+		return Run{}, errNotFound
+	}
+	return run, nil
+}
+
+func (m *memoryStorage) TransitionRun(id, from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.Runs[id]
+	if !ok {
+		return errNotFound
+	}
+	if runStatus(run) != from {
+		return errConflict
+	}
+	run.Status = to
+	m.Runs[id] = run
+	return m.persist()
+}
+
+func (m *memoryStorage) UpdateApproval(id string, approval *Approval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.Runs[id]
+	if !ok {
+		return errNotFound
+	}
+	if isImmutable(runStatus(run)) {
+		return errRunImmutable
+	}
+	run.Approval = approval
+	m.Runs[id] = run
+	return m.persist()
+}
+
+func (m *memoryStorage) ListRuns() ([]Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	runs := make([]Run, 0, len(m.Runs))
+	for _, run := range m.Runs {
+		runs = append(runs, summarizeRun(run))
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+func (m *memoryStorage) ListStudents(runID string) ([]Student, error) {
+	run, err := m.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	return run.Students, nil
+}
+
+func (m *memoryStorage) AppendAudit(entry AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Audit = append(m.Audit, entry)
+	return m.persist()
+}
+
+func (m *memoryStorage) ListAudit(runID string) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var entries []AuditEntry
+	for _, e := range m.Audit {
+		if runID == "" || e.RunID == runID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *memoryStorage) DeleteRun(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Runs[id]; !ok {
+		return errNotFound
+	}
+	delete(m.Runs, id)
+	return m.persist()
+}
+
+func (m *memoryStorage) SaveStats(snap StatsSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Stats = slices.DeleteFunc(m.Stats, func(s StatsSnapshot) bool { return s.RunID == snap.RunID })
+	m.Stats = append(m.Stats, snap)
+	return m.persist()
+}
+
+func (m *memoryStorage) ListStats(course string) ([]StatsSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var snaps []StatsSnapshot
+	for _, s := range m.Stats {
+		if course == "" || s.Course == course {
+			snaps = append(snaps, s)
+		}
+	}
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+	return snaps, nil
+}
+
+func (m *memoryStorage) SaveDispute(d Dispute) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Disputes {
+		if m.Disputes[i].ID == d.ID {
+			m.Disputes[i] = d
+			return m.persist()
+		}
+	}
+	m.Disputes = append(m.Disputes, d)
+	return m.persist()
+}
+
+func (m *memoryStorage) GetDispute(id string) (Dispute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, d := range m.Disputes {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return Dispute{}, errNotFound
+}
+
+func (m *memoryStorage) ListDisputes(runID string) ([]Dispute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var disputes []Dispute
+	for _, d := range m.Disputes {
+		if runID == "" || d.RunID == runID {
+			disputes = append(disputes, d)
+		}
+	}
+	sort.SliceStable(disputes, func(i, j int) bool { return disputes[i].OpenedAt.Before(disputes[j].OpenedAt) })
+	return disputes, nil
+}
+
+func (m *memoryStorage) SaveNote(n Note) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Notes = append(m.Notes, n)
+	return m.persist()
+}
+
+func (m *memoryStorage) ListNotes(runID string) ([]Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var notes []Note
+	for _, n := range m.Notes {
+		if n.RunID == runID {
+			notes = append(notes, n)
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].CreatedAt.Before(notes[j].CreatedAt) })
+	return notes, nil
+}
+
+func (m *memoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sqlStorage struct {
+	db      *sql.DB
+	dialect string
+}
+
+func openSQLStorage(driver, dsn string) (*sqlStorage, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w (is the binary built with -tags %s?)", err, strings.TrimSuffix(driver, "3"))
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &sqlStorage{db: db, dialect: driver}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+	return s, nil
+}
+
+func (s *sqlStorage) rebind(query string) string {
+	if s.dialect != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *sqlStorage) SaveRun(run Run) error {
+	mismatches, err := json.Marshal(run.Mismatches)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(s.rebind(`SELECT status FROM runs WHERE id = ?`), run.ID).Scan(&status)
+	if err == nil && isImmutable(status) {
+		return errRunImmutable
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if _, err := tx.Exec(s.rebind(`DELETE FROM students WHERE run_id = ?`), run.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.rebind(`DELETE FROM runs WHERE id = ?`), run.ID); err != nil {
+		return err
+	}
+	approval, err := json.Marshal(run.Approval)
+	if err != nil {
+		return err
+	}
+	components, err := json.Marshal(run.Components)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(run.Manifest)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.rebind(`INSERT INTO runs (id, tenant, course, source, status, approval, created_at, mismatches, components, manifest) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		run.ID, run.Tenant, run.Course, run.Source, runStatus(run), string(approval), run.CreatedAt.UTC().Format(time.RFC3339Nano), string(mismatches), string(components), string(manifest)); err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare(s.rebind(`INSERT INTO students (run_id, pos, emp_id, branch, data) VALUES (?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for i, student := range run.Students {
+		data, err := json.Marshal(student)
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(run.ID, i, student.EmpID, student.Branch, string(data)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStorage) GetRun(id string) (Run, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT id, tenant, course, source, status, approval, created_at, mismatches, components, manifest FROM runs WHERE id = ?`), id)
+	run, err := scanRun(row)
+	if err != nil {
+		return Run{}, err
+	}
+	run.Students, err = s.ListStudents(id)
+	return run, err
+}
+
+func (s *sqlStorage) TransitionRun(id, from, to string) error {
+	res, err := s.db.Exec(s.rebind(`UPDATE runs SET status = ? WHERE id = ? AND status = ?`), to, id, from)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := s.GetRun(id); err != nil {
+			return err
+		}
+		return errConflict
+	}
+	return nil
+}
+
+func (s *sqlStorage) UpdateApproval(id string, approval *Approval) error {
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.rebind(`UPDATE runs SET approval = ? WHERE id = ? AND status NOT IN (?, ?)`),
+		string(data), id, statusPublished, statusArchived)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := s.GetRun(id); err != nil {
+		return err
+	}
+	return errRunImmutable
+}
+
+func (s *sqlStorage) ListRuns() ([]Run, error) {
+	rows, err := s.db.Query(`SELECT id, tenant, course, source, status, approval, created_at, mismatches, components, manifest FROM runs ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (s *sqlStorage) ListStudents(runID string) ([]Student, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT data FROM students WHERE run_id = ? ORDER BY pos`), runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var students []Student
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var student Student
+		if err := json.Unmarshal([]byte(data), &student); err != nil {
+			return nil, err
+		}
+		internStudent(&student)
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+func (s *sqlStorage) DeleteRun(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.rebind(`DELETE FROM students WHERE run_id = ?`), id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(s.rebind(`DELETE FROM runs WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStorage) Reindex() error {
+	if s.dialect != "postgres" {
+		_, err := s.db.Exec(`REINDEX`)
+		return err
+	}
+	for _, table := range []string{"runs", "students", "audit", "stats", "disputes", "notes"} {
+		if _, err := s.db.Exec(`REINDEX TABLE ` + table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStorage) Vacuum() error {
+	stmt := `VACUUM`
+	if s.dialect == "postgres" {
+		stmt = `VACUUM ANALYZE`
+	}
+	_, err := s.db.Exec(stmt)
+	return err
+}
+
+func (s *sqlStorage) AppendAudit(entry AuditEntry) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO audit (run_id, at, actor, action, detail) VALUES (?, ?, ?, ?, ?)`),
+		entry.RunID, entry.Time.UTC().Format(time.RFC3339Nano), entry.Actor, entry.Action, entry.Detail)
+	return err
+}
+
+func (s *sqlStorage) ListAudit(runID string) ([]AuditEntry, error) {
+	query := `SELECT run_id, at, actor, action, detail FROM audit`
+	var args []interface{}
+	if runID != "" {
+		query += ` WHERE run_id = ?`
+		args = append(args, runID)
+	}
+	rows, err := s.db.Query(s.rebind(query+` ORDER BY at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at string
+		if err := rows.Scan(&e.RunID, &at, &e.Actor, &e.Action, &e.Detail); err != nil {
+			return nil, err
+		}
+		e.Time, _ = time.Parse(time.RFC3339Nano, at)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlStorage) SaveStats(snap StatsSnapshot) error {
+	grades, err := json.Marshal(snap.Grades)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(s.rebind(`DELETE FROM stats WHERE run_id = ?`), snap.RunID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.rebind(`INSERT INTO stats (run_id, tenant, course, at, students, mean, stddev, pass_rate, grades) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		snap.RunID, snap.Tenant, snap.Course, snap.Time.UTC().Format(time.RFC3339Nano), snap.Students, snap.Mean, snap.StdDev, snap.PassRate, string(grades)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStorage) ListStats(course string) ([]StatsSnapshot, error) {
+	query := `SELECT run_id, tenant, course, at, students, mean, stddev, pass_rate, grades FROM stats`
+	var args []interface{}
+	if course != "" {
+		query += ` WHERE course = ?`
+		args = append(args, course)
+	}
+	rows, err := s.db.Query(s.rebind(query+` ORDER BY at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []StatsSnapshot
+	for rows.Next() {
+		var snap StatsSnapshot
+		var at, grades string
+		if err := rows.Scan(&snap.RunID, &snap.Tenant, &snap.Course, &at, &snap.Students, &snap.Mean, &snap.StdDev, &snap.PassRate, &grades); err != nil {
+			return nil, err
+		}
+		snap.Time, _ = time.Parse(time.RFC3339Nano, at)
+		if err := json.Unmarshal([]byte(grades), &snap.Grades); err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+func (s *sqlStorage) SaveDispute(d Dispute) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(s.rebind(`DELETE FROM disputes WHERE id = ?`), d.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.rebind(`INSERT INTO disputes (id, run_id, tenant, emp_id, status, opened_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		d.ID, d.RunID, d.Tenant, d.EmpID, d.Status, d.OpenedAt.UTC().Format(time.RFC3339Nano), string(data)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStorage) GetDispute(id string) (Dispute, error) {
+	var d Dispute
+	var data string
+	err := s.db.QueryRow(s.rebind(`SELECT data FROM disputes WHERE id = ?`), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, errNotFound
+	}
+	if err != nil {
+		return d, err
+	}
+	return d, json.Unmarshal([]byte(data), &d)
+}
+
+func (s *sqlStorage) ListDisputes(runID string) ([]Dispute, error) {
+	query := `SELECT data FROM disputes`
+	var args []interface{}
+	if runID != "" {
+		query += ` WHERE run_id = ?`
+		args = append(args, runID)
+	}
+	rows, err := s.db.Query(s.rebind(query+` ORDER BY opened_at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disputes []Dispute
+	for rows.Next() {
+		var d Dispute
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &d); err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, d)
+	}
+	return disputes, rows.Err()
+}
+
+func (s *sqlStorage) SaveNote(n Note) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO notes (id, run_id, tenant, emp_id, created_at, data) VALUES (?, ?, ?, ?, ?, ?)`),
+		n.ID, n.RunID, n.Tenant, n.EmpID, n.CreatedAt.UTC().Format(time.RFC3339Nano), string(data))
+	return err
+}
+
+func (s *sqlStorage) ListNotes(runID string) ([]Note, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT data FROM notes WHERE run_id = ? ORDER BY created_at`), runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var n Note
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &n); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func (s *sqlStorage) Close() error {
+	return s.db.Close()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row rowScanner) (Run, error) {
+	var run Run
+	var approval, createdAt, mismatches, components, manifest string
+	err := row.Scan(&run.ID, &run.Tenant, &run.Course, &run.Source, &run.Status, &approval, &createdAt, &mismatches, &components, &manifest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Run{}, errNotFound
+	}
+	if err != nil {
+		return Run{}, err
+	}
+	run.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	if err := json.Unmarshal([]byte(mismatches), &run.Mismatches); err != nil {
+		return Run{}, err
+	}
+	if err := json.Unmarshal([]byte(approval), &run.Approval); err != nil {
+		return Run{}, err
+	}
+	if err := json.Unmarshal([]byte(components), &run.Components); err != nil {
+		return Run{}, err
+	}
+	if err := json.Unmarshal([]byte(manifest), &run.Manifest); err != nil {
+		return Run{}, err
+	}
+	return run, nil
+}
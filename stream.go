@@ -0,0 +1,324 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// openWorkbookRows opens a workbook's sheet for streaming: the first rows,
+// which hold the header, are read up front and the rest through Next, one
+// at a time, so that memory stays flat however long the sheet is. Only
+// -color-tags needs the whole sheet at once.
+func openWorkbookRows(ctx context.Context, filePath string) (Sheet, func(), error) {
+	f, err := openWorkbook(filePath)
+	if err != nil {
+		fmt.Println("Error opening the file:", err)
+		return Sheet{}, nil, err
+	}
+	name, err := selectSheet(f)
+	if err != nil {
+		closeWorkbook(f)
+		return Sheet{}, nil, err
+	}
+	// Hidden columns and merged cells are read separately: excelize's own
+	// accessors for them load the whole worksheet.
+	info, err := scanWorksheet(filePath, name)
+	if err != nil {
+		closeWorkbook(f)
+		return Sheet{}, nil, err
+	}
+	iter, err := f.Rows(name)
+	if err != nil {
+		closeWorkbook(f)
+		return Sheet{}, nil, err
+	}
+	closeSheet := func() {
+		iter.Close()
+		closeWorkbook(f)
+	}
+	hidden, err := newHiddenFilter(info.hiddenCols)
+	if err != nil {
+		closeSheet()
+		return Sheet{}, nil, err
+	}
+	r := &rowStream{ctx: ctx, iter: iter, damaged: info.damaged}
+
+	// The stream keeps returning its error or io.EOF once it has stopped, so
+	// Next below reports it again after the header.
+	sheet := Sheet{Name: name}
+	var hiddenRows []bool
+	for len(sheet.Rows) <= maxHeaderRows {
+		row, rowHidden, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		var failure *PartialError
+		if err != nil && errors.As(err, &failure) && len(sheet.Rows) > 0 {
+			break
+		}
+		if err != nil {
+			closeSheet()
+			if failure != nil {
+				return Sheet{}, nil, failure.Err
+			}
+			return Sheet{}, nil, err
+		}
+		sheet.Rows = append(sheet.Rows, row)
+		hiddenRows = append(hiddenRows, rowHidden)
+	}
+
+	merges := make([]excelize.MergeCell, 0, len(info.merges))
+	for _, ref := range info.merges {
+		start, _, _ := strings.Cut(ref, ":")
+		col, row, err := excelize.CellNameToCoordinates(start)
+		if err != nil {
+			continue
+		}
+		// Only header cells are flattened, and so need their value.
+		value := ""
+		if row <= len(sheet.Rows) && col <= len(sheet.Rows[row-1]) {
+			value = sheet.Rows[row-1][col-1]
+		}
+		merges = append(merges, excelize.MergeCell{ref, value})
+	}
+	flattenMergedHeaders(sheet.Rows, merges)
+	sheet.Merged = mergedRows(merges)
+	for i := range sheet.Rows {
+		sheet.Rows[i] = hidden.row(sheet.Rows[i], hiddenRows[i])
+	}
+
+	reported := false
+	sheet.Next = func() ([]string, error) {
+		row, rowHidden, err := r.next()
+		if err != nil {
+			if !reported {
+				hidden.report()
+				reported = true
+			}
+			return nil, err
+		}
+		return hidden.row(row, rowHidden), nil
+	}
+
+	if sheet.Comments, err = extractComments(f, name); err != nil {
+		closeSheet()
+		return Sheet{}, nil, err
+	}
+	tags, err := parseColorTags(colorTags)
+	if err != nil {
+		closeSheet()
+		return Sheet{}, nil, err
+	}
+	if len(tags) > 0 {
+		// Cell styles can only be read with the whole worksheet loaded.
+		err := sheet.readAll()
+		var failure *PartialError
+		if err != nil && !errors.As(err, &failure) {
+			closeSheet()
+			return Sheet{}, nil, err
+		}
+		if failure != nil {
+			return sheet, closeSheet, failure
+		}
+		if sheet.Annotations, err = extractColorTags(f, name, sheet.Rows, tags); err != nil {
+			closeSheet()
+			return Sheet{}, nil, err
+		}
+	}
+	return sheet, closeSheet, nil
+}
+
+// rowStream reads a sheet through excelize's row iterator, one row ahead of
+// the caller: the iterator stops silently at malformed XML, and the row it
+// stopped in may be incomplete, so when the sheet turns out to be damaged
+// that row is dropped rather than parsed.
+type rowStream struct {
+	ctx     context.Context
+	iter    *excelize.Rows
+	damaged error
+
+	read   int
+	primed bool
+	row    []string
+	hidden bool
+	ok     bool
+	err    error
+}
+
+func (r *rowStream) next() ([]string, bool, error) {
+	if !r.primed {
+		r.primed = true
+		r.fetch()
+	}
+	if r.err != nil {
+		return nil, false, r.err
+	}
+	if !r.ok {
+		return nil, false, io.EOF
+	}
+	row, hidden := r.row, r.hidden
+	r.fetch()
+	if !r.ok && r.err == nil && r.damaged != nil {
+		r.err = &PartialError{Stage: "read", Row: r.read, Err: r.damaged}
+		return nil, false, r.err
+	}
+	return row, hidden, nil
+}
+
+func (r *rowStream) fetch() {
+	r.row, r.hidden, r.ok = nil, false, false
+	if !r.iter.Next() {
+		if err := r.iter.Error(); err != nil {
+			r.err = &PartialError{Stage: "read", Row: r.read + 1, Err: err}
+		}
+		return
+	}
+	if r.read%jobStepRows == 0 {
+		if err := jobStep(r.ctx, "read", r.read); err != nil {
+			r.err = err
+			return
+		}
+	}
+	row, err := r.iter.Columns()
+	if err != nil {
+		r.err = &PartialError{Stage: "read", Row: r.read + 1, Err: err}
+		return
+	}
+	r.read++
+	if err := checkStudentLimit(r.read); err != nil {
+		r.err = err
+		return
+	}
+	r.row, r.hidden, r.ok = row, r.iter.GetRowOpts().Hidden, true
+}
+
+// worksheetInfo is what scanWorksheet finds besides the cell values.
+type worksheetInfo struct {
+	hiddenCols map[int]bool
+	// merges are the ranges of merged cells.
+	merges []string
+	// damaged is set when the worksheet XML could not be read to the end.
+	damaged error
+}
+
+// scanWorksheet reads the column settings and merged cells of a sheet,
+// decoding its XML as a stream instead of loading it, and checks on the way
+// that the XML is well formed.
+func scanWorksheet(filePath, sheet string) (worksheetInfo, error) {
+	info := worksheetInfo{hiddenCols: make(map[int]bool)}
+	zr, closePackage, err := openPackage(filePath)
+	if err != nil {
+		return info, err
+	}
+	defer closePackage()
+	parts := make(map[string]*zip.File)
+	for _, zf := range zr.File {
+		parts[zf.Name] = zf
+	}
+
+	sheetPath, err := worksheetPath(parts, sheet)
+	if err != nil {
+		return info, err
+	}
+	rc, err := parts[sheetPath].Open()
+	if err != nil {
+		return info, err
+	}
+	defer rc.Close()
+
+	d := xml.NewDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return info, nil
+		}
+		if err != nil {
+			info.damaged = err
+			return info, nil
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "col":
+			if xmlAttr(se, "hidden") != "1" && xmlAttr(se, "hidden") != "true" {
+				continue
+			}
+			lo, _ := strconv.Atoi(xmlAttr(se, "min"))
+			hi, _ := strconv.Atoi(xmlAttr(se, "max"))
+			for col := max(lo, 1); col <= min(hi, excelize.MaxColumns); col++ {
+				info.hiddenCols[col] = true
+			}
+		case "mergeCell":
+			ref := xmlAttr(se, "ref")
+			start, _, _ := strings.Cut(ref, ":")
+			if _, _, err := excelize.CellNameToCoordinates(start); err == nil {
+				info.merges = append(info.merges, ref)
+			}
+		}
+	}
+}
+
+// worksheetPath finds the part holding sheet, through the workbook and its
+// relationships, as excelize does.
+func worksheetPath(parts map[string]*zip.File, sheet string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if err := decodePart(parts, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, s := range workbook.Sheets {
+		if !strings.EqualFold(s.Name, sheet) {
+			continue
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID != s.ID {
+				continue
+			}
+			target := strings.TrimPrefix(rel.Target, "/")
+			if !strings.HasPrefix(rel.Target, "/") {
+				target = path.Join("xl", rel.Target)
+			}
+			if _, ok := parts[target]; ok {
+				return target, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("worksheet %s not found in the workbook", sheet)
+}
+
+func decodePart(parts map[string]*zip.File, name string, v interface{}) error {
+	zf, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("not a workbook: %s is missing", name)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
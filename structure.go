@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// IgnoredRow is a row below the header that holds no student: a blank
+// separator, a merged title, a footer, a summary such as class averages,
+// or a note.
+type IgnoredRow struct {
+	Row  int
+	Kind string
+	Text string
+}
+
+// ignoredKinds orders the kinds of ignored row in the summary.
+var ignoredKinds = []string{"blank", "merged title", "footer", "summary", "note"}
+
+var footerPattern = regexp.MustCompile(`(?i)^(prepared|checked|verified|approved|compiled|generated|printed|signed)\b|^signature|^date\s*:`)
+
+// structuralRow tells what kind of row a non-blank row is when it is part
+// of the sheet's structure rather than a student, or "" when it is to be
+// read as a student. Student rows always have digits in an ID cell, so
+// only rows without are considered; merged is whether a range merged
+// across columns starts in the row. text is its first non-empty cell.
+func structuralRow(row []string, layout Layout, merged bool) (kind, text string) {
+	if hasDigit(row, layout.EmpID) || hasDigit(row, layout.CampusID) {
+		return "", ""
+	}
+	numeric := false
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if text == "" {
+			text = cell
+		}
+		if _, err := strconv.ParseFloat(cell, 64); err == nil {
+			numeric = true
+		}
+	}
+	switch {
+	case merged:
+		return "merged title", text
+	case footerPattern.MatchString(text):
+		return "footer", text
+	case numeric:
+		return "summary", text
+	}
+	return "note", text
+}
+
+func hasDigit(row []string, col int) bool {
+	return col >= 0 && col < len(row) && strings.ContainsAny(row[col], "0123456789")
+}
+
+// mergedRows are the rows, 1-based, where a range merged across columns
+// starts, as a title spanning the sheet does.
+func mergedRows(merges []excelize.MergeCell) map[int]bool {
+	rows := make(map[int]bool)
+	for _, m := range merges {
+		startCol, startRow, err := excelize.CellNameToCoordinates(m.GetStartAxis())
+		if err != nil {
+			continue
+		}
+		if endCol, _, err := excelize.CellNameToCoordinates(m.GetEndAxis()); err == nil && endCol > startCol {
+			rows[startRow] = true
+		}
+	}
+	return rows
+}
+
+// printIgnoredRows sums up the rows skipped as not being students, e.g.
+// "Ignored rows: 3 (2 blank, 1 footer)".
+func printIgnoredRows(rows []IgnoredRow) {
+	if len(rows) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.Kind]++
+	}
+	var parts []string
+	for _, kind := range ignoredKinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	fmt.Printf("Ignored rows: %d (%s)\n", len(rows), strings.Join(parts, ", "))
+}
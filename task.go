@@ -1,241 +1,1174 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
-	"sort"
+	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/xuri/excelize/v2"
 )
 
 type Student struct {
-	EmpID  string
-	Branch string
-	Marks  map[string]float64
-	Total  float64
+	EmpID    string
+	CampusID string
+	ClassNo  string `json:",omitempty"`
+	Name     string `json:",omitempty"`
+	Email    string `json:",omitempty"`
+	Advisor  string `json:",omitempty"`
+	Branch   string
+	Row      int
+	Source   string `json:",omitempty"`
+	Sheet    string `json:",omitempty"`
+	// Cells names the source cell of each field, e.g. "Final Total": "K12".
+	Cells       map[string]string `json:",omitempty"`
+	Marks       map[string]float64
+	Total       float64
+	Comments    map[string]string  `json:",omitempty"`
+	Annotations map[string]string  `json:",omitempty"`
+	Cluster     int                `json:",omitempty"`
+	Items       map[string]float64 `json:",omitempty"`
+	Computed    map[string]float64 `json:",omitempty"`
+	// Extra holds the student's cells in the sheet's extra columns, by
+	// column name (see ExtraColumn).
+	Extra map[string]string `json:",omitempty"`
+}
+
+type Sheet struct {
+	Rows [][]string
+	// Name is the worksheet the rows were read from, empty for CSV.
+	Name string
+	// Next, when set, reads the rows after Rows one at a time and returns
+	// io.EOF after the last, so that a large sheet is parsed as it is read.
+	Next        func() ([]string, error)
+	Comments    map[string]string
+	Annotations map[string]string
+	// Merged are the rows, 1-based, where a range merged across columns
+	// starts.
+	Merged map[int]bool
+}
+
+// readAll reads the rest of a streamed sheet into Rows, leaving out blank
+// rows at the end.
+func (s *Sheet) readAll() error {
+	if s.Next == nil {
+		return nil
+	}
+	next := s.Next
+	s.Next = nil
+	used := len(s.Rows)
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Rows = s.Rows[:used]
+			return err
+		}
+		s.Rows = append(s.Rows, row)
+		if len(row) > 0 {
+			used = len(s.Rows)
+		}
+	}
+	s.Rows = s.Rows[:used]
+	return nil
 }
 
 var (
-	components  = []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Pre-Compre", "Compre"}
-	exportJSON  bool
-	classFilter string
+	components    = []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Pre-Compre", "Compre"}
+	totalParts    = []string{"Quiz", "Mid-Sem", "Lab Test", "Weekly Labs", "Compre"}
+	componentMax  = map[string]float64{"Quiz": 30, "Mid-Sem": 75, "Lab Test": 60, "Weekly Labs": 30, "Pre-Compre": 195, "Compre": 105}
+	exportJSON    bool
+	classFilter   string
+	hiddenPolicy  string
+	colorTags     string
+	configPath    string
+	courseName    string
+	numClusters   int
+	predictFrom   string
+	riskBelow     float64
+	saveRun       bool
+	userName      string
+	resume        bool
+	checkpointN   int
+	decimals      int
+	decimalSep    string
+	decimalComma  bool
+	failOn        string
+	waiversPath   string
+	explain       bool
+	rosterPath    string
+	mappingPath   string
+	fromJSON      bool
+	ignoreColumns string
+	discover      bool
+	profileName   string
+	profileDir    string
+	waivers       []Waiver
+	sortSpec      string
+	sheetName     string
+	sortKeys      []SortKey
 )
 
 func init() {
 	flag.BoolVar(&exportJSON, "export", false, "Export report as JSON")
+	flag.BoolVar(&exportCSV, "export-csv", false, "Export the students (marks, computed total, rank) and issues as students.csv and mismatches.csv")
+	flag.BoolVar(&exportXLSX, "export-xlsx", false, "Export report as a formatted workbook, output.xlsx, with Students, Branch Averages, Component Averages and Validation Errors sheets")
+	flag.BoolVar(&exportHTML, "export-html", false, "Export report as a standalone page, output.html, with sortable and filterable tables of students, branch statistics and mismatches")
+	flag.BoolVar(&keepColumns, "keep-columns", false, "Carry the sheet's other columns (remarks, tutorial section, ...) through to the CSV and Excel exports, after the computed ones")
 	flag.StringVar(&classFilter, "class", "", "Filter by Class ID")
-	flag.Parse()
+	flag.StringVar(&hiddenPolicy, "hidden", "include", "Hidden rows and columns: include or skip")
+	flag.StringVar(&colorTags, "color-tags", "", "Annotate filled cells, e.g. FFFF00=provisional,FF0000=re-evaluated")
+	flag.StringVar(&configPath, "config", "", "Path to JSON config file")
+	flag.StringVar(&profileName, "profile", "", "Named config profile from -profile-dir, e.g. cs101")
+	flag.StringVar(&profileDir, "profile-dir", defaultProfileDir(), "Directory holding <name>.json profiles")
+	flag.StringVar(&courseName, "course", "", "Course code recorded in exports")
+	flag.IntVar(&numClusters, "clusters", 0, "Group students into k performance clusters (0 disables)")
+	flag.StringVar(&predictFrom, "predict-from", "", "Comma-separated prior-semester run exports used to predict Compre")
+	flag.Float64Var(&riskBelow, "risk-below", 40, "Flag students whose predicted Compre is below this percentage")
+	flag.StringVar(&followupDir, "followup-ics", "", "Write calendar invites for follow-up meetings with at-risk students (-predict-from) to this directory")
+	flag.StringVar(&followupBy, "followup-by", "branch", "Batch follow-up invites into one calendar per branch or advisor (the roster's Advisor column)")
+	flag.StringVar(&followupStart, "followup-start", "", "First follow-up meeting, as YYYY-MM-DD HH:MM local time (default: next Monday 10:00)")
+	flag.IntVar(&followupMinutes, "followup-minutes", 20, "Length of each follow-up meeting in minutes")
+	flag.BoolVar(&saveRun, "save", false, "Save the run to the configured storage")
+	flag.StringVar(&userName, "user", cliActor(), "User name recorded in the audit log")
+	flag.BoolVar(&resume, "resume", false, "Resume from the checkpoint left by an interrupted run")
+	flag.IntVar(&checkpointN, "checkpoint-every", 1000, "Save a checkpoint every N rows (0 disables)")
+	flag.IntVar(&decimals, "decimals", 2, "Decimal places in reports")
+	flag.StringVar(&decimalSep, "decimal-sep", ".", "Decimal separator in reports, e.g. \",\"")
+	flag.BoolVar(&decimalComma, "decimal-comma", false, "Read marks written with a decimal comma, e.g. 23,5 or 1.234,5 (marks with a decimal point still read as before)")
+	flag.StringVar(&waiversPath, "waivers", "", "JSON file of known-acceptable issues (emp_id, rule, reason) to report as waived")
+	flag.StringVar(&rosterPath, "roster", "", "CSV roster of enrolled students (EmpID, optionally Name, Email, Advisor): students not on it and enrolled students missing from the sheet are reported, and names and emails joined onto students")
+	flag.BoolVar(&fromJSON, "from-json", false, "Read the input as a JSON report written by -export, whatever its name (.json files always are)")
+	flag.StringVar(&mappingPath, "mapping", "", "JSON or YAML file saying which column holds each field (overrides the config's mapping)")
+	flag.StringVar(&ignoreColumns, "ignore-columns", "", "Comma-separated columns to treat as blank, by header name, letter (E) or number (5), e.g. \"Remarks,Sl No\"")
+	flag.BoolVar(&discover, "discover", false, "Treat every numeric column after the ID columns as a component named by its header")
+	flag.BoolVar(&strictSchema, "strict", false, "Check the sheet's columns, header names and cell types before reading it, and stop listing every problem found instead of reading what it can")
+	flag.BoolVar(&explain, "explain", false, "Annotate each issue with what the rule checks, the cells involved and a suggested fix")
+	flag.IntVar(&maxWorkbooks, "max-workbooks", 8, "Most workbooks open at once; further opens fail (0 means no limit)")
+	flag.IntVar(&maxStudents, "max-students", 500000, "Most students read from one sheet (0 means no limit)")
+	flag.IntVar(&maxExportMB, "max-export-mb", 256, "Largest JSON export or API response in MB (0 means no limit)")
+	flag.StringVar(&sortSpec, "sort", "", "Order of rankings and exports, e.g. \"total desc, compre desc\" or \"branchpercentile desc\" (default total desc)")
+	flag.StringVar(&inputFormat, "format", "auto", "Input format: xlsx, xls, csv, tsv, ods, or auto to go by the file extension")
+	flag.StringVar(&csvDelimiter, "delimiter", "", "Field delimiter of CSV and TSV input, a single character or \"tab\" (default: tab for .tsv and .txt, otherwise comma or semicolon)")
+	flag.StringVar(&relativeGrading, "relative-grading", "", "Compare global grade boundaries with grading relative to each branch or section (branch, section)")
+	flag.StringVar(&downloadToken, "download-token", os.Getenv("DOWNLOAD_TOKEN"), "Bearer token sent when the file argument is an http(s) URL to download")
+	flag.StringVar(&workbookPassword, "password", os.Getenv("WORKBOOK_PASSWORD"), "Password to open a password-protected .xlsx workbook")
+	flag.StringVar(&googleCredentials, "google-credentials", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "Service account key for reading Google Sheets through the Sheets API (default: download link-shared sheets as CSV)")
+	flag.StringVar(&sheetName, "sheet", "", "Worksheet to read, by name or 1-based position (default: the first sheet)")
+	flag.IntVar(&batchJobs, "jobs", runtime.NumCPU(), "Workbooks processed at once when given a directory or glob (at most -max-workbooks)")
+	flag.StringVar(&batchOut, "batch-out", "batch-report", "Directory for per-file and combined reports when given a directory or glob")
+	flag.StringVar(&templatePath, "template", "", "Fill this .xlsx report template ({{placeholders}} in cells, headers and footers) with the results")
+	flag.StringVar(&templateOut, "template-out", "report.xlsx", "Where to write the filled -template")
+	flag.StringVar(&docxOut, "docx", "", "Write the summary report as a Word document to this path")
+	flag.StringVar(&docxTemplate, "docx-template", "", "Word template for -docx, with the same {{placeholders}} as -template (default: a plain summary)")
+	flag.StringVar(&latexOut, "latex", "", "Write the summary report as a LaTeX document, with pgfplots data tables, to this path")
+	flag.StringVar(&annotateOut, "annotate", "", "Write a copy of the .xlsx input to this path with the cells failing validation filled red and commented with what was expected and found")
+	flag.IntVar(&minCohort, "min-cohort", 5, "Pool branches with fewer students than this in shared summaries (-pdf, -template, -docx, -latex, shared summary links), both in branch rows and beside students' marks, withholding them if still too few; 0 shows every branch")
+	flag.BoolVar(&perBranch, "per-branch", false, "Also write each export once per branch (output-A7.json, students-A7.csv, ...), with only that branch's students and issues")
+	flag.IntVar(&maskIDs, "mask-ids", 0, "Show only the last N characters of student IDs in console output, e.g. 4 (exports keep full IDs; 0 shows them in full)")
+	flag.BoolVar(&showTimings, "timings", false, "Report the wall time and rows per second of each stage (open, parse, validate, rank, stats, export) at the end of the run")
+	flag.StringVar(&alertSpec, "alert", "", "Mark the run as needing attention, and notify, when a statistic crosses a threshold, e.g. pass_rate<70,branch_divergence>15,errors>50 (added to the config's alerts)")
+	flag.StringVar(&pdfOut, "pdf", "", "Write the summary report (averages, top 3 tables and validation issues) as a PDF to this path")
+	flag.StringVar(&splitOut, "split-branches", "", "Write each branch's ranking and statistics to its own workbook in this directory (or its own sheet, when this ends in .xlsx)")
+	flag.StringVar(&sqlFile, "sql-file", "", "Run the SQL in this file on the results, loaded into an embedded DuckDB (tables students, marks and issues), and append its result tables to the report; needs -tags duckdb")
+	flag.StringVar(&analyticsEngine, "engine", "go", "Compute the ranks and statistics in go or, with window functions and quantiles, in an embedded duckdb (needs -tags duckdb)")
+	flag.StringVar(&manifestOut, "manifest", "", "Write the run's reproducibility manifest (build, config and input hashes, flags, seeds) to this path")
+	flag.StringVar(&failOn, "fail-on", "never", "Exit non-zero (or reject uploads) on issues: error, warning or never")
+}
+
+var commands = map[string]func(args []string){
+	"generate":    runGenerate,
+	"fuzz":        runFuzz,
+	"consolidate": runConsolidate,
+	"research":    runResearch,
+	"serve":       runServe,
+	"runs":        runRuns,
+	"admin":       runAdmin,
+	"explain":     runExplain,
+	"fix":         runFix,
+	"map":         runMap,
+	"doctor":      runDoctor,
+	"sample":      runSample,
+	"reconcile":   runReconcile,
+	"rank":        runRank,
+	"disputes":    runDisputes,
+	"merge":       runMerge,
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <path-to-excel-file>")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: go run . [flags] <path-to-xlsx-xls-ods-csv-or-tsv-file>")
+		fmt.Println("       go run . [flags] - < gradebook.xlsx")
+		fmt.Println("       go run . [-download-token T] [flags] <https-url-of-file>")
+		fmt.Println("       go run . [-config config.json] [flags] s3://<bucket>/<key>")
+		fmt.Println("       go run . [flags] <directory-or-glob>")
+		fmt.Println("       go run . [-google-credentials key.json] [flags] <google-sheets-url-or-id>")
+		fmt.Println("       go run . generate [flags]")
+		fmt.Println("       go run . fuzz [flags] <path-to-excel-file>")
+		fmt.Println("       go run . consolidate <run.json>...")
+		fmt.Println("       go run . research [flags] <path-to-excel-file>")
+		fmt.Println("       go run . serve [flags]")
+		fmt.Println("       go run . runs <command> [flags]")
+		fmt.Println("       go run . admin <command> [flags]")
+		fmt.Println("       go run . explain [rule-id]")
+		fmt.Println("       go run . fix [-apply] <path-to-excel-file>")
+		fmt.Println("       go run . map [-o course.json] <path-to-excel-file>")
+		fmt.Println("       go run . doctor")
+		fmt.Println("       go run . sample [-n 10] [-seed 1] <path-to-excel-file>")
+		fmt.Println("       go run . reconcile <path-to-excel-file> <totals-file>")
+		fmt.Println("       go run . reconcile -registration <roster-file> <path-to-excel-file>")
+		fmt.Println("       go run . rank [-component Compre] <path-to-excel-file>")
+		fmt.Println("       go run . disputes <command> [flags]")
+		fmt.Println("       go run . [flags] merge <section-file> <section-file>...")
 		return
 	}
 
-	filePath := os.Args[1]
-	students, err := parseExcel(filePath)
+	if err := resolveProfile(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := applyExportPrefs(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateNumberFormat(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateInputFormat(inputFormat); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateDelimiter(csvDelimiter); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if _, err := parseAlertRules(alertSpec); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateRelativeGrading(relativeGrading); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateFailOn(failOn); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := validateEngine(analyticsEngine); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	keys, err := parseSortSpec(sortSpec)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	sortKeys = keys
+	w, err := loadWaivers(waiversPath)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
+	waivers = w
 
-	var wg sync.WaitGroup
-	mismatchCh := make(chan string, len(students))
+	if cmd, ok := commands[flag.Arg(0)]; ok {
+		cmd(flag.Args()[1:])
+		return
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		validateData(students, mismatchCh)
-	}()
+	filePath := flag.Arg(0)
+	if paths, ok, err := batchInputs(filePath); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	} else if ok {
+		runBatch(paths)
+		return
+	}
+	source := filePath
+	if filePath == "-" {
+		if filePath, err = readStdin(); err != nil {
+			fmt.Println("Error reading standard input:", err)
+			os.Exit(1)
+		}
+		source = "stdin"
+		defer removeInputCopy()
+	} else if isDownload(filePath) {
+		if filePath, err = downloadInput(context.Background(), filePath); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		defer removeInputCopy()
+	} else if isS3(filePath) {
+		if filePath, err = fetchS3(context.Background(), filePath); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		defer removeInputCopy()
+	}
+	students, layout, issues, err := parseExcel(filePath)
+	var failure *PartialError
+	if err != nil && !errors.As(err, &failure) {
+		fmt.Println("Error:", err)
+		removeInputCopy()
+		os.Exit(1)
+	}
 
-	wg.Wait()
-	close(mismatchCh)
+	report(source, []string{filePath}, students, layout, issues, failure)
+}
 
-	var mismatches []string
-	for msg := range mismatchCh {
-		mismatches = append(mismatches, msg)
-	}
+// report validates the parsed students and prints the report, along with
+// every export asked for. inputs are the files the students were read from.
+func report(source string, inputs []string, students []Student, layout Layout, issues []Issue, failure *PartialError) {
+	set := layout.componentSet()
+	// One pass over the students feeds validation, the statistics and the
+	// rankings; the stages below only print what it gathered.
+	var a analysis
+	runStage(&failure, "validation", func() {
+		validated := startTiming("validate")
+		defer func() { validated(len(students)) }()
+		a = analyze(students, set)
+		mismatches := applyWaivers(a.Mismatches, waivers)
+		issues = append(issues, mismatches...)
+
+		fmt.Println("\nValidation Errors:")
+		if active := unwaived(mismatches); len(active) > 0 {
+			for _, issue := range active {
+				fmt.Println(maskMessage(issue))
+				if explain {
+					fmt.Print(explainIssue(issue, layout))
+				}
+			}
+		} else {
+			fmt.Println("No validation errors found.")
+		}
+		if n := countIssues(mismatches, severityWaived); n > 0 {
+			fmt.Printf("\nWaived (%d):\n", n)
+			for _, issue := range mismatches {
+				if issue.Severity == severityWaived {
+					fmt.Printf("%s (%s)\n", maskMessage(issue), issue.Waiver)
+				}
+			}
+		}
+	})
+
+	runStage(&failure, "cell notes", func() {
+		printCellNotes("Cell Comments", students, func(s Student) map[string]string { return s.Comments })
+		printCellNotes("Cell Annotations", students, func(s Student) map[string]string { return s.Annotations })
+	})
 
-	fmt.Println("\nValidation Errors:")
-	if len(mismatches) > 0 {
-		for _, msg := range mismatches {
-			fmt.Println(msg)
+	// A sheet whose rows were all skipped, or that has none, has nothing
+	// to average, rank or model.
+	if len(students) > 0 {
+		runStage(&failure, "ranking", func() {
+			ranked := startTiming("rank")
+			rankStudents(students, set, a.Totals)
+			ranked(len(students))
+		})
+	}
+	var engine *sql.DB
+	if useDuckDB() {
+		runStage(&failure, "duckdb", func() {
+			db, err := openAnalytics(set, students, issues)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			engine = db
+		})
+	}
+	if engine != nil {
+		defer engine.Close()
+	}
+	stats := startTiming("stats")
+	rep := buildReport(students, a)
+	if engine != nil && analyticsEngine == "duckdb" {
+		if err := engineStatistics(engine, rep); err != nil {
+			fmt.Println("Error computing statistics in DuckDB:", err)
 		}
+	}
+	if len(students) == 0 {
+		fmt.Println("\nNo student rows matched: no averages, rankings or statistics to report.")
 	} else {
-		fmt.Println("No validation errors found.")
+		reportStatistics(&failure, rep, layout)
 	}
+	stats(len(students))
 
-	calculateAverages(students)
-	calculateBranchAverages(students)
-	rankStudents(students)
+	var queries []QueryResult
+	if sqlFile != "" && engine != nil {
+		runStage(&failure, "sql", func() {
+			results, err := runSQLFile(engine, sqlFile)
+			printQueryResults(results)
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+			queries = results
+		})
+	}
+
+	var alerts []Alert
+	runStage(&failure, "alerts", func() {
+		cfg, err := loadConfig(configPath)
+		if err == nil {
+			var rules []AlertRule
+			if rules, err = alertRules(cfg); err == nil && len(rules) > 0 {
+				alerts = evaluateAlerts(cfg, rules, courseName, set, students, issues)
+				printAlerts(alerts)
+			}
+		}
+		if err != nil {
+			fmt.Println("Error evaluating alerts:", err)
+		}
+	})
 
-	if exportJSON {
-		exportToJSON(students, mismatches)
+	if failure != nil {
+		printPartialMarker(failure)
+		issues = append(issues, rowError("partial", failure.Row, "PARTIAL RUN: %v", failure))
 	}
-}
 
-func parseExcel(filePath string) ([]Student, error) {
-	f, err := excelize.OpenFile(filePath)
-	if err != nil {
-		fmt.Println("Error opening the file:", err)
-		return nil, err
+	exported := startTiming("export")
+	manifest := buildManifest(inputs)
+	// A saved run's ID is its tenant's (see saveToStorage).
+	if saveRun {
+		if cfg, err := loadConfig(configPath); err == nil {
+			manifest = manifest.forTenant(cfg.user(userName).Tenant)
+		}
+	}
+	fmt.Println("\nRun ID:", manifest.RunID)
+	if manifestOut != "" {
+		if err := writeExport(manifestOut, manifest); err != nil {
+			fmt.Println("Error writing manifest:", err)
+		} else {
+			fmt.Println("Manifest written to", manifestOut)
+		}
 	}
-	defer f.Close()
 
-	rows, err := f.GetRows(f.GetSheetName(0))
-	if err != nil {
-		return nil, err
+	// A saved run is announced, alerts and all, by saveToStorage.
+	if len(alerts) > 0 && !saveRun {
+		cfg, _ := loadConfig(configPath)
+		n := RunNotification{Event: "run.needs_attention", RunID: manifest.RunID, Course: courseName, Status: "unsaved",
+			Count: len(students), Errors: countIssues(issues, severityError), Alerts: alertTexts(alerts)}
+		for _, err := range sendNotifications(cfg.Notify, n) {
+			fmt.Println("Error sending notification:", err)
+		}
 	}
 
-	var students []Student
+	rep.Issues, rep.Items, rep.Queries, rep.Manifest, rep.Failure, rep.Alerts = issues, layout.Items, queries, manifest, failure, alerts
+	if keepColumns {
+		rep.Extra = extraNames(layout, students)
+	}
 
-	for i, row := range rows {
-		if i == 0 || len(row) < 11 {
-			continue
+	if templatePath != "" {
+		cfg, err := loadConfig(configPath)
+		if err == nil {
+			err = fillTemplate(cfg, rep, templatePath, templateOut)
+		}
+		if err != nil {
+			fmt.Println("Error filling template:", err)
+		} else {
+			fmt.Println("Report written to", templateOut)
 		}
+	}
 
-		empID := row[2]
-		campusID := row[3]
+	if docxOut != "" {
+		cfg, err := loadConfig(configPath)
+		if err == nil {
+			err = fillDocx(cfg, rep, docxTemplate, docxOut)
+		}
+		if err != nil {
+			fmt.Println("Error writing Word report:", err)
+		} else {
+			fmt.Println("Word report written to", docxOut)
+		}
+	}
 
-		if len(campusID) < 6 {
-			fmt.Printf("Warning: Skipping row %d due to invalid CampusID format (%s)\n", i+1, campusID)
-			continue
+	if latexOut != "" {
+		cfg, err := loadConfig(configPath)
+		if err == nil {
+			err = writeLatex(cfg, rep, latexOut)
+		}
+		if err != nil {
+			fmt.Println("Error writing LaTeX report:", err)
+		} else {
+			fmt.Println("LaTeX report written to", latexOut)
 		}
+	}
 
-		branch := campusID[4:6]
+	if annotateOut != "" {
+		if len(inputs) != 1 {
+			fmt.Println("Error: -annotate needs a single .xlsx input")
+		} else if n, err := annotateWorkbook(inputs[0], annotateOut, students, issues, layout); err != nil {
+			fmt.Println("Error writing annotated workbook:", err)
+		} else {
+			fmt.Printf("Annotated workbook written to %s (%d cells marked)\n", annotateOut, n)
+		}
+	}
 
-		student := Student{
-			EmpID:  empID,
-			Branch: branch,
-			Marks:  make(map[string]float64),
+	if splitOut != "" {
+		paths, err := writeBranchSplit(students, set, splitOut)
+		if err != nil {
+			fmt.Println("Error writing branch workbooks:", err)
+		} else {
+			fmt.Printf("Branch workbooks written: %s\n", strings.Join(paths, ", "))
 		}
+	}
 
-		for j, comp := range components {
-			mark, _ := strconv.ParseFloat(row[j+4], 64)
-			student.Marks[comp] = mark
+	for _, r := range renderers() {
+		if err := r.Render(rep); err != nil {
+			fmt.Println("Error:", err)
+		}
+	}
+	if perBranch {
+		for _, sub := range branchReports(rep) {
+			for _, r := range branchRenderers(sub.Branch) {
+				if err := r.Render(sub); err != nil {
+					fmt.Println("Error:", err)
+				}
+			}
 		}
+	}
+	exported(len(students))
 
-		finalTotal, _ := strconv.ParseFloat(row[10], 64)
-		student.Marks["Final Total"] = finalTotal
+	if saveRun {
+		saved := startTiming("save")
+		saveToStorage(manifest, source, set, students, issues)
+		saved(len(students))
+	}
+	printTimings()
 
-		students = append(students, student)
+	if shouldFail(failOn, issues) {
+		fmt.Printf("\nFailing (-fail-on %s): %d errors, %d warnings\n", failOn, countIssues(issues, severityError), countIssues(issues, severityWarning))
+		removeInputCopy()
+		os.Exit(1)
 	}
+}
+
+// reportStatistics prints the averages, rankings and the analyses asked
+// for, for report.
+func reportStatistics(failure **PartialError, rep *Report, layout Layout) {
+	students := rep.Students
+	runStage(failure, "averages", func() { consoleRenderer{}.Render(rep) })
 
-	return students, nil
+	if relativeGrading != "" {
+		runStage(failure, "relative grading", func() {
+			cfg, err := loadConfig(configPath)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			printRelativeGrading(cfg, rep.Set, students, relativeGrading)
+		})
+	}
+
+	if numClusters > 0 {
+		runStage(failure, "clustering", func() { clusterStudents(students, rep.Set, numClusters) })
+	}
+
+	if predictFrom != "" {
+		runStage(failure, "prediction", func() {
+			risks := predictCompre(students, rep.Set, predictFrom, riskBelow)
+			if followupDir != "" && risks != nil {
+				if err := writeFollowups(students, rep.Set, risks, followupDir); err != nil {
+					fmt.Println("Error writing follow-up invites:", err)
+				}
+			}
+		})
+	}
+
+	if len(layout.Items) > 0 {
+		runStage(failure, "item analysis", func() { analyzeItems(students, layout.Items) })
+	}
 }
 
-func validateData(students []Student, mismatchCh chan<- string) {
-	for _, student := range students {
-		expectedI := student.Marks["Quiz"] + student.Marks["Mid-Sem"] + student.Marks["Lab Test"] + student.Marks["Weekly Labs"]
-		if expectedI != student.Marks["Pre-Compre"] {
-			mismatchCh <- fmt.Sprintf("Mismatch in E+F+G+H != I for EmpID %s", student.EmpID)
+func parseExcel(filePath string) ([]Student, Layout, []Issue, error) {
+	return parseExcelContext(context.Background(), filePath)
+}
+
+// parseExcelContext is parseExcel for server jobs: cancelling ctx stops
+// reading and parsing, and progress is reported to the job running under it.
+func parseExcelContext(ctx context.Context, filePath string) ([]Student, Layout, []Issue, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, Layout{}, nil, err
+	}
+
+	if isExport(filePath) {
+		parsed := startTiming("parse")
+		students, layout, err := loadExportedStudents(filePath)
+		if err != nil {
+			return nil, Layout{}, nil, err
 		}
+		err = applyComputedColumns(students, layout.componentSet(), layout.Items, cfg.Columns)
+		parsed(len(students))
+		return students, layout, nil, err
+	}
 
-		expectedTotal := student.Marks["Pre-Compre"] + student.Marks["Compre"]
-		actualTotal, exists := student.Marks["Final Total"]
+	opened := startTiming("open")
+	sheet, closeSheet, err := openRows(ctx, filePath)
+	opened(len(sheet.Rows))
+	var failure *PartialError
+	if err != nil && !errors.As(err, &failure) {
+		return nil, Layout{}, nil, err
+	}
+	defer closeSheet()
 
-		if exists && expectedTotal != actualTotal {
-			mismatchCh <- fmt.Sprintf("Mismatch in I+J != K for EmpID %s (Expected: %.2f, Found: %.2f)", student.EmpID, expectedTotal, actualTotal)
+	opts := parseOptions(cfg)
+	opts.Discover = discover
+	opts.Strict = opts.Strict || strictSchema
+	opts.Context = ctx
+	// A live Google Sheet, or a copy of standard input, a download or an S3
+	// object, has no file to tell whether it changed since a checkpoint, so
+	// it is always read in full.
+	remote := isGoogleSheet(filePath) || filePath == inputCopy
+	var resumed Checkpoint
+	if resume && !remote {
+		cp, ok, err := loadCheckpoint(filePath)
+		if err != nil {
+			return nil, Layout{}, nil, err
+		}
+		if ok {
+			fmt.Printf("Resuming %s after row %d (%d students already parsed)\n", filePath, cp.Row, len(cp.Students))
+			resumed = cp
+			opts.StartRow = cp.Row
+		}
+	}
+	if checkpointN > 0 && !remote {
+		cp, err := newCheckpoint(filePath)
+		if err != nil {
+			return nil, Layout{}, nil, err
+		}
+		opts.ProgressEvery = checkpointN
+		opts.Progress = func(row int, students []Student, warnings []Issue) {
+			cp.Row = row
+			cp.Students = append(resumed.Students[:len(resumed.Students):len(resumed.Students)], students...)
+			cp.Warnings = append(resumed.Warnings[:len(resumed.Warnings):len(resumed.Warnings)], warnings...)
+			if err := cp.save(); err != nil {
+				fmt.Println("Error saving checkpoint:", err)
+			}
 		}
 	}
+
+	parsed := startTiming("parse")
+	students, layout, warnings, parseErr := parseRows(sheet, opts)
+	if layout.Components != nil && layout.Total < 0 {
+		set := layout.componentSet()
+		for i := range students {
+			students[i].Marks["Final Total"] = set.total(students[i])
+		}
+	}
+	students = append(resumed.Students, students...)
+	warnings = append(resumed.Warnings, warnings...)
+	source := filePath
+	if filePath == inputCopy {
+		source = inputName
+	}
+	setSource(students, source)
+	if rosterPath != "" {
+		roster, err := loadRoster(rosterPath)
+		if err != nil {
+			return nil, Layout{}, nil, err
+		}
+		issues := joinRoster(students, roster, cfg.Roster)
+		// Students after a failure are not missing, only unread.
+		if failure == nil && parseErr == nil {
+			issues = append(issues, missingStudents(students, roster)...)
+		}
+		warnings = append(warnings, applyWaivers(issues, waivers)...)
+	}
+	if !quietParse {
+		printIssues(warnings, layout)
+		printIgnoredRows(layout.Ignored)
+	}
+	if failure == nil && parseErr == nil && !remote {
+		removeCheckpoint(filePath)
+	}
+	if err := applyComputedColumns(students, layout.componentSet(), layout.Items, cfg.Columns); err != nil {
+		return nil, Layout{}, nil, err
+	}
+	parsed(len(students))
+
+	if failure != nil {
+		return students, layout, warnings, failure
+	}
+	return students, layout, warnings, parseErr
 }
 
-func calculateAverages(students []Student) {
-	avg := make(map[string]float64)
-	count := float64(len(students))
+// loadRows reads a whole sheet, for callers that need all of its rows at
+// once.
+func loadRows(ctx context.Context, filePath string) (Sheet, error) {
+	sheet, closeSheet, err := openRows(ctx, filePath)
+	var failure *PartialError
+	if err != nil && !errors.As(err, &failure) {
+		return Sheet{}, err
+	}
+	defer closeSheet()
+	if err == nil {
+		err = sheet.readAll()
+	}
+	if err := ctx.Err(); err != nil {
+		return Sheet{}, err
+	}
+	if err != nil && !errors.As(err, &failure) {
+		return Sheet{}, err
+	}
+	return sheet, err
+}
 
-	for _, student := range students {
-		for comp, mark := range student.Marks {
-			avg[comp] += mark
+// openRows opens the sheet in filePath for parsing. Workbooks are streamed
+// (see openWorkbookRows); other inputs are read whole. closeSheet must be
+// called once the rows have been read.
+func openRows(ctx context.Context, filePath string) (sheet Sheet, closeSheet func(), err error) {
+	closeSheet = func() {}
+	switch {
+	case isGoogleSheet(filePath):
+		sheet, err = loadGoogleSheet(ctx, filePath)
+	case isCSV(filePath):
+		sheet, err = loadCSVRows(ctx, filePath)
+	case isODS(filePath):
+		sheet, err = loadODSRows(ctx, filePath)
+	case isXLS(filePath):
+		sheet, err = loadXLSRows(ctx, filePath)
+	default:
+		sheet, closeSheet, err = openWorkbookRows(ctx, filePath)
+		if closeSheet == nil {
+			closeSheet = func() {}
 		}
 	}
+	return sheet, closeSheet, err
+}
 
-	fmt.Println("\nAverage Marks per Component:")
-	for comp, total := range avg {
-		fmt.Printf("%s: %.2f\n", comp, total/count)
+// selectSheet returns the worksheet named by -sheet, either by name or by
+// 1-based position, or the first sheet when -sheet is not set.
+func selectSheet(f *excelize.File) (string, error) {
+	return pickSheet(f.GetSheetList())
+}
+
+// pickSheet applies -sheet to a list of sheet names, for inputs that are not
+// opened with excelize.
+func pickSheet(sheets []string) (string, error) {
+	if sheetName == "" && len(sheets) > 0 {
+		return sheets[0], nil
 	}
+	for _, name := range sheets {
+		if name == sheetName {
+			return name, nil
+		}
+	}
+	if n, err := strconv.Atoi(sheetName); err == nil && n >= 1 && n <= len(sheets) {
+		return sheets[n-1], nil
+	}
+	available := make([]string, len(sheets))
+	for i, name := range sheets {
+		available[i] = fmt.Sprintf("%d. %s", i+1, name)
+	}
+	return "", fmt.Errorf("no sheet %q; available sheets: %s", sheetName, strings.Join(available, ", "))
 }
 
-func calculateBranchAverages(students []Student) {
-	branchTotals := make(map[string]float64)
-	branchCounts := make(map[string]int)
+func parseRows(sheet Sheet, opts ParseOptions) (students []Student, layout Layout, warnings []Issue, err error) {
+	current := 0
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PartialError{Stage: "parse", Row: current, Err: fmt.Errorf("%v", r)}
+		}
+	}()
 
-	for i := range students {
-		students[i].Total = students[i].Marks["Quiz"] + students[i].Marks["Mid-Sem"] +
-			students[i].Marks["Lab Test"] + students[i].Marks["Weekly Labs"] + students[i].Marks["Compre"]
+	// A partial read still leaves the rows before it to parse.
+	var failure *PartialError
+	if sheet.Next != nil && needsAllRows(sheet, opts) {
+		if err := sheet.readAll(); err != nil && !errors.As(err, &failure) {
+			return nil, Layout{}, nil, err
+		}
+	}
+	rows := sheet.Rows
+	ignored, unknown := ignoredColumns(rows[:min(maxHeaderRows, len(rows))], opts.Ignore)
+	for _, row := range rows {
+		blankColumns(row, ignored)
+	}
+	layout, ok := detectLayout(rows)
+	if opts.Mapping != nil {
+		layout, _ = opts.Mapping.layout()
+		layout.Headers = matchHeader(rows[:min(layout.HeaderRows, len(rows))]).names
+		for _, name := range layout.Components {
+			if _, known := layout.Max[name]; !known {
+				layout.Max[name], _ = numericColumn(rows[min(layout.HeaderRows, len(rows)):], layout.Columns[name])
+			}
+		}
+		ok = true
+	} else if opts.Discover {
+		layout, ok = discoverLayout(rows)
+		if !ok {
+			layout, _ = detectLayout(rows)
+		}
+	}
+	if !ok && opts.StartRow == 0 {
+		warnings = append(warnings, headerIssue(layout))
+	}
+	if opts.StartRow == 0 {
+		warnings = append(warnings, ignoreIssues(layout, ignored, unknown)...)
 	}
+	if opts.Strict && opts.StartRow == 0 {
+		if problems := checkSchema(rows, layout, ok, opts.Mapping != nil); len(problems) > 0 {
+			return nil, layout, warnings, &SchemaError{Problems: problems}
+		}
+	}
+	layout.Extra = extraColumns(layout, ignored)
+	width := layout.width()
+	branches := newBranchExtractor(opts.Branch, layout)
+	itemKeys := make([]string, len(layout.Items))
+	for j, item := range layout.Items {
+		itemKeys[j] = intern(item.Key())
+	}
+	letters := sourceColumns(layout)
+	var blanks []int
 
-	for _, student := range students {
-		branch := student.Branch
-		branchTotals[branch] += student.Total
-		branchCounts[branch]++
+	for i := 0; ; i++ {
+		var row []string
+		if i < len(rows) {
+			row = rows[i]
+		} else if sheet.Next == nil {
+			break
+		} else if row, err = sheet.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			if errors.As(err, &failure) {
+				break
+			}
+			return nil, layout, warnings, err
+		}
+		if i >= len(rows) {
+			blankColumns(row, ignored)
+		}
+
+		current = i + 1
+		if current%jobStepRows == 0 {
+			if err := jobStep(opts.Context, "parse", current); err != nil {
+				return nil, layout, warnings, err
+			}
+		}
+		if opts.Progress != nil && current%opts.ProgressEvery == 0 && current > opts.StartRow {
+			opts.Progress(i, students, warnings)
+		}
+		if i < layout.HeaderRows || i < opts.StartRow {
+			continue
+		}
+		// Blank rows count as separators once a row follows them; rows
+		// dropped as hidden were reported when read.
+		if isBlankRow(row) {
+			if row != nil || hiddenPolicy != "skip" {
+				blanks = append(blanks, i+1)
+			}
+			continue
+		}
+		for _, r := range blanks {
+			layout.Ignored = append(layout.Ignored, IgnoredRow{Row: r, Kind: "blank"})
+		}
+		blanks = blanks[:0]
+		if kind, text := structuralRow(row, layout, sheet.Merged[i+1]); kind != "" {
+			layout.Ignored = append(layout.Ignored, IgnoredRow{Row: i + 1, Kind: kind, Text: text})
+			warnings = append(warnings, rowWarning("skipped-row", i+1, "Skipping row %d: %s %q", i+1, kind, text))
+			continue
+		}
+
+		if len(row) < width {
+			warnings = append(warnings, rowError("truncated-row", i+1, "Skipping row %d due to truncated row (%d of %d columns)", i+1, len(row), width))
+			continue
+		}
+
+		empID := row[layout.EmpID]
+		campusID := row[layout.CampusID]
+
+		branch, note := branches.extract(row, campusID)
+		if branch == "" {
+			warnings = append(warnings, rowError("campus-id", i+1, "Skipping row %d due to invalid CampusID format (%s)", i+1, campusID))
+			continue
+		}
+		if note != "" {
+			warnings = append(warnings, rowWarning("branch", i+1, "Row %d: %s", i+1, note))
+		}
+
+		student := Student{
+			EmpID:    empID,
+			CampusID: campusID,
+			Branch:   intern(branch),
+			Row:      i + 1,
+			Sheet:    sheet.Name,
+			Cells:    sourceCells(letters, i+1),
+			Marks:    make(map[string]float64, len(layout.components())+1),
+			Extra:    extraCells(row, layout.Extra),
+		}
+		if layout.ClassNo >= 0 && layout.ClassNo < len(row) {
+			student.ClassNo = intern(row[layout.ClassNo])
+		}
+
+		for _, comp := range layout.components() {
+			cell := row[layout.Columns[comp]]
+			mark, err := parseMark(cell)
+			if err != nil {
+				warnings = append(warnings, rowWarning("invalid-mark", i+1, "Row %d: invalid %s mark %q treated as 0%s", i+1, comp, cell, markHint(cell)).in(comp))
+			}
+			student.Marks[comp] = mark
+		}
+
+		if layout.Total >= 0 {
+			finalTotal, err := parseMark(row[layout.Total])
+			if err != nil {
+				warnings = append(warnings, rowWarning("invalid-mark", i+1, "Row %d: invalid Final Total %q treated as 0%s", i+1, row[layout.Total], markHint(row[layout.Total])).in("Final Total"))
+			}
+			student.Marks["Final Total"] = finalTotal
+		}
+
+		for j, item := range layout.Items {
+			if item.Col >= len(row) {
+				continue
+			}
+			score, err := parseMark(row[item.Col])
+			if err != nil {
+				warnings = append(warnings, rowWarning("invalid-mark", i+1, "Row %d: invalid %s score %q treated as 0%s", i+1, itemKeys[j], row[item.Col], markHint(row[item.Col])).in(itemKeys[j]))
+			}
+			if student.Items == nil {
+				student.Items = make(map[string]float64, len(itemKeys))
+			}
+			student.Items[itemKeys[j]] = score
+		}
+
+		if len(sheet.Comments) > 0 {
+			student.Comments = lookupCells(sheet.Comments, layout, i+1)
+		}
+		if len(sheet.Annotations) > 0 {
+			student.Annotations = lookupCells(sheet.Annotations, layout, i+1)
+		}
+
+		students = append(students, student)
 	}
 
-	fmt.Println("\nBranch-wise Averages:")
-	for branch, total := range branchTotals {
-		avg := total / float64(branchCounts[branch])
-		fmt.Printf("Branch %s: %.2f\n", branch, avg)
+	if failure != nil {
+		return students, layout, warnings, failure
 	}
+	return students, layout, warnings, nil
 }
 
-func rankStudents(students []Student) {
-	fmt.Println("\nOverall Top 3 Students:")
+// needsAllRows tells whether the layout of a streamed sheet depends on more
+// than its first rows: when it is discovered from the marks, or mapped
+// without the maximum marks, or every cell is checked first with -strict.
+func needsAllRows(sheet Sheet, opts ParseOptions) bool {
+	if opts.Discover || opts.Strict {
+		return true
+	}
+	if opts.Mapping == nil {
+		return false
+	}
+	layout, _ := opts.Mapping.layout()
+	if layout.HeaderRows >= len(sheet.Rows) {
+		return true
+	}
+	for _, name := range layout.Components {
+		if _, known := layout.Max[name]; !known {
+			return true
+		}
+	}
+	return false
+}
 
-	for i := range students {
-		students[i].Total = students[i].Marks["Quiz"] + students[i].Marks["Mid-Sem"] +
-			students[i].Marks["Lab Test"] + students[i].Marks["Weekly Labs"] + students[i].Marks["Compre"]
+// parseMark reads a mark cell; empty is 0. With -decimal-comma, a mark with
+// a comma has it as the decimal separator, and any points as thousands
+// separators ("1.234,5"); one without is read as usual, as Excel gives
+// numeric cells.
+func parseMark(cell string) (float64, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return 0, nil
+	}
+	number := cell
+	if decimalComma && strings.Count(number, ",") == 1 {
+		number = strings.Replace(strings.ReplaceAll(number, ".", ""), ",", ".", 1)
+	}
+	mark, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(mark) || math.IsInf(mark, 0) {
+		return 0, fmt.Errorf("non-finite mark %q", cell)
 	}
+	return mark, nil
+}
 
-	sort.Slice(students, func(i, j int) bool {
-		return students[i].Total > students[j].Total
-	})
+var decimalCommaMark = regexp.MustCompile(`^[-+]?[0-9]+,[0-9]+$`)
+
+// markHint suggests -decimal-comma for an invalid mark that looks like one
+// written with a decimal comma.
+func markHint(cell string) string {
+	if !decimalComma && decimalCommaMark.MatchString(strings.TrimSpace(cell)) {
+		return " (rerun with -decimal-comma if commas are decimal separators)"
+	}
+	return ""
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func collectMismatches(students []Student, set ComponentSet) []Issue {
+	var wg sync.WaitGroup
+	mismatchCh := make(chan Issue, len(students))
 
-	for i := 0; i < 3 && i < len(students); i++ {
-		fmt.Printf("%d. EmpID: %s | Computed Total: %.2f\n", i+1, students[i].EmpID, students[i].Total)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		validateData(students, set, mismatchCh)
+	}()
+	go func() {
+		wg.Wait()
+		close(mismatchCh)
+	}()
+
+	var mismatches []Issue
+	for issue := range mismatchCh {
+		mismatches = append(mismatches, issue)
 	}
 
-	branchStudents := make(map[string][]Student)
+	return mismatches
+}
+
+func validateData(students []Student, set ComponentSet, mismatchCh chan<- Issue) {
+	v := newValidator(set)
 	for _, student := range students {
-		branchStudents[student.Branch] = append(branchStudents[student.Branch], student)
+		v.check(student, mismatchCh)
 	}
+}
 
-	fmt.Println("\nTop 3 Students per Branch:")
-	for branch, studentsInBranch := range branchStudents {
-		sort.Slice(studentsInBranch, func(i, j int) bool {
-			return studentsInBranch[i].Total > studentsInBranch[j].Total
-		})
+// validator checks students one at a time against the components of their
+// sheet, remembering the rows of the EmpIDs it has seen.
+type validator struct {
+	set      ComponentSet
+	firstRow map[string]int
+}
+
+func newValidator(set ComponentSet) *validator {
+	return &validator{set: set, firstRow: make(map[string]int)}
+}
 
-		fmt.Printf("\nBranch %s:\n", branch)
-		for i := 0; i < 3 && i < len(studentsInBranch); i++ {
-			fmt.Printf("%d. EmpID: %s | Computed Total: %.2f\n", i+1, studentsInBranch[i].EmpID, studentsInBranch[i].Total)
+func (v *validator) check(student Student, mismatchCh chan<- Issue) {
+	if row, seen := v.firstRow[student.EmpID]; seen {
+		mismatchCh <- studentError("duplicate-empid", student, "Duplicate EmpID %s (rows %d and %d)", student.EmpID, row, student.Row)
+	} else {
+		v.firstRow[student.EmpID] = student.Row
+	}
+
+	for _, comp := range v.set.names() {
+		mark := student.Marks[comp]
+		if mark < 0 || mark > v.set.max(comp) {
+			mismatchCh <- studentError("out-of-range", student, "Out of range %s mark for EmpID %s (%s, allowed 0-%s)", comp, student.EmpID, formatNumber(mark), formatNumber(v.set.max(comp))).in(comp)
 		}
 	}
+
+	// Discovered layouts may lack the standard columns the sums are over.
+	_, hasPreCompre := student.Marks["Pre-Compre"]
+	expectedI := student.Marks["Quiz"] + student.Marks["Mid-Sem"] + student.Marks["Lab Test"] + student.Marks["Weekly Labs"]
+	if hasPreCompre && expectedI != student.Marks["Pre-Compre"] {
+		mismatchCh <- studentError("pre-compre-sum", student, "Mismatch in E+F+G+H != I for EmpID %s", student.EmpID)
+	}
+
+	_, hasCompre := student.Marks["Compre"]
+	expectedTotal := student.Marks["Pre-Compre"] + student.Marks["Compre"]
+	actualTotal, exists := student.Marks["Final Total"]
+
+	if exists && hasPreCompre && hasCompre && expectedTotal != actualTotal {
+		mismatchCh <- studentError("total-sum", student, "Mismatch in I+J != K for EmpID %s (Expected: %s, Found: %s)", student.EmpID, formatNumber(expectedTotal), formatNumber(actualTotal))
+	}
+
+	if msg := checkIDConsistency(student); msg != "" {
+		mismatchCh <- studentError("id-consistency", student, "%s", msg)
+	}
 }
 
-func exportToJSON(students []Student, mismatches []string) {
-	data := map[string]interface{}{
-		"students":   students,
-		"mismatches": mismatches,
+var (
+	empIDPattern    = regexp.MustCompile(`^\d{3}(\d{4})(\d{4})$`)
+	campusIDPattern = regexp.MustCompile(`^(\d{4})[A-Z0-9]{4}(\d{4})[A-Z]$`)
+)
+
+// checkIDConsistency compares the year and serial encoded in both IDs. Rows
+// where either ID is in an unrecognised format are left alone.
+func checkIDConsistency(s Student) string {
+	emp := empIDPattern.FindStringSubmatch(s.EmpID)
+	campus := campusIDPattern.FindStringSubmatch(strings.ToUpper(s.CampusID))
+	if emp == nil || campus == nil {
+		return ""
+	}
+	if emp[1] != campus[1] {
+		return fmt.Sprintf("Mismatch in EmpID/CampusID year for EmpID %s (EmpID: %s, CampusID: %s)", s.EmpID, emp[1], campus[1])
 	}
+	if emp[2] != campus[2] {
+		return fmt.Sprintf("Mismatch in EmpID/CampusID serial for EmpID %s (EmpID: %s, CampusID: %s)", s.EmpID, emp[2], campus[2])
+	}
+	return ""
+}
 
-	file, err := os.Create("output.json")
-	if err != nil {
-		fmt.Println("Error creating JSON file:", err)
-		return
+// rankStudents sorts the students, given their computed totals in sheet
+// order, for the rankings and exports.
+func rankStudents(students []Student, set ComponentSet, totals []float64) {
+	for i := range students {
+		students[i].Total = totals[i]
+	}
+	if err := sortStudents(students, set, sortKeys); err != nil {
+		fmt.Println("Error:", err)
 	}
-	defer file.Close()
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	err = encoder.Encode(data)
-	if err != nil {
-		fmt.Println("Error writing JSON data:", err)
+// jsonRenderer writes the report as the JSON export, which can be read back
+// in with -from-json.
+type jsonRenderer struct {
+	path string
+}
+
+func (j jsonRenderer) Render(rep *Report) error {
+	data := map[string]interface{}{
+		"schema_version": runSchemaVersion,
+		"course":         rep.Course,
+		"students":       rep.Students,
+		"mismatches":     rep.Issues,
+		"manifest":       rep.Manifest,
+	}
+	if rep.Branch != "" {
+		data["branch"] = rep.Branch
+	}
+	if len(rep.Items) > 0 {
+		data["items"] = rep.Items
+	}
+	if list := rep.Set.exported(); list != nil {
+		data["components"] = list
+	}
+	if len(rep.Queries) > 0 {
+		data["queries"] = rep.Queries
+	}
+	if len(rep.Alerts) > 0 {
+		data["needs_attention"] = true
+		data["alerts"] = rep.Alerts
+	}
+	if rep.Failure != nil {
+		data["partial"] = true
+		data["failure"] = rep.Failure.Error()
 	}
 
-	fmt.Println("Data exported to output.json")
+	if err := writeExport(j.path, data); err != nil {
+		return fmt.Errorf("writing JSON data: %w", err)
+	}
+	fmt.Println("Data exported to", j.path)
+	return nil
 }
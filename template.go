@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	templatePath string
+	templateOut  string
+
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z]+)(?:\.([^}]*?))?\s*\}\}`)
+)
+
+// A template is an ordinary workbook, laid out and branded as the institute
+// requires, with placeholders in its cells and page headers and footers:
+//
+//	{{course}} {{date}} {{students}} {{errors}} {{warnings}}
+//	{{mean.Quiz}} {{median.Total}} {{min.Compre}} {{max.Total}}
+//	{{student.EmpID}} {{student.Name}} {{student.Quiz}} {{student.Grade}} ...
+//	{{branch.Name}} {{branch.Students}} {{branch.Total}} {{branch.Quiz}} ...
+//	{{component.Name}} {{component.Mean}} {{component.Median}} {{component.Max}} ...
+//	{{top.EmpID}} {{top.Total}} ... (the top 3 students)
+//	{{issue.Message}} {{issue.Severity}} {{issue.Rule}} {{issue.EmpID}} {{issue.Row}}
+//
+// A row with student placeholders is repeated for every student, in -sort
+// order, and likewise for branches, components, the top 3 and unwaived
+// issues; the copies keep the row's formatting. Everything else in the
+// template (logos, fonts, merged titles, print settings) is left as it is.
+// The numbers are the Report's, so a template agrees with the console and
+// the other exports.
+type templateData struct {
+	cfg    Config
+	rep    *Report
+	active []Issue
+	// branches go in shared reports, so small branches are pooled (see
+	// pooledBranchStats), and pools give each student's branch the same way.
+	branches []BranchStats
+	pools    map[string]string
+	now      time.Time
+}
+
+var repeatedPlaceholders = map[string]bool{"student": true, "branch": true, "component": true, "top": true, "issue": true}
+
+func newTemplateData(cfg Config, rep *Report) templateData {
+	branches, _ := pooledBranchStats(rep.Branches)
+	pools, _ := branchPools(rep.Branches)
+	return templateData{cfg: cfg, rep: rep, active: unwaived(rep.Issues), branches: branches, pools: pools, now: time.Now()}
+}
+
+// count is how many times a row repeating over kind is written.
+func (d templateData) count(kind string) int {
+	switch kind {
+	case "student":
+		return len(d.rep.Students)
+	case "branch":
+		return len(d.branches)
+	case "component":
+		return len(d.rep.Components)
+	case "top":
+		return min(3, len(d.rep.Students))
+	case "issue":
+		return len(d.active)
+	}
+	return 1
+}
+
+func fillTemplate(cfg Config, rep *Report, path, out string) error {
+	if filepath.Clean(path) == filepath.Clean(out) {
+		return fmt.Errorf("-template-out would overwrite the template %s", path)
+	}
+	f, err := openWorkbook(path)
+	if err != nil {
+		return err
+	}
+	defer closeWorkbook(f)
+
+	d := newTemplateData(cfg, rep)
+	for _, sheet := range f.GetSheetList() {
+		if err := d.fillSheet(f, sheet); err != nil {
+			return fmt.Errorf("template sheet %s: %w", sheet, err)
+		}
+	}
+	return f.SaveAs(out)
+}
+
+func (d templateData) fillSheet(f *excelize.File, sheet string) error {
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+
+	// Bottom up, so that repeating a row only moves rows already filled.
+	for r := len(rows) - 1; r >= 0; r-- {
+		kind, err := rowKind(rows[r])
+		if err != nil {
+			return fmt.Errorf("row %d: %w", r+1, err)
+		}
+		n := d.count(kind)
+		if n == 0 {
+			if err := f.RemoveRow(sheet, r+1); err != nil {
+				return err
+			}
+			continue
+		}
+		for k := 1; k < n; k++ {
+			if err := f.DuplicateRow(sheet, r+1); err != nil {
+				return err
+			}
+		}
+		for k := 0; k < n; k++ {
+			for c, text := range rows[r] {
+				if !strings.Contains(text, "{{") {
+					continue
+				}
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+1+k)
+				v, err := d.fill(text, k)
+				if err != nil {
+					return fmt.Errorf("cell %s: %w", cell, err)
+				}
+				if err := f.SetCellValue(sheet, cell, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	hf, err := f.GetHeaderFooter(sheet)
+	if err != nil || hf == nil {
+		return err
+	}
+	changed := false
+	for _, field := range []*string{&hf.OddHeader, &hf.OddFooter, &hf.EvenHeader, &hf.EvenFooter, &hf.FirstHeader, &hf.FirstFooter} {
+		if !strings.Contains(*field, "{{") {
+			continue
+		}
+		// "&" starts a formatting code in headers and footers.
+		text, err := d.fillText(*field, -1, func(s string) string { return strings.ReplaceAll(s, "&", "&&") })
+		if err != nil {
+			return fmt.Errorf("header or footer: %w", err)
+		}
+		*field = text
+		changed = true
+	}
+	if changed {
+		return f.SetHeaderFooter(sheet, hf)
+	}
+	return nil
+}
+
+// rowKind tells what a template row repeats over, if anything.
+func rowKind(row []string) (string, error) {
+	kind := ""
+	for _, text := range row {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			ns := strings.ToLower(m[1])
+			if !repeatedPlaceholders[ns] {
+				continue
+			}
+			if kind != "" && kind != ns {
+				return "", fmt.Errorf("a row cannot repeat per %s and per %s at once", kind, ns)
+			}
+			kind = ns
+		}
+	}
+	return kind, nil
+}
+
+// fill replaces the placeholders in text, using the k-th student or branch
+// for a repeated row. A cell holding just one numeric placeholder becomes a
+// number, so the template's number format applies.
+func (d templateData) fill(text string, k int) (interface{}, error) {
+	if m := placeholderPattern.FindStringSubmatch(strings.TrimSpace(text)); m != nil && m[0] == strings.TrimSpace(text) {
+		return d.value(m[1], m[2], k)
+	}
+	return d.fillText(text, k, func(s string) string { return s })
+}
+
+func (d templateData) fillText(text string, k int, escape func(string) string) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(text, -1) {
+		key := ""
+		if m[4] >= 0 {
+			key = text[m[4]:m[5]]
+		}
+		v, err := d.value(text[m[2]:m[3]], key, k)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text[last:m[0]])
+		if n, ok := v.(float64); ok {
+			b.WriteString(escape(formatNumber(n)))
+		} else {
+			b.WriteString(escape(fmt.Sprint(v)))
+		}
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String(), nil
+}
+
+func (d templateData) value(ns, key string, k int) (interface{}, error) {
+	name := "{{" + ns
+	if key != "" {
+		name += "." + key
+	}
+	name += "}}"
+
+	switch strings.ToLower(ns) {
+	case "course":
+		return courseName, nil
+	case "date":
+		return d.now.Format("2006-01-02"), nil
+	case "students":
+		return len(d.rep.Students), nil
+	case "errors":
+		return countIssues(d.rep.Issues, severityError), nil
+	case "warnings":
+		return countIssues(d.rep.Issues, severityWarning), nil
+	case "mean", "median", "min", "max":
+		stats, ok := d.rep.stats(key)
+		if !ok && len(d.rep.Students) > 0 {
+			return nil, fmt.Errorf("unknown component in %s", name)
+		}
+		return stats.get(strings.ToLower(ns)), nil
+	case "student", "top":
+		if k >= 0 && k < len(d.rep.Students) {
+			if v, ok := d.studentField(d.rep.Students[k], k, key); ok {
+				return v, nil
+			}
+		}
+	case "component":
+		if k >= 0 && k < len(d.rep.Components) {
+			stats := d.rep.Components[k]
+			switch fn := normalizeHeader(key); fn {
+			case "name":
+				return stats.Name, nil
+			case "outof":
+				return d.rep.Set.max(stats.Name), nil
+			case "mean", "median", "min", "max":
+				return stats.get(fn), nil
+			}
+		}
+	case "issue":
+		if k >= 0 && k < len(d.active) {
+			issue := d.active[k]
+			switch normalizeHeader(key) {
+			case "message":
+				return issue.Message, nil
+			case "severity":
+				return issue.Severity, nil
+			case "rule":
+				return issue.Rule, nil
+			case "empid":
+				return issue.EmpID, nil
+			case "row":
+				return issue.Row, nil
+			}
+		}
+	case "branch":
+		if k >= 0 && k < len(d.branches) {
+			b := d.branches[k]
+			switch normalizeHeader(key) {
+			case "name", "branch":
+				return b.Branch, nil
+			case "students":
+				return b.Students, nil
+			case "total":
+				return b.AverageTotal, nil
+			}
+			for comp, v := range b.Means {
+				if normalizeHeader(comp) == normalizeHeader(key) {
+					return v, nil
+				}
+			}
+		}
+	}
+	if repeatedPlaceholders[strings.ToLower(ns)] && k < 0 {
+		return nil, fmt.Errorf("%s can only be used in a table row", name)
+	}
+	return nil, fmt.Errorf("unknown placeholder %s", name)
+}
+
+func (d templateData) studentField(s Student, k int, key string) (interface{}, bool) {
+	course := d.cfg.course(courseName)
+	pct := d.rep.Set.total(s) / course.MaxTotal * 100
+	switch normalizeHeader(key) {
+	case "empid":
+		return s.EmpID, true
+	case "campusid":
+		return s.CampusID, true
+	case "classno", "section":
+		return s.ClassNo, true
+	case "name":
+		return s.Name, true
+	case "email":
+		return s.Email, true
+	case "advisor":
+		return s.Advisor, true
+	case "branch":
+		return sharedBranch(d.pools, s.Branch), true
+	case "row":
+		return s.Row, true
+	case "source":
+		return s.Source, true
+	case "sheet":
+		return s.Sheet, true
+	case "rank":
+		return d.rep.Ranks[k], true
+	case "total":
+		return d.rep.Set.total(s), true
+	case "percent":
+		return pct, true
+	case "grade":
+		return d.cfg.grade(pct).Grade, true
+	case "points":
+		return d.cfg.grade(pct).Points, true
+	}
+	vars := studentVars(s, d.rep.Set)
+	v, ok := vars[normalizeHeader(key)]
+	return v, ok
+}
+
+// get is the statistic a template names: mean, median, min or max.
+func (m MarkStats) get(fn string) float64 {
+	switch fn {
+	case "median":
+		return m.Median
+	case "min":
+		return m.Min
+	case "max":
+		return m.Max
+	}
+	return m.Mean
+}
@@ -0,0 +1,232 @@
+package main
+
+import "fmt"
+
+// Tenant is a department sharing the deployment. Users belong to at most
+// one tenant; quotas of zero mean unlimited. Max* quotas reject new runs,
+// Warn* quotas only warn.
+type Tenant struct {
+	ID            string `json:"id"`
+	MaxRuns       int    `json:"max_runs"`
+	MaxStudents   int    `json:"max_students"`
+	MaxRows       int    `json:"max_rows,omitempty"`
+	WarnRows      int    `json:"warn_rows,omitempty"`
+	MaxStorageMB  int    `json:"max_storage_mb,omitempty"`
+	WarnStorageMB int    `json:"warn_storage_mb,omitempty"`
+}
+
+func (c Config) tenant(id string) Tenant {
+	for _, t := range c.Tenants {
+		if t.ID == id {
+			return t
+		}
+	}
+	return Tenant{ID: id}
+}
+
+// tenantStorage confines a Storage to one tenant. Runs owned by other
+// tenants behave exactly as if they did not exist.
+type tenantStorage struct {
+	Storage
+	tenant string
+}
+
+func scopeStorage(store Storage, tenant string) Storage {
+	return tenantStorage{Storage: store, tenant: tenant}
+}
+
+func (t tenantStorage) owns(id string) error {
+	run, err := t.Storage.GetRun(id)
+	if err != nil {
+		return err
+	}
+	if run.Tenant != t.tenant {
+		return errNotFound
+	}
+	return nil
+}
+
+func (t tenantStorage) SaveRun(run Run) error {
+	if _, err := t.Storage.GetRun(run.ID); err == nil {
+		if err := t.owns(run.ID); err != nil {
+			return err
+		}
+	}
+	run.Tenant = t.tenant
+	return t.Storage.SaveRun(run)
+}
+
+func (t tenantStorage) GetRun(id string) (Run, error) {
+	if err := t.owns(id); err != nil {
+		return Run{}, err
+	}
+	return t.Storage.GetRun(id)
+}
+
+func (t tenantStorage) ListRuns() ([]Run, error) {
+	runs, err := t.Storage.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+	var own []Run
+	for _, run := range runs {
+		if run.Tenant == t.tenant {
+			own = append(own, run)
+		}
+	}
+	return own, nil
+}
+
+func (t tenantStorage) TransitionRun(id, from, to string) error {
+	if err := t.owns(id); err != nil {
+		return err
+	}
+	return t.Storage.TransitionRun(id, from, to)
+}
+
+func (t tenantStorage) UpdateApproval(id string, approval *Approval) error {
+	if err := t.owns(id); err != nil {
+		return err
+	}
+	return t.Storage.UpdateApproval(id, approval)
+}
+
+func (t tenantStorage) ListStudents(runID string) ([]Student, error) {
+	if err := t.owns(runID); err != nil {
+		return nil, err
+	}
+	return t.Storage.ListStudents(runID)
+}
+
+func (t tenantStorage) AppendAudit(entry AuditEntry) error {
+	if err := t.owns(entry.RunID); err != nil {
+		return err
+	}
+	return t.Storage.AppendAudit(entry)
+}
+
+func (t tenantStorage) ListAudit(runID string) ([]AuditEntry, error) {
+	if runID != "" {
+		if err := t.owns(runID); err != nil {
+			return nil, err
+		}
+		return t.Storage.ListAudit(runID)
+	}
+
+	runs, err := t.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+	own := make(map[string]bool, len(runs))
+	for _, run := range runs {
+		own[run.ID] = true
+	}
+	entries, err := t.Storage.ListAudit("")
+	if err != nil {
+		return nil, err
+	}
+	var filtered []AuditEntry
+	for _, e := range entries {
+		if own[e.RunID] {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+func (t tenantStorage) SaveStats(snap StatsSnapshot) error {
+	if err := t.owns(snap.RunID); err != nil {
+		return err
+	}
+	snap.Tenant = t.tenant
+	return t.Storage.SaveStats(snap)
+}
+
+// ListStats cannot check run ownership, since snapshots outlive their runs,
+// so it relies on the tenant recorded in each snapshot instead.
+func (t tenantStorage) ListStats(course string) ([]StatsSnapshot, error) {
+	snaps, err := t.Storage.ListStats(course)
+	if err != nil {
+		return nil, err
+	}
+	var own []StatsSnapshot
+	for _, s := range snaps {
+		if s.Tenant == t.tenant {
+			own = append(own, s)
+		}
+	}
+	return own, nil
+}
+
+func (t tenantStorage) SaveDispute(d Dispute) error {
+	if err := t.owns(d.RunID); err != nil {
+		return err
+	}
+	d.Tenant = t.tenant
+	return t.Storage.SaveDispute(d)
+}
+
+func (t tenantStorage) GetDispute(id string) (Dispute, error) {
+	d, err := t.Storage.GetDispute(id)
+	if err != nil {
+		return Dispute{}, err
+	}
+	if d.Tenant != t.tenant {
+		return Dispute{}, errNotFound
+	}
+	return d, nil
+}
+
+func (t tenantStorage) ListDisputes(runID string) ([]Dispute, error) {
+	disputes, err := t.Storage.ListDisputes(runID)
+	if err != nil {
+		return nil, err
+	}
+	var own []Dispute
+	for _, d := range disputes {
+		if d.Tenant == t.tenant {
+			own = append(own, d)
+		}
+	}
+	return own, nil
+}
+
+func (t tenantStorage) SaveNote(n Note) error {
+	if err := t.owns(n.RunID); err != nil {
+		return err
+	}
+	n.Tenant = t.tenant
+	return t.Storage.SaveNote(n)
+}
+
+func (t tenantStorage) ListNotes(runID string) ([]Note, error) {
+	if err := t.owns(runID); err != nil {
+		return nil, err
+	}
+	return t.Storage.ListNotes(runID)
+}
+
+func (t tenantStorage) DeleteRun(id string) error {
+	if err := t.owns(id); err != nil {
+		return err
+	}
+	return t.Storage.DeleteRun(id)
+}
+
+// checkQuota reports whether the tenant may store run, and any soft quota
+// warning to show when it does.
+func checkQuota(store Storage, tenant Tenant, run Run) (string, error) {
+	if n := len(run.Students); tenant.MaxStudents > 0 && n > tenant.MaxStudents {
+		return "", fmt.Errorf("run has %d students, tenant %q allows at most %d", n, tenant.ID, tenant.MaxStudents)
+	}
+	if tenant.MaxRuns > 0 {
+		runs, err := store.ListRuns()
+		if err != nil {
+			return "", err
+		}
+		if len(runs) >= tenant.MaxRuns {
+			return "", fmt.Errorf("tenant %q already has %d runs (quota %d)", tenant.ID, len(runs), tenant.MaxRuns)
+		}
+	}
+	return checkUsageQuota(store, tenant, run)
+}
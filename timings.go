@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var showTimings bool
+
+// stageTiming is the time spent in one stage of the pipeline, and the rows
+// it handled.
+type stageTiming struct {
+	stage   string
+	elapsed time.Duration
+	rows    int
+}
+
+// timings collects the stages timed with -timings. In a batch the files'
+// stages add up, so a stage run by several workers at once can take longer
+// in total than the run did.
+var timings struct {
+	sync.Mutex
+	start  time.Time
+	stages []stageTiming
+}
+
+func init() {
+	timings.start = time.Now()
+}
+
+// startTiming starts timing a stage. The function it returns stops the
+// clock, given the rows the stage handled; it does nothing without
+// -timings.
+func startTiming(stage string) func(rows int) {
+	if !showTimings {
+		return func(int) {}
+	}
+	start := time.Now()
+	return func(rows int) {
+		elapsed := time.Since(start)
+		timings.Lock()
+		defer timings.Unlock()
+		for i := range timings.stages {
+			if timings.stages[i].stage == stage {
+				timings.stages[i].elapsed += elapsed
+				timings.stages[i].rows += rows
+				return
+			}
+		}
+		timings.stages = append(timings.stages, stageTiming{stage, elapsed, rows})
+	}
+}
+
+// printTimings reports each stage's wall time and rows per second, in the
+// order the stages first ran, and the run's time so far.
+func printTimings() {
+	if !showTimings {
+		return
+	}
+	timings.Lock()
+	defer timings.Unlock()
+	fmt.Println("\nTimings:")
+	fmt.Printf("%-10s %12s %10s %14s\n", "Stage", "Time", "Rows", "Rows/sec")
+	var sum time.Duration
+	for _, t := range timings.stages {
+		rate := "-"
+		if t.rows > 0 && t.elapsed > 0 {
+			rate = fmt.Sprintf("%.0f", float64(t.rows)/t.elapsed.Seconds())
+		}
+		fmt.Printf("%-10s %12s %10d %14s\n", t.stage, t.elapsed.Round(time.Microsecond), t.rows, rate)
+		sum += t.elapsed
+	}
+	total := time.Since(timings.start)
+	fmt.Printf("%-10s %12s\n", "other", max(total-sum, 0).Round(time.Microsecond))
+	fmt.Printf("%-10s %12s\n", "total", total.Round(time.Microsecond))
+}
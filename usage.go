@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Usage is what a tenant, or one of its users (API keys), currently has in
+// storage. Bytes is the size of the runs as JSON, which approximates what
+// every backend stores.
+type Usage struct {
+	Tenant string `json:"tenant"`
+	User   string `json:"user,omitempty"`
+	Runs   int    `json:"runs"`
+	Rows   int    `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+}
+
+func (u *Usage) add(o Usage) {
+	u.Runs += o.Runs
+	u.Rows += o.Rows
+	u.Bytes += o.Bytes
+}
+
+func runUsage(run Run) Usage {
+	data, _ := json.Marshal(run)
+	return Usage{Tenant: run.Tenant, Runs: 1, Rows: len(run.Students), Bytes: int64(len(data))}
+}
+
+// collectUsage measures every run in store, grouped by tenant and by the
+// user who created it, according to the audit log.
+func collectUsage(store Storage) ([]Usage, error) {
+	entries, err := store.ListAudit("")
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string)
+	for _, e := range entries {
+		if e.Action == "run.created" {
+			owners[e.RunID] = e.Actor
+		}
+	}
+
+	runs, err := store.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ tenant, user string }
+	groups := make(map[key]*Usage)
+	for _, summary := range runs {
+		run, err := store.GetRun(summary.ID)
+		if err != nil {
+			return nil, err
+		}
+		k := key{run.Tenant, owners[run.ID]}
+		if groups[k] == nil {
+			groups[k] = &Usage{Tenant: k.tenant, User: k.user}
+		}
+		groups[k].add(runUsage(run))
+	}
+
+	out := make([]Usage, 0, len(groups))
+	for _, u := range groups {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tenant != out[j].Tenant {
+			return out[i].Tenant < out[j].Tenant
+		}
+		return out[i].User < out[j].User
+	})
+	return out, nil
+}
+
+func tenantTotal(usage []Usage, tenant string) Usage {
+	total := Usage{Tenant: tenant}
+	for _, u := range usage {
+		if u.Tenant == tenant {
+			total.add(u)
+		}
+	}
+	return total
+}
+
+// checkUsageQuota reports whether adding run keeps the tenant within its
+// hard row and storage quotas. Crossing a soft quota is allowed but returns
+// a warning for the caller to pass on.
+func checkUsageQuota(store Storage, tenant Tenant, run Run) (string, error) {
+	if tenant.MaxRows == 0 && tenant.WarnRows == 0 && tenant.MaxStorageMB == 0 && tenant.WarnStorageMB == 0 {
+		return "", nil
+	}
+	usage, err := collectUsage(store)
+	if err != nil {
+		return "", err
+	}
+	current := tenantTotal(usage, tenant.ID)
+	after := current
+	after.add(runUsage(run))
+
+	if tenant.MaxRows > 0 && after.Rows > tenant.MaxRows {
+		return "", fmt.Errorf("tenant %q has %d of %d rows stored; this run adds %d, which exceeds the quota",
+			tenant.ID, current.Rows, tenant.MaxRows, len(run.Students))
+	}
+	if limit := int64(tenant.MaxStorageMB) << 20; limit > 0 && after.Bytes > limit {
+		return "", fmt.Errorf("tenant %q uses %s of %d MB storage; this run adds %s, which exceeds the quota",
+			tenant.ID, formatBytes(current.Bytes), tenant.MaxStorageMB, formatBytes(after.Bytes-current.Bytes))
+	}
+
+	var warning string
+	if tenant.WarnRows > 0 && after.Rows > tenant.WarnRows {
+		warning = fmt.Sprintf("tenant %q now has %d rows stored, over its soft quota of %d", tenant.ID, after.Rows, tenant.WarnRows)
+	} else if limit := int64(tenant.WarnStorageMB) << 20; limit > 0 && after.Bytes > limit {
+		warning = fmt.Sprintf("tenant %q now uses %s storage, over its soft quota of %d MB", tenant.ID, formatBytes(after.Bytes), tenant.WarnStorageMB)
+	}
+	return warning, nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+type UsageReport struct {
+	Usage
+	Quota Tenant  `json:"quota"`
+	Users []Usage `json:"users"`
+}
+
+func (s *server) usage(w http.ResponseWriter, r *http.Request) {
+	user, store, ok := s.scope(w, r)
+	if !ok {
+		return
+	}
+	usage, err := collectUsage(store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, UsageReport{Usage: tenantTotal(usage, user.Tenant), Quota: s.cfg.tenant(user.Tenant), Users: usage})
+}
+
+func adminUsage(tenant string) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	usage, err := collectUsage(store)
+	if err != nil {
+		return err
+	}
+	var tenants []string
+	for _, u := range usage {
+		if (tenant == "" || u.Tenant == tenant) && (len(tenants) == 0 || tenants[len(tenants)-1] != u.Tenant) {
+			tenants = append(tenants, u.Tenant)
+		}
+	}
+	if len(tenants) == 0 {
+		fmt.Println("No runs stored.")
+		return nil
+	}
+
+	for _, id := range tenants {
+		t := cfg.tenant(id)
+		total := tenantTotal(usage, id)
+		name := id
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("%s | %d runs | rows: %d%s | storage: %s%s\n", name, total.Runs,
+			total.Rows, limitText(t.WarnRows, t.MaxRows, ""), formatBytes(total.Bytes), limitText(t.WarnStorageMB, t.MaxStorageMB, " MB"))
+		for _, u := range usage {
+			if u.Tenant != id {
+				continue
+			}
+			user := u.User
+			if user == "" {
+				user = "(unknown)"
+			}
+			fmt.Printf("  %-16s %3d runs %8d rows %10s\n", user, u.Runs, u.Rows, formatBytes(u.Bytes))
+		}
+	}
+	return nil
+}
+
+func limitText(soft, hard int, unit string) string {
+	var s string
+	if soft > 0 {
+		s += fmt.Sprintf(" (soft %d%s)", soft, unit)
+	}
+	if hard > 0 {
+		s += fmt.Sprintf(" (max %d%s)", hard, unit)
+	}
+	return s
+}
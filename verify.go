@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CardVerification is what the verification link on a printed report card
+// shows: the marks and grade on record, to compare with the card.
+type CardVerification struct {
+	Authentic bool               `json:"authentic"`
+	RunID     string             `json:"run_id"`
+	Course    string             `json:"course"`
+	EmpID     string             `json:"emp_id"`
+	Name      string             `json:"name,omitempty"`
+	Marks     map[string]float64 `json:"marks"`
+	Total     float64            `json:"total"`
+	Grade     string             `json:"grade"`
+}
+
+// cardVerifyLink returns the signed path that confirms empID's report card
+// from run. Unlike shared links it does not expire, since cards are kept on
+// paper; it stays valid until its signing key is retired.
+func cardVerifyLink(keys []SigningKey, runID, empID string) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no signing keys configured (run admin rotate-keys)")
+	}
+	path := "/verify/runs/" + url.PathEscape(runID) + "/" + url.PathEscape(empID)
+	q := url.Values{}
+	q.Set("kid", keys[0].ID)
+	q.Set("sig", signLink(keys[0], path, 0))
+	return path + "?" + q.Encode(), nil
+}
+
+// verificationFooter is the verification link and its QR code printed at
+// the end of a report card, or nothing without a base URL and signing keys.
+func verificationFooter(cfg Config, run Run, s Student) string {
+	if cfg.BaseURL == "" || len(cfg.Keys) == 0 {
+		return ""
+	}
+	link, err := cardVerifyLink(cfg.Keys, run.ID, s.EmpID)
+	if err != nil {
+		return ""
+	}
+	link = strings.TrimSuffix(cfg.BaseURL, "/") + link
+	code, err := qrEncode([]byte(link))
+	if err != nil {
+		return "Verify this report card at:\n" + link + "\n"
+	}
+	return "Verify this report card by scanning the code or visiting:\n" + link + "\n\n" + qrText(code)
+}
+
+// verifyCard confirms a printed report card through the signed link on it,
+// without an account.
+func (s *server) verifyCard(w http.ResponseWriter, r *http.Request) {
+	if err := checkSignature(s.cfg.Keys, r.URL.EscapedPath(), 0, r.URL.Query()); err != nil {
+		writeError(w, http.StatusForbidden, "report card could not be verified: "+err.Error())
+		return
+	}
+	run, err := s.store.GetRun(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if err := requirePublished(run); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	for _, student := range run.Students {
+		if student.EmpID == r.PathValue("empid") {
+			writeJSON(w, http.StatusOK, CardVerification{
+				Authentic: true,
+				RunID:     run.ID,
+				Course:    run.Course,
+				EmpID:     student.EmpID,
+				Name:      student.Name,
+				Marks:     student.Marks,
+				Total:     student.Total,
+				Grade:     traceGrade(s.cfg, run, student).Grade,
+			})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "student not found in run")
+}
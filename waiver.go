@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Waiver marks one known-acceptable issue, e.g. approved grace marks that
+// make a total disagree with its components.
+type Waiver struct {
+	EmpID  string `json:"emp_id"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+func loadWaivers(path string) ([]Waiver, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var waivers []Waiver
+	if err := json.Unmarshal(data, &waivers); err != nil {
+		return nil, fmt.Errorf("invalid waivers file %s: %w", path, err)
+	}
+	for i, w := range waivers {
+		if w.EmpID == "" || w.Rule == "" || w.Reason == "" {
+			return nil, fmt.Errorf("waiver %d in %s needs emp_id, rule and reason", i+1, path)
+		}
+	}
+	return waivers, nil
+}
+
+// applyWaivers downgrades every issue matched by a waiver to "waived", keeping
+// the reason, so it is still reported but no longer counts as a failure.
+func applyWaivers(issues []Issue, waivers []Waiver) []Issue {
+	if len(waivers) == 0 {
+		return issues
+	}
+	reasons := make(map[[2]string]string, len(waivers))
+	for _, w := range waivers {
+		reasons[[2]string{w.EmpID, w.Rule}] = w.Reason
+	}
+	for i, issue := range issues {
+		if reason, ok := reasons[[2]string{issue.EmpID, issue.Rule}]; ok {
+			issues[i].Severity = severityWaived
+			issues[i].Waiver = reason
+		}
+	}
+	return issues
+}
+
+func unwaived(issues []Issue) []Issue {
+	var active []Issue
+	for _, issue := range issues {
+		if issue.Severity != severityWaived {
+			active = append(active, issue)
+		}
+	}
+	return active
+}
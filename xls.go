@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/xuri/excelize/v2"
+)
+
+// isXLS reports whether path should be read as a legacy Excel 97-2003
+// workbook: with -format auto that is decided by the extension.
+func isXLS(path string) bool {
+	if inputFormat != "auto" {
+		return inputFormat == "xls"
+	}
+	return strings.EqualFold(filepath.Ext(path), ".xls")
+}
+
+// BIFF8 record types read from an .xls workbook.
+const (
+	biffBOF         = 0x0809
+	biffEOF         = 0x000A
+	biffContinue    = 0x003C
+	biffFilePass    = 0x002F
+	biffBoundSheet  = 0x0085
+	biffSST         = 0x00FC
+	biffLabelSST    = 0x00FD
+	biffLabel       = 0x0204
+	biffNumber      = 0x0203
+	biffRK          = 0x027E
+	biffMulRK       = 0x00BD
+	biffBoolErr     = 0x0205
+	biffFormula     = 0x0006
+	biffString      = 0x0207
+	biffRow         = 0x0208
+	biffColInfo     = 0x007D
+	biffMergedCells = 0x00E5
+)
+
+// biffRecord is one record of the workbook stream, with the CONTINUE
+// records that follow it kept apart: a string split across them starts
+// again with its own flags.
+type biffRecord struct {
+	typ    uint16
+	chunks [][]byte
+}
+
+// biffRecords reads the records of a substream starting at offset, up to
+// and including its EOF record.
+func biffRecords(stream []byte, offset int) ([]biffRecord, error) {
+	var records []biffRecord
+	for offset+4 <= len(stream) {
+		typ := binary.LittleEndian.Uint16(stream[offset:])
+		size := int(binary.LittleEndian.Uint16(stream[offset+2:]))
+		offset += 4
+		if offset+size > len(stream) {
+			return records, fmt.Errorf("record %#04x runs past the end of the workbook", typ)
+		}
+		data := stream[offset : offset+size]
+		offset += size
+		if typ == biffContinue && len(records) > 0 {
+			last := &records[len(records)-1]
+			last.chunks = append(last.chunks, data)
+			continue
+		}
+		records = append(records, biffRecord{typ: typ, chunks: [][]byte{data}})
+		if typ == biffEOF {
+			return records, nil
+		}
+	}
+	return records, fmt.Errorf("the workbook ends without an EOF record")
+}
+
+func (r biffRecord) data() []byte {
+	return r.chunks[0]
+}
+
+// biffReader reads a record and its continuations as one sequence of bytes,
+// except in the characters of a string, where each continuation starts with
+// a byte saying whether they are compressed.
+type biffReader struct {
+	chunks [][]byte
+	cur    []byte
+}
+
+func newBIFFReader(r biffRecord) *biffReader {
+	return &biffReader{chunks: r.chunks[1:], cur: r.chunks[0]}
+}
+
+func (r *biffReader) next() bool {
+	if len(r.chunks) == 0 {
+		return false
+	}
+	r.cur, r.chunks = r.chunks[0], r.chunks[1:]
+	return true
+}
+
+func (r *biffReader) bytes(n int) ([]byte, error) {
+	if n <= len(r.cur) {
+		b := r.cur[:n]
+		r.cur = r.cur[n:]
+		return b, nil
+	}
+	b := append([]byte(nil), r.cur...)
+	for len(b) < n {
+		if !r.next() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		k := min(n-len(b), len(r.cur))
+		b = append(b, r.cur[:k]...)
+		r.cur = r.cur[k:]
+	}
+	return b, nil
+}
+
+func (r *biffReader) uint16() (int, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint16(b)), nil
+}
+
+// str reads an XLUnicodeString, or with rich set an
+// XLUnicodeRichExtendedString, dropping its formatting runs and phonetic
+// text.
+func (r *biffReader) str(rich bool) (string, error) {
+	n, err := r.uint16()
+	if err != nil {
+		return "", err
+	}
+	flags, err := r.bytes(1)
+	if err != nil {
+		return "", err
+	}
+	high := flags[0]&0x01 != 0
+	var runs, ext int
+	if rich && flags[0]&0x08 != 0 {
+		if runs, err = r.uint16(); err != nil {
+			return "", err
+		}
+	}
+	if rich && flags[0]&0x04 != 0 {
+		b, err := r.bytes(4)
+		if err != nil {
+			return "", err
+		}
+		ext = int(binary.LittleEndian.Uint32(b))
+	}
+
+	units := make([]uint16, 0, n)
+	for len(units) < n {
+		if len(r.cur) == 0 {
+			if !r.next() || len(r.cur) == 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			high, r.cur = r.cur[0]&0x01 != 0, r.cur[1:]
+			continue
+		}
+		if high {
+			k := min(n-len(units), len(r.cur)/2)
+			if k == 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			for i := 0; i < k; i++ {
+				units = append(units, binary.LittleEndian.Uint16(r.cur[2*i:]))
+			}
+			r.cur = r.cur[2*k:]
+		} else {
+			k := min(n-len(units), len(r.cur))
+			for _, c := range r.cur[:k] {
+				units = append(units, uint16(c))
+			}
+			r.cur = r.cur[k:]
+		}
+	}
+	if _, err := r.bytes(4*runs + ext); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// xlsNumber formats a number the way Excel shows it in the General format,
+// to 15 significant digits, without an exponent.
+func xlsNumber(v float64) string {
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func xlsRK(rk uint32) string {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&^0x03) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return xlsNumber(v)
+}
+
+var xlsErrors = map[byte]string{0x00: "#NULL!", 0x07: "#DIV/0!", 0x0F: "#VALUE!", 0x17: "#REF!", 0x1D: "#NAME?", 0x24: "#NUM!", 0x2A: "#N/A"}
+
+func xlsBoolErr(value, isErr byte) string {
+	if isErr != 0 {
+		return xlsErrors[value]
+	}
+	if value != 0 {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// loadXLSRows reads the sheet selected by -sheet from a legacy .xls
+// workbook (Excel 97-2003, BIFF8), with its merged header cells and hidden
+// rows and columns. Cells are read as their stored values, so a number
+// shows as it would in the General format whatever its number format.
+// Comments and colours are not read.
+func loadXLSRows(ctx context.Context, path string) (Sheet, error) {
+	if err := acquireWorkbook(); err != nil {
+		return Sheet{}, err
+	}
+	defer releaseWorkbook()
+
+	stream, err := readXLSStream(path)
+	if err != nil {
+		fmt.Println("Error opening the file:", err)
+		return Sheet{}, err
+	}
+	globals, err := biffRecords(stream, 0)
+	if err != nil {
+		return Sheet{}, fmt.Errorf("invalid .xls file %s: %w", path, err)
+	}
+	if len(globals) == 0 || globals[0].typ != biffBOF || len(globals[0].data()) < 2 ||
+		binary.LittleEndian.Uint16(globals[0].data()) != 0x0600 {
+		return Sheet{}, fmt.Errorf("%s is older than Excel 97; open it in Excel and save it as .xlsx", path)
+	}
+
+	var names []string
+	offsets := make(map[string]int)
+	var sst []string
+	for _, rec := range globals {
+		switch rec.typ {
+		case biffFilePass:
+			return Sheet{}, fmt.Errorf("%s is password-protected; remove the password and save it again", path)
+		case biffBoundSheet:
+			data := rec.data()
+			// Only worksheets: chart and macro sheets hold no marks.
+			if len(data) < 8 || data[5] != 0 {
+				continue
+			}
+			// The name has an 8-bit length, unlike other strings.
+			r := &biffReader{cur: append([]byte{data[6], 0}, data[7:]...)}
+			name, err := r.str(false)
+			if err != nil {
+				return Sheet{}, fmt.Errorf("invalid .xls file %s: sheet name: %w", path, err)
+			}
+			names = append(names, name)
+			offsets[name] = int(binary.LittleEndian.Uint32(data))
+		case biffSST:
+			r := newBIFFReader(rec)
+			head, err := r.bytes(8)
+			if err != nil {
+				return Sheet{}, fmt.Errorf("invalid .xls file %s: shared strings: %w", path, err)
+			}
+			count := int(binary.LittleEndian.Uint32(head[4:]))
+			sst = make([]string, 0, min(count, len(stream)/3))
+			for range count {
+				s, err := r.str(true)
+				if err != nil {
+					return Sheet{}, fmt.Errorf("invalid .xls file %s: shared strings: %w", path, err)
+				}
+				sst = append(sst, s)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return Sheet{}, fmt.Errorf("%s has no sheets", path)
+	}
+	name, err := pickSheet(names)
+	if err != nil {
+		return Sheet{}, err
+	}
+
+	records, err := biffRecords(stream, offsets[name])
+	if err != nil && len(records) == 0 {
+		return Sheet{}, fmt.Errorf("invalid .xls file %s: %w", path, err)
+	}
+	t := &xlsTable{ctx: ctx, sst: sst, hiddenRows: make(map[int]bool), hiddenCols: make(map[int]bool)}
+	if readErr := t.read(records); readErr != nil {
+		return Sheet{}, readErr
+	}
+	if err != nil {
+		// A damaged file still leaves the rows before the damage usable.
+		if len(t.rows) == 0 {
+			return Sheet{}, fmt.Errorf("invalid .xls file %s: %w", path, err)
+		}
+		return Sheet{Rows: t.rows, Name: name}, &PartialError{Stage: "read", Row: len(t.rows) + 1, Err: err}
+	}
+
+	flattenMergedHeaders(t.rows, t.merges)
+	hidden, err := newHiddenFilter(t.hiddenCols)
+	if err != nil {
+		return Sheet{}, err
+	}
+	for i := range t.rows {
+		t.rows[i] = hidden.row(t.rows[i], t.hiddenRows[i+1])
+	}
+	hidden.report()
+	return Sheet{Rows: t.rows, Name: name, Merged: mergedRows(t.merges)}, nil
+}
+
+// readXLSStream returns the Workbook stream of an .xls file, which holds
+// the sheets inside an OLE compound document.
+func readXLSStream(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := mscfb.New(f)
+	if err != nil {
+		return nil, fmt.Errorf("not an .xls file: %s is not an Excel 97-2003 workbook", path)
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		switch entry.Name {
+		case "Workbook":
+			return io.ReadAll(entry)
+		case "Book":
+			return nil, fmt.Errorf("%s is an Excel 5.0/95 workbook; open it in Excel and save it as .xlsx", path)
+		}
+	}
+	return nil, fmt.Errorf("not an .xls file: %s has no Workbook stream", path)
+}
+
+// xlsTable collects the cells of one worksheet as rows, the way readRows
+// does for a workbook.
+type xlsTable struct {
+	ctx        context.Context
+	sst        []string
+	rows       [][]string
+	merges     []excelize.MergeCell
+	hiddenRows map[int]bool
+	hiddenCols map[int]bool
+}
+
+func (t *xlsTable) read(records []biffRecord) error {
+	for i, rec := range records {
+		data := rec.data()
+		if len(data) < 6 {
+			continue
+		}
+		// Cell records start with the row and column; COLINFO with the
+		// first and last column it applies to.
+		row, col := int(binary.LittleEndian.Uint16(data)), int(binary.LittleEndian.Uint16(data[2:]))
+		var err error
+		switch rec.typ {
+		case biffLabelSST:
+			if len(data) >= 10 {
+				if k := int(binary.LittleEndian.Uint32(data[6:])); k < len(t.sst) {
+					err = t.set(row, col, t.sst[k])
+				}
+			}
+		case biffLabel:
+			r := newBIFFReader(rec)
+			r.cur = r.cur[6:]
+			text, strErr := r.str(false)
+			if strErr != nil {
+				return strErr
+			}
+			err = t.set(row, col, text)
+		case biffNumber:
+			if len(data) >= 14 {
+				err = t.set(row, col, xlsNumber(math.Float64frombits(binary.LittleEndian.Uint64(data[6:]))))
+			}
+		case biffRK:
+			if len(data) >= 10 {
+				err = t.set(row, col, xlsRK(binary.LittleEndian.Uint32(data[6:])))
+			}
+		case biffMulRK:
+			for k := 0; k < (len(data)-6)/6 && err == nil; k++ {
+				err = t.set(row, col+k, xlsRK(binary.LittleEndian.Uint32(data[4+6*k+2:])))
+			}
+		case biffBoolErr:
+			if len(data) >= 8 {
+				err = t.set(row, col, xlsBoolErr(data[6], data[7]))
+			}
+		case biffFormula:
+			if len(data) < 14 {
+				continue
+			}
+			result := data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				err = t.set(row, col, xlsNumber(math.Float64frombits(binary.LittleEndian.Uint64(result))))
+				continue
+			}
+			switch result[0] {
+			case 0:
+				// The text of a string result follows in a STRING record.
+				if i+1 < len(records) && records[i+1].typ == biffString {
+					text, strErr := newBIFFReader(records[i+1]).str(false)
+					if strErr != nil {
+						return strErr
+					}
+					err = t.set(row, col, text)
+				}
+			case 1, 2:
+				err = t.set(row, col, xlsBoolErr(result[2], result[0]-1))
+			}
+		case biffRow:
+			if len(data) >= 16 && binary.LittleEndian.Uint16(data[12:])&0x20 != 0 {
+				t.hiddenRows[row+1] = true
+			}
+		case biffColInfo:
+			if len(data) >= 10 && binary.LittleEndian.Uint16(data[8:])&0x01 != 0 {
+				for c := row; c <= min(col, 255); c++ {
+					t.hiddenCols[c+1] = true
+				}
+			}
+		case biffMergedCells:
+			for k := 0; 2+8*k+8 <= len(data); k++ {
+				ref := data[2+8*k:]
+				start, _ := excelize.CoordinatesToCellName(int(binary.LittleEndian.Uint16(ref[4:]))+1, int(binary.LittleEndian.Uint16(ref))+1)
+				end, _ := excelize.CoordinatesToCellName(int(binary.LittleEndian.Uint16(ref[6:]))+1, int(binary.LittleEndian.Uint16(ref[2:]))+1)
+				t.merges = append(t.merges, excelize.MergeCell{start + ":" + end, ""})
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// The value of a merged range is that of its first cell, known only now.
+	for k, m := range t.merges {
+		col, row, _ := excelize.CellNameToCoordinates(m.GetStartAxis())
+		if row <= len(t.rows) && col <= len(t.rows[row-1]) {
+			t.merges[k][1] = t.rows[row-1][col-1]
+		}
+	}
+	return nil
+}
+
+// set stores the text of the cell at the 0-based row and col.
+func (t *xlsTable) set(row, col int, text string) error {
+	for len(t.rows) <= row {
+		if len(t.rows)%jobStepRows == 0 {
+			if err := jobStep(t.ctx, "read", len(t.rows)); err != nil {
+				return err
+			}
+		}
+		t.rows = append(t.rows, nil)
+		if err := checkStudentLimit(len(t.rows)); err != nil {
+			return err
+		}
+	}
+	for len(t.rows[row]) <= col {
+		t.rows[row] = append(t.rows[row], "")
+	}
+	t.rows[row][col] = text
+	return nil
+}
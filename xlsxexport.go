@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var exportXLSX bool
+
+// xlsxRenderer writes the report as a workbook with a Students sheet
+// (marks, computed totals and ranks), Branch Averages, Component Averages
+// and Validation Errors. Each sheet's header row is frozen, and marks and
+// averages show two decimal places while keeping their full value.
+type xlsxRenderer struct {
+	path string
+}
+
+func (x xlsxRenderer) Render(rep *Report) error {
+	if err := writeReportWorkbook(x.path, rep); err != nil {
+		return fmt.Errorf("writing Excel report: %w", err)
+	}
+	fmt.Println("Data exported to", x.path)
+	return nil
+}
+
+func writeReportWorkbook(path string, rep *Report) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	number, err := f.NewStyle(&excelize.Style{NumFmt: 2})
+	if err != nil {
+		return err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	// sheet writes rows under a bold, frozen header, with the columns from
+	// numbersFrom on formatted as numbers.
+	first := true
+	sheet := func(name string, header []interface{}, rows [][]interface{}, numbersFrom int) error {
+		if first {
+			f.SetSheetName(f.GetSheetName(0), name)
+			first = false
+		} else if _, err := f.NewSheet(name); err != nil {
+			return err
+		}
+		for i, values := range append([][]interface{}{header}, rows...) {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(name, cell, &values); err != nil {
+				return err
+			}
+		}
+		last, _ := excelize.ColumnNumberToName(len(header))
+		if err := f.SetCellStyle(name, "A1", last+"1", bold); err != nil {
+			return err
+		}
+		if numbersFrom > 0 && len(rows) > 0 {
+			from, _ := excelize.CoordinatesToCellName(numbersFrom, 2)
+			to, _ := excelize.CoordinatesToCellName(len(header), len(rows)+1)
+			if err := f.SetCellStyle(name, from, to, number); err != nil {
+				return err
+			}
+		}
+		return f.SetPanes(name, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	}
+
+	header := []interface{}{"Rank", "EmpID", "Campus ID", "Branch", "Class No."}
+	for _, name := range rep.Marks {
+		header = append(header, name)
+	}
+	header = append(header, "Computed Total")
+	for _, name := range rep.Extra {
+		header = append(header, name)
+	}
+	rows := make([][]interface{}, len(rep.Students))
+	for i, s := range rep.Students {
+		values := []interface{}{rep.Ranks[i], s.EmpID, s.CampusID, s.Branch, s.ClassNo}
+		for _, name := range rep.Marks {
+			values = append(values, s.Marks[name])
+		}
+		values = append(values, s.Total)
+		for _, name := range rep.Extra {
+			values = append(values, s.Extra[name])
+		}
+		rows[i] = values
+	}
+	if err := sheet("Students", header, rows, 6); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for _, b := range rep.Branches {
+		rows = append(rows, []interface{}{b.Branch, b.Students, b.AverageTotal})
+	}
+	if err := sheet("Branch Averages", []interface{}{"Branch", "Students", "Mean Computed Total"}, rows, 3); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for _, c := range append(rep.components(), rep.TotalStats) {
+		rows = append(rows, []interface{}{c.Name, c.Mean, c.Median, c.Min, c.Max, c.StdDev})
+	}
+	if err := sheet("Component Averages", []interface{}{"Component", "Mean", "Median", "Min", "Max", "Std Dev"}, rows, 2); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for _, issue := range rep.Issues {
+		var row interface{}
+		if issue.Row > 0 {
+			row = issue.Row
+		}
+		rows = append(rows, []interface{}{issue.Severity, issue.Rule, issue.EmpID, row, issue.Column, issue.Message, issue.Waiver})
+	}
+	if err := sheet("Validation Errors", []interface{}{"Severity", "Rule", "EmpID", "Row", "Column", "Message", "Waiver"}, rows, 0); err != nil {
+		return err
+	}
+
+	f.SetActiveSheet(0)
+	return f.SaveAs(path)
+}